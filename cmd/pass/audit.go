@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/audit"
+	"github.com/ChaosHour/go-pass/internal/config"
+	"github.com/ChaosHour/go-pass/internal/policy"
+)
+
+var errFindings = fmt.Errorf("findings at or above the --fail-on severity")
+
+// runAudit checks the accounts of a host or dump file against the audit rules.
+// It returns errFindings when a finding is at least as severe as --fail-on.
+func runAudit(ctx context.Context, cfg *config.Config) error {
+	var accounts []account.Account
+	var err error
+	source := cfg.SourceHost
+	if cfg.SourceFile != "" {
+		source = cfg.SourceFile
+		accounts, err = loadFileAccounts(cfg.SourceFile, cfg.OnlyUser)
+	} else {
+		accounts, err = loadHostAccounts(ctx, cfg, cfg.SourceHost)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeFindings(audit.Run(source, accounts), cfg)
+}
+
+// runPolicyCheck evaluates the accounts of a host or dump file against a
+// policy file. It returns errFindings like runAudit.
+func runPolicyCheck(ctx context.Context, cfg *config.Config) error {
+	data, err := os.ReadFile(cfg.PolicyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read policy file: %w", err)
+	}
+	p, err := policy.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cfg.PolicyFile, err)
+	}
+
+	var accounts []account.Account
+	source := cfg.SourceHost
+	if cfg.SourceFile != "" {
+		source = cfg.SourceFile
+		accounts, err = loadFileAccounts(cfg.SourceFile, cfg.OnlyUser)
+	} else {
+		accounts, err = loadHostAccounts(ctx, cfg, cfg.SourceHost)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeFindings(p.Evaluate(source, cfg.Env, accounts), cfg)
+}
+
+// writeFindings writes report in the configured format and returns
+// errFindings when a finding reaches the --fail-on severity
+func writeFindings(report *audit.Report, cfg *config.Config) error {
+	var err error
+	switch cfg.Format {
+	case "json":
+		err = report.WriteJSON(os.Stdout)
+	case "sarif":
+		err = report.WriteSARIF(os.Stdout, cfg.SourceFile)
+	default:
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+
+	threshold := audit.Severity(cfg.FailOn).Rank()
+	for _, f := range report.Findings {
+		if f.Severity.Rank() >= threshold {
+			return errFindings
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/config"
+	"github.com/ChaosHour/go-pass/internal/database"
+	"github.com/ChaosHour/go-pass/internal/diff"
+	"gopkg.in/yaml.v3"
+)
+
+var errDrift = fmt.Errorf("account drift detected")
+
+// runDiff compares the accounts of the source host with either the target
+// host or a saved dump file. It returns errDrift when differences are found.
+func runDiff(ctx context.Context, cfg *config.Config) error {
+	var report *diff.Report
+	if cfg.SourceFile != "" {
+		// the saved file is the source of truth, the live server is compared to it
+		saved, err := loadFileAccounts(cfg.SourceFile, cfg.OnlyUser)
+		if err != nil {
+			return err
+		}
+		live, err := loadHostAccounts(ctx, cfg, cfg.SourceHost)
+		if err != nil {
+			return err
+		}
+		report = diff.Compare(cfg.SourceFile, saved, cfg.SourceHost, live)
+	} else {
+		source, err := loadHostAccounts(ctx, cfg, cfg.SourceHost)
+		if err != nil {
+			return err
+		}
+		target, err := loadHostAccounts(ctx, cfg, cfg.TargetHost)
+		if err != nil {
+			return err
+		}
+		report = diff.Compare(cfg.SourceHost, source, cfg.TargetHost, target)
+	}
+
+	var err error
+	if cfg.Format == "json" {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+	if report.HasDrift() {
+		return errDrift
+	}
+	return nil
+}
+
+// loadHostAccounts connects to host with the credentials in cfg and loads its accounts
+func loadHostAccounts(ctx context.Context, cfg *config.Config, host string) ([]account.Account, error) {
+	hostCfg := *cfg
+	hostCfg.SourceHost = host
+	db, srv, err := connect(ctx, &hostCfg)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return database.LoadAccounts(ctx, db, srv, &hostCfg)
+}
+
+// loadFileAccounts parses a saved dump file, keeping the same accounts that
+// would be read from a server for the -o filter. Import and pt-like dumps
+// are replayed; JSON and YAML dumps are decoded as they are.
+func loadFileAccounts(path, onlyUser string) ([]account.Account, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dump file: %w", err)
+	}
+	var accounts []account.Account
+	switch ext := filepath.Ext(path); {
+	case strings.HasPrefix(strings.TrimSpace(string(data)), "["), ext == ".json":
+		err = json.Unmarshal(data, &accounts)
+	case ext == ".yaml", ext == ".yml":
+		err = yaml.Unmarshal(data, &accounts)
+	default:
+		accounts, err = account.ParseSQL(string(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	account.Sort(accounts)
+	return account.FilterUser(accounts, onlyUser), nil
+}
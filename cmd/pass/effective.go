@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/config"
+	"github.com/ChaosHour/go-pass/internal/effective"
+)
+
+// runEffective prints what an account can do through its own grants and its
+// active roles. Every account is loaded so that the roles can be resolved.
+func runEffective(ctx context.Context, cfg *config.Config) error {
+	name, err := account.ParseName(cfg.Account)
+	if err != nil {
+		return err
+	}
+
+	var accounts []account.Account
+	if cfg.SourceFile != "" {
+		accounts, err = loadFileAccounts(cfg.SourceFile, "")
+	} else {
+		accounts, err = loadHostAccounts(ctx, cfg, cfg.SourceHost)
+	}
+	if err != nil {
+		return err
+	}
+
+	res, err := effective.NewResolver(accounts).Resolve(name, cfg.AllRoles)
+	if err != nil {
+		return err
+	}
+	if cfg.Format == "json" {
+		return res.WriteJSON(os.Stdout)
+	}
+	return res.WriteText(os.Stdout)
+}
+
+// runWhoCan lists the accounts that hold a privilege on an object, through
+// their own grants or any of their roles
+func runWhoCan(ctx context.Context, cfg *config.Config) error {
+	o, err := effective.ParseObject(cfg.On)
+	if err != nil {
+		return err
+	}
+
+	var accounts []account.Account
+	if cfg.SourceFile != "" {
+		accounts, err = loadFileAccounts(cfg.SourceFile, "")
+	} else {
+		accounts, err = loadHostAccounts(ctx, cfg, cfg.SourceHost)
+	}
+	if err != nil {
+		return err
+	}
+
+	h := effective.WhoCan(accounts, o, cfg.Privilege)
+	if cfg.Format == "json" {
+		return h.WriteJSON(os.Stdout)
+	}
+	return h.WriteText(os.Stdout)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/config"
+	"github.com/ChaosHour/go-pass/internal/passhash"
+)
+
+// runHash reads a password from in and prints its
+// authentication string, raw and in the 0x form accepted by IDENTIFIED ... AS,
+// or a CREATE USER statement when an account was given. Non-printable salt
+// bytes are escaped in the raw form so they cannot break the output lines.
+func runHash(in io.Reader, out io.Writer, cfg *config.Config) error {
+	password, err := readPassword(in)
+	if err != nil {
+		return err
+	}
+
+	auth, err := passhash.Generate(cfg.Plugin, password)
+	if err != nil {
+		return err
+	}
+	authHex := strings.ToUpper(hex.EncodeToString([]byte(auth)))
+
+	if cfg.Account != "" {
+		name, err := account.ParseName(cfg.Account)
+		if err != nil {
+			return err
+		}
+		a := account.Account{User: name.User, Host: name.Host, Plugin: cfg.Plugin, AuthString: authHex}
+		_, err = fmt.Fprintln(out, a.CreateStatement()+";")
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n0x%s\n", escapeBytes(auth), authHex)
+	return err
+}
+
+// escapeBytes replaces bytes outside printable ASCII, and backslashes, with
+// \xNN escapes
+func escapeBytes(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c > 0x7e || c == '\\' {
+			fmt.Fprintf(&b, "\\x%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
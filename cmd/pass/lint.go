@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/config"
+	"github.com/ChaosHour/go-pass/internal/database"
+	"github.com/ChaosHour/go-pass/internal/lint"
+)
+
+// runLint reports redundant and orphaned grants with the statements that
+// clean them up. The objects that exist come from --schemas or the host;
+// without either, grants on missing objects are not checked.
+func runLint(ctx context.Context, cfg *config.Config) error {
+	var catalog *lint.Catalog
+	if cfg.SchemasFile != "" {
+		data, err := os.ReadFile(cfg.SchemasFile)
+		if err != nil {
+			return fmt.Errorf("failed to read schema list: %w", err)
+		}
+		if catalog, err = lint.ParseCatalog(data); err != nil {
+			return fmt.Errorf("%s: %w", cfg.SchemasFile, err)
+		}
+	}
+
+	var accounts []account.Account
+	source := cfg.SourceHost
+	if cfg.SourceFile != "" {
+		source = cfg.SourceFile
+		var err error
+		if accounts, err = loadFileAccounts(cfg.SourceFile, cfg.OnlyUser); err != nil {
+			return err
+		}
+	} else {
+		db, srv, err := connect(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		if accounts, err = database.LoadAccounts(ctx, db, srv, cfg); err != nil {
+			return err
+		}
+		if catalog == nil {
+			objects, err := database.ListObjects(ctx, db)
+			if err != nil {
+				return err
+			}
+			catalog = lint.NewCatalog(objects)
+		}
+	}
+
+	report := lint.Run(source, accounts, catalog)
+	if cfg.Format == "json" {
+		return report.WriteJSON(os.Stdout)
+	}
+	return report.WriteText(os.Stdout)
+}
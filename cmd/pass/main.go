@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"os"
@@ -23,18 +24,20 @@ func main() {
 		os.Exit(0)
 	}
 
-	// Check if ~/.my.cnf exists
-	home := os.Getenv("HOME")
-	if home == "" {
-		log.Fatal(red("[!]"), "HOME environment variable not set")
-	}
-	if _, err := os.Stat(home + "/.my.cnf"); os.IsNotExist(err) {
-		fmt.Println(red("[!]"), "Please create a ~/.my.cnf file with the database credentials.")
-		os.Exit(1)
-	}
+	if !cfg.Offline() {
+		// Check if ~/.my.cnf exists
+		home := os.Getenv("HOME")
+		if home == "" {
+			log.Fatal(red("[!]"), "HOME environment variable not set")
+		}
+		if _, err := os.Stat(home + "/.my.cnf"); os.IsNotExist(err) {
+			fmt.Println(red("[!]"), "Please create a ~/.my.cnf file with the database credentials.")
+			os.Exit(1)
+		}
 
-	if err := cfg.LoadMyCnf(); err != nil {
-		log.Fatal(red("[!]"), err)
+		if err := cfg.LoadMyCnf(); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -42,20 +45,103 @@ func main() {
 	}
 
 	ctx := context.Background()
+	switch cfg.Command {
+	case config.CmdDiff:
+		if err := runDiff(ctx, cfg); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
+	case config.CmdPlan:
+		if err := runPlan(ctx, cfg); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
+	case config.CmdApply:
+		if err := runApply(ctx, cfg); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
+	case config.CmdHash:
+		if err := runHash(os.Stdin, os.Stdout, cfg); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
+	case config.CmdVerify:
+		if err := runVerify(ctx, os.Stdin, cfg); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
+	case config.CmdWeak:
+		if err := runWeak(ctx, cfg); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
+	case config.CmdAudit:
+		if err := runAudit(ctx, cfg); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
+	case config.CmdPolicy:
+		if err := runPolicyCheck(ctx, cfg); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
+	case config.CmdMigrate:
+		if err := runMigrate(ctx, cfg); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
+	case config.CmdRotate:
+		if err := runRotate(ctx, cfg); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
+	case config.CmdEffective:
+		if err := runEffective(ctx, cfg); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
+	case config.CmdWhoCan:
+		if err := runWhoCan(ctx, cfg); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
+	case config.CmdMatch:
+		if err := runMatch(ctx, cfg); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
+	case config.CmdLint:
+		if err := runLint(ctx, cfg); err != nil {
+			log.Fatal(red("[!]"), err)
+		}
+	default:
+		runDump(ctx, cfg)
+	}
+}
+
+// connect opens a connection to cfg.SourceHost and detects its server
+func connect(ctx context.Context, cfg *config.Config) (*sql.DB, *database.ServerInfo, error) {
 	db, err := database.Connect(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	srv, err := database.DetectServer(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	log.Println(green("[+]"), "Server:", srv)
+	return db, srv, nil
+}
+
+func runDump(ctx context.Context, cfg *config.Config) {
+	db, srv, err := connect(ctx, cfg)
 	if err != nil {
 		log.Fatal(red("[!]"), err)
 	}
 	defer db.Close()
 
-	if err := database.DumpUserAccounts(ctx, db, cfg); err != nil {
+	if err := database.DumpUserAccounts(ctx, db, srv, cfg); err != nil {
 		log.Fatal(red("[!]"), err)
 	}
 
+	if cfg.Format == "json" || cfg.Format == "yaml" {
+		log.Println(green("[+]"), "Wrote accounts to", cfg.DumpFile)
+		return
+	}
+
 	// Sleep for 5 seconds as in original
 	time.Sleep(5 * time.Second)
 
-	if err := database.RunQuery(ctx, db, cfg); err != nil {
+	if err := database.RunQuery(ctx, db, srv, cfg); err != nil {
 		log.Fatal(red("[!]"), err)
 	}
 
@@ -64,10 +150,97 @@ func main() {
 
 func printHelp() {
 	fmt.Println("Usage: go-pass -s <source host> -f <dump file>")
+	fmt.Println("       go-pass diff -s <source host> -t <target host>")
+	fmt.Println("       go-pass diff -s <host> --file <dump file>")
+	fmt.Println("       go-pass plan -s <source host>|--file <dump file> -t <target host> -f <plan file>")
+	fmt.Println("       go-pass apply [-t <target host>] <plan or import file>")
+	fmt.Println("       go-pass hash [--plugin <plugin>] [--create-user <user@host>] < password")
+	fmt.Println("       go-pass verify-password -s <host>|--file <dump file> <user@host> < password")
+	fmt.Println("       go-pass weak-passwords -s <host>|--file <dump file> [--wordlist <file>] [--reveal]")
+	fmt.Println("       go-pass audit -s <host>|--file <dump file> [--format text|json|sarif]")
+	fmt.Println("       go-pass policy-check --policy <policy file> -s <host>|--file <dump file> [--env <env>]")
+	fmt.Println("       go-pass migrate-plugin -s <host> [--passwords <csv>|--generate --secrets-out <csv>] [-f <file>]")
+	fmt.Println("       go-pass rotate -s <host> --secret-file <file>|--hook <command> <user@host>")
+	fmt.Println("       go-pass rotate -s <host> --finalize <user@host>")
+	fmt.Println("       go-pass effective -s <host>|--file <dump file> [--all-roles] <user@host>")
+	fmt.Println("       go-pass who-can -s <host>|--file <dump file> --on <schema.table> --privilege <privilege>")
+	fmt.Println("       go-pass match -s <host>|--file <dump file> --user <user> --from <client host>")
+	fmt.Println("       go-pass lint -s <host>|--file <dump file> [--schemas <file>]")
+	fmt.Println("Flags must come before the <user@host> or file argument.")
 	fmt.Println("Options:")
 	fmt.Println("  -s <source host>  Source MySQL host")
 	fmt.Println("  -f <dump file>    Output dump file")
 	fmt.Println("  -o <user>         Only dump the specified user")
-	fmt.Println("  --format <fmt>    Output format: raw, import, pt-like (default: raw)")
+	fmt.Println("  --format <fmt>    Output format: raw, import, pt-like, json, yaml (default: raw)")
+	fmt.Println("  --undo-file <f>   Undo script path (default: <dump file>.undo.sql)")
+	fmt.Println("  --target-version <v>  Translate the import format for MySQL 5.7, 8.0, 8.4, ...")
+	fmt.Println("  --target-profile <p>  Adjust the import format for rds, aurora, cloudsql, azure or a profile file")
 	fmt.Println("  -h                Print this help")
+	fmt.Println("Diff options:")
+	fmt.Println("  -t <target host>  Target MySQL host to compare against")
+	fmt.Println("  --file <file>     Saved import or pt-like dump to compare the host against")
+	fmt.Println("  --format <fmt>    Report format: text, json (default: text)")
+	fmt.Println("Plan options:")
+	fmt.Println("  -s <source host>  Host whose accounts the target should match")
+	fmt.Println("  --file <file>     Dump file whose accounts the target should match")
+	fmt.Println("  -t <target host>  Host the plan is computed for")
+	fmt.Println("  -f <plan file>    Output plan file")
+	fmt.Println("Apply options:")
+	fmt.Println("  -t <target host>  Target host; overrides the target recorded in a plan")
+	fmt.Println("  --undo-file <f>   Undo script path (default: <file>.undo.sql)")
+	fmt.Println("  --dry-run         Print the statements without executing them")
+	fmt.Println("  --continue-on-error  Keep applying after a statement fails")
+	fmt.Println("  --skip-binlog     Run SET SESSION sql_log_bin=0 first (replica-local changes)")
+	fmt.Println("Hash options:")
+	fmt.Println("  --plugin <name>   caching_sha2_password (default), sha256_password, mysql_native_password")
+	fmt.Println("  --create-user <user@host>  Print a CREATE USER statement instead of the bare hash")
+	fmt.Println("Verify-password options:")
+	fmt.Println("  -s <host>         Host to read the authentication string from")
+	fmt.Println("  --file <file>     Import or pt-like dump to read the authentication string from")
+	fmt.Println("Weak-passwords options:")
+	fmt.Println("  -s <host>         Host whose accounts are checked")
+	fmt.Println("  --file <file>     Import or pt-like dump to check instead of a host")
+	fmt.Println("  -o <user>         Only check the specified user")
+	fmt.Println("  --wordlist <file> Candidate passwords, one per line")
+	fmt.Println("  --workers <n>     Number of concurrent workers (default: number of CPUs)")
+	fmt.Println("  --reveal          Print guessed passwords and how they were found")
+	fmt.Println("Audit options:")
+	fmt.Println("  -s <host>         Host whose accounts are audited")
+	fmt.Println("  --file <file>     Import or pt-like dump to audit instead of a host")
+	fmt.Println("  -o <user>         Only audit the specified user")
+	fmt.Println("  --format <fmt>    Report format: text, json, sarif (default: text)")
+	fmt.Println("  --fail-on <sev>   Lowest severity that fails the run: critical, high, medium, low (default: low)")
+	fmt.Println("Policy-check options:")
+	fmt.Println("  --policy <file>   YAML or JSON policy file")
+	fmt.Println("  --env <env>       Environment whose forbidden_privileges apply in addition to \"all\"")
+	fmt.Println("  -s, --file, -o, --format, --fail-on  As for audit")
+	fmt.Println("Migrate-plugin options:")
+	fmt.Println("  --from <plugin>   Plugin to migrate away from (default: mysql_native_password)")
+	fmt.Println("  --to <plugin>     Plugin to migrate to (default: caching_sha2_password)")
+	fmt.Println("  -o <user>         Only migrate the specified user")
+	fmt.Println("  --passwords <csv> New passwords: user, host, password and current_password columns")
+	fmt.Println("  --generate        Generate passwords for accounts missing from --passwords")
+	fmt.Println("  --secrets-out <f> CSV file generated passwords are written to")
+	fmt.Println("  --retain-current  Keep current passwords valid with RETAIN CURRENT PASSWORD")
+	fmt.Println("  -f <file>         Output file for the ALTER USER statements")
+	fmt.Println("Rotate options:")
+	fmt.Println("  --secret-file <f> File the new password is written to (mode 0600)")
+	fmt.Println("  --hook <command>  Shell command that receives the new password on stdin")
+	fmt.Println("  --finalize        Discard the old password once all clients use the new one")
+	fmt.Println("Effective options:")
+	fmt.Println("  -s <host>         Host to read the account and its roles from")
+	fmt.Println("  --file <file>     Dump file to read the account and its roles from")
+	fmt.Println("  --all-roles       Treat every granted role as active, not only the default roles")
+	fmt.Println("  --format <fmt>    Output format: text, json (default: text)")
+	fmt.Println("Who-can options:")
+	fmt.Println("  --on <object>     schema, schema.table or *.*, optionally prefixed with PROCEDURE or FUNCTION")
+	fmt.Println("  --privilege <p>   Privilege to look up, e.g. SELECT")
+	fmt.Println("  -s, --file, --format  As for effective; --file also takes JSON and YAML dumps")
+	fmt.Println("Match options:")
+	fmt.Println("  --user <user>     User name the client logs in with")
+	fmt.Println("  --from <host>     Host name or IP address the client connects from; names are not resolved")
+	fmt.Println("  -s, --file, --format  As for who-can")
+	fmt.Println("Lint options:")
+	fmt.Println("  --schemas <file>  Schemas, tables and routines that exist, one per line (default: read from -s)")
+	fmt.Println("  -s, --file, --format  As for who-can")
 }
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/config"
+	"github.com/ChaosHour/go-pass/internal/login"
+)
+
+// runMatch shows which account row the server picks for a client, and the
+// rows of the user that can never be picked
+func runMatch(ctx context.Context, cfg *config.Config) error {
+	var accounts []account.Account
+	var err error
+	if cfg.SourceFile != "" {
+		accounts, err = loadFileAccounts(cfg.SourceFile, "")
+	} else {
+		accounts, err = loadHostAccounts(ctx, cfg, cfg.SourceHost)
+	}
+	if err != nil {
+		return err
+	}
+
+	res := login.Match(accounts, cfg.LoginUser, cfg.ClientHost)
+	if cfg.Format == "json" {
+		return res.WriteJSON(os.Stdout)
+	}
+	return res.WriteText(os.Stdout)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/config"
+	"github.com/ChaosHour/go-pass/internal/database"
+	"github.com/ChaosHour/go-pass/internal/migrate"
+)
+
+// runMigrate lists accounts per plugin and the clients of the accounts on
+// --from. With passwords it also writes the ALTER USER statements that move
+// those accounts to --to, to be executed with go-pass apply.
+func runMigrate(ctx context.Context, cfg *config.Config) error {
+	db, srv, err := connect(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	accounts, err := database.LoadAccounts(ctx, db, srv, cfg)
+	if err != nil {
+		return err
+	}
+	if err := migrate.WriteInventory(os.Stdout, accounts); err != nil {
+		return err
+	}
+
+	selected := migrate.Select(accounts, cfg.FromPlugin)
+	conns, err := database.ClientConnections(ctx, db)
+	if err != nil {
+		// performance_schema may be disabled; the plan is still useful
+		log.Println(red("[!]"), "Client report unavailable:", err)
+	} else if err := migrate.WriteClients(os.Stdout, cfg.FromPlugin, selected, conns); err != nil {
+		return err
+	}
+
+	if cfg.PlanFile == "" {
+		return nil
+	}
+	if len(selected) == 0 {
+		log.Printf("%s No unlocked %s accounts to migrate", green("[+]"), cfg.FromPlugin)
+		return nil
+	}
+
+	creds := map[string]migrate.Credential{}
+	if cfg.PasswordsFile != "" {
+		f, err := os.Open(cfg.PasswordsFile)
+		if err != nil {
+			return fmt.Errorf("failed to open passwords file: %w", err)
+		}
+		creds, err = migrate.ReadCredentials(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", cfg.PasswordsFile, err)
+		}
+	}
+
+	stmts, generated, err := migrate.Build(selected, creds, migrate.Options{
+		To:            cfg.Plugin,
+		Generate:      cfg.Generate,
+		RetainCurrent: cfg.RetainCurrent,
+	})
+	if err != nil {
+		return err
+	}
+
+	// both files contain passwords
+	if len(generated) > 0 {
+		var b strings.Builder
+		if err := migrate.WriteCredentials(&b, generated); err != nil {
+			return err
+		}
+		if err := writePrivate(cfg.SecretsFile, []byte(b.String())); err != nil {
+			return fmt.Errorf("failed to write secrets file: %w", err)
+		}
+		log.Printf("%s Wrote %d generated passwords to %s", green("[+]"), len(generated), cfg.SecretsFile)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- go-pass migrate-plugin: %d accounts from %s to %s on %s\n", len(selected), cfg.FromPlugin, cfg.Plugin, cfg.SourceHost)
+	fmt.Fprintf(&b, "-- contains passwords; execute with: go-pass apply -t %s %s\n", cfg.SourceHost, cfg.PlanFile)
+	for _, s := range stmts {
+		b.WriteString(s + ";\n")
+	}
+	if err := writePrivate(cfg.PlanFile, []byte(b.String())); err != nil {
+		return fmt.Errorf("failed to write %s: %w", cfg.PlanFile, err)
+	}
+	log.Printf("%s Wrote %d statements to %s", green("[+]"), len(stmts), cfg.PlanFile)
+	return nil
+}
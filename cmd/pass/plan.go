@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/config"
+	"github.com/ChaosHour/go-pass/internal/database"
+	"github.com/ChaosHour/go-pass/internal/plan"
+	"github.com/ChaosHour/go-pass/internal/sqlsplit"
+)
+
+// runPlan writes the statements that make the target host match the source
+// host or dump file
+func runPlan(ctx context.Context, cfg *config.Config) error {
+	var source []account.Account
+	var err error
+	if cfg.SourceFile != "" {
+		source, err = loadFileAccounts(cfg.SourceFile, cfg.OnlyUser)
+	} else {
+		source, err = loadHostAccounts(ctx, cfg, cfg.SourceHost)
+	}
+	if err != nil {
+		return err
+	}
+
+	targetCfg := *cfg
+	targetCfg.SourceHost = cfg.TargetHost
+	db, srv, err := connect(ctx, &targetCfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	target, err := database.LoadAccounts(ctx, db, srv, &targetCfg)
+	if err != nil {
+		return err
+	}
+
+	p := &plan.Plan{
+		Target:         cfg.TargetHost,
+		OnlyUser:       cfg.OnlyUser,
+		TargetChecksum: plan.Fingerprint(target),
+		Statements:     plan.Build(source, target, srv.Dialect()),
+	}
+
+	file, err := os.Create(cfg.PlanFile)
+	if err != nil {
+		return fmt.Errorf("failed to create plan file: %w", err)
+	}
+	defer file.Close()
+	if err := p.Write(file); err != nil {
+		return fmt.Errorf("failed to write plan file: %w", err)
+	}
+	log.Printf("%s Wrote %d statements to %s", green("[+]"), len(p.Statements), cfg.PlanFile)
+	return nil
+}
+
+var errApplyFailed = fmt.Errorf("one or more statements failed")
+
+// runApply executes a plan or an import-format file statement by statement.
+// Plans are only applied if the target has not changed since they were made.
+// It returns errApplyFailed when any statement failed.
+func runApply(ctx context.Context, cfg *config.Config) error {
+	data, err := os.ReadFile(cfg.PlanFile)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", cfg.PlanFile, err)
+	}
+
+	host := cfg.TargetHost
+	var p *plan.Plan
+	var statements []string
+	if plan.IsPlan(string(data)) {
+		if p, err = plan.Read(string(data)); err != nil {
+			return err
+		}
+		if host == "" {
+			host = p.Target
+		}
+		statements = p.Statements
+	} else if statements, err = sqlsplit.Split(string(data)); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", cfg.PlanFile, err)
+	}
+	if host == "" {
+		return fmt.Errorf("target host (-t) is required")
+	}
+
+	targetCfg := *cfg
+	targetCfg.SourceHost = host
+	db, srv, err := connect(ctx, &targetCfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if p != nil {
+		targetCfg.OnlyUser = p.OnlyUser
+		current, err := database.LoadAccounts(ctx, db, srv, &targetCfg)
+		if err != nil {
+			return err
+		}
+		if plan.Fingerprint(current) != p.TargetChecksum {
+			return fmt.Errorf("accounts on %s changed since the plan was made, create a new plan", host)
+		}
+	}
+
+	if !cfg.DryRun {
+		if _, err := database.WriteUndo(ctx, db, srv, statements, cfg.UndoPath()); err != nil {
+			return err
+		}
+	}
+
+	report, err := database.Apply(ctx, db, statements, database.ApplyOptions{
+		DryRun:          cfg.DryRun,
+		ContinueOnError: cfg.ContinueOnError,
+		SkipBinlog:      cfg.SkipBinlog,
+	})
+	if err != nil {
+		return err
+	}
+	if err := report.WriteText(os.Stdout); err != nil {
+		return err
+	}
+	if report.Failed() {
+		return errApplyFailed
+	}
+	return nil
+}
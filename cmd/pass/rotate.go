@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/config"
+	"github.com/ChaosHour/go-pass/internal/database"
+	"github.com/ChaosHour/go-pass/internal/passhash"
+)
+
+// runRotate gives an account a generated password while keeping the current
+// one valid, or with --finalize discards the old password again. The new
+// password is stored before the server is changed so it cannot get lost.
+func runRotate(ctx context.Context, cfg *config.Config) error {
+	name, err := account.ParseName(cfg.Account)
+	if err != nil {
+		return err
+	}
+
+	db, srv, err := connect(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if !srv.Has(database.DualPasswords) {
+		return fmt.Errorf("%s does not support dual passwords", srv)
+	}
+
+	accounts, err := database.SnapshotAccounts(ctx, db, srv, []account.Name{name})
+	if err != nil {
+		return err
+	}
+	if len(accounts) == 0 {
+		return fmt.Errorf("account %s not found", name.ID())
+	}
+	secondary, err := database.SecondaryPasswords(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Finalize {
+		if !secondary[name] {
+			log.Printf("%s %s holds no old password", green("[+]"), name.ID())
+			return nil
+		}
+		if err := database.DiscardOldPassword(ctx, db, name); err != nil {
+			return err
+		}
+		log.Printf("%s Discarded the old password of %s", green("[+]"), name.ID())
+		return nil
+	}
+
+	if secondary[name] {
+		return fmt.Errorf("%s still holds the old password of a previous rotation; run rotate --finalize first", name.ID())
+	}
+	if !slices.Contains(passhash.Plugins, accounts[0].Plugin) {
+		return fmt.Errorf("%s uses %s, which does not support dual passwords", name.ID(), accounts[0].Plugin)
+	}
+
+	password, err := passhash.NewPassword(32)
+	if err != nil {
+		return err
+	}
+	if err := storeSecret(ctx, cfg, name, password); err != nil {
+		return err
+	}
+	if err := database.RetainCurrentPassword(ctx, db, name, password); err != nil {
+		return err
+	}
+	log.Printf("%s Rotated the password of %s; the old password stays valid until rotate --finalize", green("[+]"), name.ID())
+	return nil
+}
+
+// storeSecret writes the password to --secret-file and pipes it to --hook
+func storeSecret(ctx context.Context, cfg *config.Config, name account.Name, password string) error {
+	if cfg.SecretsFile != "" {
+		if err := writePrivate(cfg.SecretsFile, []byte(password+"\n")); err != nil {
+			return fmt.Errorf("failed to write secret file: %w", err)
+		}
+		log.Printf("%s Wrote the new password of %s to %s", green("[+]"), name.ID(), cfg.SecretsFile)
+	}
+	if cfg.Hook != "" {
+		cmd := exec.CommandContext(ctx, "sh", "-c", cfg.Hook)
+		cmd.Stdin = strings.NewReader(password + "\n")
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(), "GO_PASS_USER="+name.User, "GO_PASS_HOST="+name.Host)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to run hook: %w", err)
+		}
+	}
+	return nil
+}
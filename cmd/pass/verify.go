@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/config"
+	"github.com/ChaosHour/go-pass/internal/passhash"
+)
+
+// errMismatch is returned when the candidate password does not match
+var errMismatch = fmt.Errorf("password does not match")
+
+// runVerify checks a candidate password read from in against the
+// authentication string of one account, taken from a server or a dump file.
+// The check is done locally; no login is attempted.
+func runVerify(ctx context.Context, in io.Reader, cfg *config.Config) error {
+	name, err := account.ParseName(cfg.Account)
+	if err != nil {
+		return err
+	}
+
+	var accounts []account.Account
+	if cfg.SourceFile != "" {
+		accounts, err = loadFileAccounts(cfg.SourceFile, name.User)
+	} else {
+		hostCfg := *cfg
+		hostCfg.OnlyUser = name.User
+		accounts, err = loadHostAccounts(ctx, &hostCfg, cfg.SourceHost)
+	}
+	if err != nil {
+		return err
+	}
+
+	var a *account.Account
+	for i := range accounts {
+		if accounts[i].ID() == name.ID() {
+			a = &accounts[i]
+		}
+	}
+	if a == nil {
+		return fmt.Errorf("account %s not found", name.ID())
+	}
+	if a.Plugin == "" {
+		return fmt.Errorf("the authentication plugin of %s is unknown", name.ID())
+	}
+
+	password, err := readPassword(in)
+	if err != nil {
+		return err
+	}
+	ok, err := passhash.Verify(a.Plugin, a.AuthBytes(), password)
+	if err != nil {
+		return fmt.Errorf("failed to verify %s: %w", name.ID(), err)
+	}
+	if !ok {
+		return fmt.Errorf("%s: %w", name.ID(), errMismatch)
+	}
+	log.Printf("%s Password matches %s (%s)", green("[+]"), name.ID(), a.Plugin)
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/config"
+	"github.com/ChaosHour/go-pass/internal/weakpass"
+)
+
+var errWeak = fmt.Errorf("weak passwords found")
+
+// runWeak checks the accounts of a host or dump file against a wordlist and
+// trivial variants of their names. It returns errWeak when any password was
+// guessed.
+func runWeak(ctx context.Context, cfg *config.Config) error {
+	var words []string
+	if cfg.Wordlist != "" {
+		f, err := os.Open(cfg.Wordlist)
+		if err != nil {
+			return fmt.Errorf("failed to open wordlist: %w", err)
+		}
+		defer f.Close()
+		if words, err = weakpass.ReadWordlist(f); err != nil {
+			return err
+		}
+	}
+
+	var accounts []account.Account
+	var err error
+	if cfg.SourceFile != "" {
+		accounts, err = loadFileAccounts(cfg.SourceFile, cfg.OnlyUser)
+	} else {
+		accounts, err = loadHostAccounts(ctx, cfg, cfg.SourceHost)
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := weakpass.Check(ctx, accounts, words, cfg.Workers)
+	if err != nil {
+		return err
+	}
+	if err := result.WriteText(os.Stdout, cfg.Reveal); err != nil {
+		return err
+	}
+	if len(result.Findings) > 0 {
+		return errWeak
+	}
+	return nil
+}
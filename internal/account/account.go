@@ -0,0 +1,417 @@
+package account
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Account holds the structured form of a MySQL or MariaDB account as
+// reported by SHOW CREATE USER and SHOW GRANTS
+type Account struct {
+	User                   string         `json:"user" yaml:"user"`
+	Host                   string         `json:"host" yaml:"host"`
+	Role                   bool           `json:"role,omitempty" yaml:"role,omitempty"` // a MariaDB role, which has no host and is kept under %
+	Plugin                 string         `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+	AuthString             string         `json:"auth_string,omitempty" yaml:"auth_string,omitempty"`   // hex-encoded, upper case
+	Factors                []Factor       `json:"factors,omitempty" yaml:"factors,omitempty"`           // factors 2 and 3; Plugin and AuthString are the first
+	Alternatives           []Factor       `json:"alternatives,omitempty" yaml:"alternatives,omitempty"` // MariaDB IDENTIFIED VIA ... OR plugins
+	Require                string         `json:"require,omitempty" yaml:"require,omitempty"`
+	MaxQueriesPerHour      int            `json:"max_queries_per_hour,omitempty" yaml:"max_queries_per_hour,omitempty"`
+	MaxUpdatesPerHour      int            `json:"max_updates_per_hour,omitempty" yaml:"max_updates_per_hour,omitempty"`
+	MaxConnectionsPerHour  int            `json:"max_connections_per_hour,omitempty" yaml:"max_connections_per_hour,omitempty"`
+	MaxUserConnections     int            `json:"max_user_connections,omitempty" yaml:"max_user_connections,omitempty"`
+	MaxStatementTime       string         `json:"max_statement_time,omitempty" yaml:"max_statement_time,omitempty"` // MariaDB, in seconds
+	PasswordExpire         string         `json:"password_expire,omitempty" yaml:"password_expire,omitempty"`
+	PasswordHistory        string         `json:"password_history,omitempty" yaml:"password_history,omitempty"`                 // DEFAULT or a number of passwords
+	PasswordReuseInterval  string         `json:"password_reuse_interval,omitempty" yaml:"password_reuse_interval,omitempty"`   // DEFAULT or a number of days
+	PasswordRequireCurrent string         `json:"password_require_current,omitempty" yaml:"password_require_current,omitempty"` // DEFAULT, OPTIONAL or REQUIRED
+	FailedLoginAttempts    int            `json:"failed_login_attempts,omitempty" yaml:"failed_login_attempts,omitempty"`
+	PasswordLockTime       string         `json:"password_lock_time,omitempty" yaml:"password_lock_time,omitempty"` // a number of days or UNBOUNDED
+	Locked                 bool           `json:"locked" yaml:"locked"`
+	Comment                string         `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Attributes             map[string]any `json:"attributes,omitempty" yaml:"attributes,omitempty"` // user attributes other than the comment
+	SecondaryPassword      bool           `json:"secondary_password,omitempty" yaml:"secondary_password,omitempty"`
+	DefaultRoles           []string       `json:"default_roles,omitempty" yaml:"default_roles,omitempty"`
+	Grants                 []Grant        `json:"grants,omitempty" yaml:"grants,omitempty"`
+	Roles                  []RoleGrant    `json:"roles,omitempty" yaml:"roles,omitempty"`
+	Revokes                []Grant        `json:"partial_revokes,omitempty" yaml:"partial_revokes,omitempty"`
+}
+
+// Privilege is a single privilege, optionally restricted to columns
+type Privilege struct {
+	Name    string   `json:"name" yaml:"name"`
+	Columns []string `json:"columns,omitempty" yaml:"columns,omitempty"`
+}
+
+// Grant is a set of privileges granted (or partially revoked) on one object level
+type Grant struct {
+	Privileges  []Privilege `json:"privileges" yaml:"privileges"`
+	ObjectType  string      `json:"object_type,omitempty" yaml:"object_type,omitempty"` // TABLE, FUNCTION, PROCEDURE or PROXY
+	Schema      string      `json:"schema" yaml:"schema"`                               // "*" for all schemas
+	Object      string      `json:"object" yaml:"object"`                               // "*" for all objects
+	GrantOption bool        `json:"grant_option,omitempty" yaml:"grant_option,omitempty"`
+}
+
+// RoleGrant is a role granted to an account
+type RoleGrant struct {
+	Role        string `json:"role" yaml:"role"` // quoted `user`@`host`
+	AdminOption bool   `json:"admin_option,omitempty" yaml:"admin_option,omitempty"`
+}
+
+// Factor is an additional authentication factor of a multi-factor account
+type Factor struct {
+	Plugin     string `json:"plugin" yaml:"plugin"`
+	AuthString string `json:"auth_string,omitempty" yaml:"auth_string,omitempty"` // hex-encoded, upper case
+}
+
+// Quote returns the backtick-quoted `user`@`host` form of an account name
+func Quote(user, host string) string {
+	return QuoteIdent(user) + "@" + QuoteIdent(host)
+}
+
+// QuoteIdent backtick-quotes an identifier, doubling embedded backticks
+func QuoteIdent(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
+// QuoteString single-quotes a string literal, escaping quotes and backslashes
+func QuoteString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// ID returns the quoted account name used as a key throughout go-pass
+func (a *Account) ID() string {
+	return Quote(a.User, a.Host)
+}
+
+// AuthBytes returns the decoded authentication string
+func (a *Account) AuthBytes() []byte {
+	b, err := hex.DecodeString(a.AuthString)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// IdentifiedClause renders the IDENTIFIED WITH clause of the first factor of
+// the account, or an empty string when the plugin is unknown
+func (a *Account) IdentifiedClause() string {
+	if a.Plugin == "" {
+		return ""
+	}
+	return Factor{Plugin: a.Plugin, AuthString: a.AuthString}.Clause()
+}
+
+// AuthClause renders the IDENTIFIED WITH clauses of every factor joined by AND
+// as CREATE USER takes them
+func (a *Account) AuthClause() string {
+	clause := a.IdentifiedClause()
+	if clause == "" {
+		return ""
+	}
+	for _, f := range a.Factors {
+		clause += " AND " + f.Clause()
+	}
+	return clause
+}
+
+// Clause renders the IDENTIFIED WITH clause of the factor
+func (f Factor) Clause() string {
+	clause := "IDENTIFIED WITH " + QuoteString(f.Plugin)
+	if f.AuthString != "" {
+		clause += " AS 0x" + f.AuthString
+	}
+	return clause
+}
+
+// FactorStatement renders ALTER USER with an ADD or MODIFY option for each
+// additional factor of the account, or an empty string for single-factor
+// accounts. ALTER USER only sets the first factor through IDENTIFIED and
+// does not accept these options together with any other.
+func (a *Account) FactorStatement(op string) string {
+	if len(a.Factors) == 0 {
+		return ""
+	}
+	stmt := "ALTER USER " + a.ID()
+	for i, f := range a.Factors {
+		stmt += fmt.Sprintf(" %s %d FACTOR %s", op, i+2, f.Clause())
+	}
+	return stmt
+}
+
+// ExpireClause renders the PASSWORD EXPIRE clause of the account
+func (a *Account) ExpireClause() string {
+	switch a.PasswordExpire {
+	case "":
+		return ""
+	case "NOW":
+		return "PASSWORD EXPIRE"
+	}
+	return "PASSWORD EXPIRE " + a.PasswordExpire
+}
+
+// LockClause renders the ACCOUNT LOCK or ACCOUNT UNLOCK clause of the account
+func (a *Account) LockClause() string {
+	if a.Locked {
+		return "ACCOUNT LOCK"
+	}
+	return "ACCOUNT UNLOCK"
+}
+
+// ResourceClause renders the WITH clause of the resource limits that are set
+func (a *Account) ResourceClause() string {
+	var limits []string
+	for _, l := range []struct {
+		name  string
+		value int
+	}{
+		{"MAX_QUERIES_PER_HOUR", a.MaxQueriesPerHour},
+		{"MAX_UPDATES_PER_HOUR", a.MaxUpdatesPerHour},
+		{"MAX_CONNECTIONS_PER_HOUR", a.MaxConnectionsPerHour},
+		{"MAX_USER_CONNECTIONS", a.MaxUserConnections},
+	} {
+		if l.value != 0 {
+			limits = append(limits, l.name+" "+strconv.Itoa(l.value))
+		}
+	}
+	if a.MaxStatementTime != "" {
+		limits = append(limits, "MAX_STATEMENT_TIME "+a.MaxStatementTime)
+	}
+	if len(limits) == 0 {
+		return ""
+	}
+	return "WITH " + strings.Join(limits, " ")
+}
+
+// PasswordClauses renders the password history, reuse, verification and
+// failed-login options of the account in SHOW CREATE USER order
+func (a *Account) PasswordClauses() []string {
+	var clauses []string
+	if a.PasswordHistory != "" {
+		clauses = append(clauses, "PASSWORD HISTORY "+a.PasswordHistory)
+	}
+	switch a.PasswordReuseInterval {
+	case "":
+	case "DEFAULT":
+		clauses = append(clauses, "PASSWORD REUSE INTERVAL DEFAULT")
+	default:
+		clauses = append(clauses, "PASSWORD REUSE INTERVAL "+a.PasswordReuseInterval+" DAY")
+	}
+	switch a.PasswordRequireCurrent {
+	case "":
+	case "REQUIRED":
+		clauses = append(clauses, "PASSWORD REQUIRE CURRENT")
+	default:
+		clauses = append(clauses, "PASSWORD REQUIRE CURRENT "+a.PasswordRequireCurrent)
+	}
+	if a.FailedLoginAttempts != 0 {
+		clauses = append(clauses, "FAILED_LOGIN_ATTEMPTS "+strconv.Itoa(a.FailedLoginAttempts))
+	}
+	if a.PasswordLockTime != "" && a.PasswordLockTime != "0" {
+		clauses = append(clauses, "PASSWORD_LOCK_TIME "+a.PasswordLockTime)
+	}
+	return clauses
+}
+
+// AttributeClause renders the comment and user attributes of the account.
+// MySQL stores the comment as the "comment" attribute and accepts only one
+// of COMMENT and ATTRIBUTE per statement.
+func (a *Account) AttributeClause() string {
+	if len(a.Attributes) == 0 {
+		if a.Comment == "" {
+			return ""
+		}
+		return "COMMENT " + QuoteString(a.Comment)
+	}
+	attrs := make(map[string]any, len(a.Attributes)+1)
+	for k, v := range a.Attributes {
+		attrs[k] = v
+	}
+	if a.Comment != "" {
+		attrs["comment"] = a.Comment
+	}
+	// json.Marshal sorts map keys, which keeps the statement stable
+	data, _ := json.Marshal(attrs)
+	return "ATTRIBUTE " + QuoteString(string(data))
+}
+
+// SetAttributes replaces the comment and user attributes of the account with
+// the JSON object MySQL keeps in INFORMATION_SCHEMA.USER_ATTRIBUTES
+func (a *Account) SetAttributes(data string) error {
+	var attrs map[string]any
+	if err := json.Unmarshal([]byte(data), &attrs); err != nil {
+		return fmt.Errorf("invalid user attributes %q: %w", data, err)
+	}
+	a.Comment, _ = attrs["comment"].(string)
+	delete(attrs, "comment")
+	a.Attributes = nil
+	if len(attrs) > 0 {
+		a.Attributes = attrs
+	}
+	return nil
+}
+
+// clauses renders every account option that CREATE USER and ALTER USER
+// accept after the account name and the authentication clause
+func (a *Account) clauses(auth string) []string {
+	var parts []string
+	if auth != "" {
+		parts = append(parts, auth)
+	}
+	if a.Require != "" {
+		parts = append(parts, "REQUIRE "+a.Require)
+	}
+	if c := a.ResourceClause(); c != "" {
+		parts = append(parts, c)
+	}
+	if c := a.ExpireClause(); c != "" {
+		parts = append(parts, c)
+	}
+	parts = append(parts, a.LockClause())
+	parts = append(parts, a.PasswordClauses()...)
+	if c := a.AttributeClause(); c != "" {
+		parts = append(parts, c)
+	}
+	return parts
+}
+
+// CreateStatement renders a CREATE USER statement for the account. Default
+// roles are left out since the roles may not exist yet when it runs.
+func (a *Account) CreateStatement() string {
+	return strings.Join(append([]string{"CREATE USER " + a.ID()}, a.clauses(a.AuthClause())...), " ")
+}
+
+// AlterStatement renders an ALTER USER statement that sets every option of
+// CreateStatement on an existing account except the additional factors,
+// see FactorStatement
+func (a *Account) AlterStatement() string {
+	return strings.Join(append([]string{"ALTER USER " + a.ID()}, a.clauses(a.IdentifiedClause())...), " ")
+}
+
+// DefaultRoleStatement renders the ALTER USER statement that sets the default
+// roles of the account. It has to run after the roles were granted.
+func (a *Account) DefaultRoleStatement() string {
+	if len(a.DefaultRoles) == 0 {
+		return "ALTER USER " + a.ID() + " DEFAULT ROLE NONE"
+	}
+	return "ALTER USER " + a.ID() + " DEFAULT ROLE " + strings.Join(a.DefaultRoles, ", ")
+}
+
+// Level returns the privilege level of the grant as it appears after ON
+func (g *Grant) Level() string {
+	if g.ObjectType == "PROXY" {
+		return g.Object
+	}
+	level := quoteLevelPart(g.Schema) + "." + quoteLevelPart(g.Object)
+	if g.ObjectType != "" && g.ObjectType != "TABLE" {
+		level = g.ObjectType + " " + level
+	}
+	return level
+}
+
+func quoteLevelPart(s string) string {
+	if s == "*" {
+		return s
+	}
+	return QuoteIdent(s)
+}
+
+// String renders the privilege with its column list, if any
+func (p Privilege) String() string {
+	if len(p.Columns) == 0 {
+		return p.Name
+	}
+	cols := make([]string, len(p.Columns))
+	for i, c := range p.Columns {
+		cols[i] = QuoteIdent(c)
+	}
+	return p.Name + " (" + strings.Join(cols, ", ") + ")"
+}
+
+// Statement renders the grant as a GRANT statement for the given grantee
+func (g *Grant) Statement(grantee string) string {
+	stmt := "GRANT " + joinPrivileges(g.Privileges) + " ON " + g.Level() + " TO " + grantee
+	if g.GrantOption {
+		stmt += " WITH GRANT OPTION"
+	}
+	return stmt
+}
+
+// RevokeStatement renders the grant as a REVOKE statement for the given grantee
+func (g *Grant) RevokeStatement(grantee string) string {
+	return "REVOKE " + joinPrivileges(g.Privileges) + " ON " + g.Level() + " FROM " + grantee
+}
+
+func joinPrivileges(privs []Privilege) string {
+	parts := make([]string, len(privs))
+	for i, p := range privs {
+		parts[i] = p.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Statement renders the role grant as a GRANT statement for the given grantee
+func (r RoleGrant) Statement(grantee string) string {
+	stmt := "GRANT " + r.Role + " TO " + grantee
+	if r.AdminOption {
+		stmt += " WITH ADMIN OPTION"
+	}
+	return stmt
+}
+
+// PrivilegeSet flattens grants into level -> privilege keys. Column privileges
+// become one key per column and GRANT OPTION is its own key. USAGE is dropped
+// since it grants nothing.
+func PrivilegeSet(grants []Grant) map[string]map[string]bool {
+	set := make(map[string]map[string]bool)
+	for _, g := range grants {
+		level := g.Level()
+		add := func(key string) {
+			if set[level] == nil {
+				set[level] = make(map[string]bool)
+			}
+			set[level][key] = true
+		}
+		for _, p := range g.Privileges {
+			if p.Name == "USAGE" {
+				continue
+			}
+			if len(p.Columns) == 0 {
+				add(p.Name)
+				continue
+			}
+			for _, c := range p.Columns {
+				add(Privilege{Name: p.Name, Columns: []string{c}}.String())
+			}
+		}
+		if g.GrantOption {
+			add("GRANT OPTION")
+		}
+	}
+	return set
+}
+
+// SortedKeys returns the keys of a set in sorted order
+func SortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Sort orders accounts by user then host
+func Sort(accounts []Account) {
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].User != accounts[j].User {
+			return accounts[i].User < accounts[j].User
+		}
+		return accounts[i].Host < accounts[j].Host
+	})
+}
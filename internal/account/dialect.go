@@ -0,0 +1,135 @@
+package account
+
+import "strings"
+
+// Dialect is the SQL syntax account statements are rendered in. The methods of
+// Account render MySQL syntax; a Dialect renders the same statements for
+// either server.
+type Dialect int
+
+const (
+	MySQL Dialect = iota
+	MariaDB
+)
+
+// String returns the name of the dialect
+func (d Dialect) String() string {
+	if d == MariaDB {
+		return "MariaDB"
+	}
+	return "MySQL"
+}
+
+// RoleName renders a role as GRANT and SET DEFAULT ROLE take it. MariaDB
+// roles have no host, so the `%` host go-pass gives them is left out.
+func (d Dialect) RoleName(role string) string {
+	if d == MariaDB {
+		return strings.TrimSuffix(role, "@`%`")
+	}
+	return role
+}
+
+// Grantee renders the account as the grantee of GRANT and REVOKE
+func (d Dialect) Grantee(a *Account) string {
+	if d == MariaDB && a.Role {
+		return QuoteIdent(a.User)
+	}
+	return a.ID()
+}
+
+// IdentifiedClause renders the authentication of the account. MariaDB takes
+// the authentication string as text after USING and lists alternative
+// plugins with OR.
+func (d Dialect) IdentifiedClause(a *Account) string {
+	if d != MariaDB {
+		return a.IdentifiedClause()
+	}
+	if a.Plugin == "" {
+		return ""
+	}
+	clause := "IDENTIFIED VIA " + mariaDBAuth(Factor{Plugin: a.Plugin, AuthString: a.AuthString})
+	for _, f := range a.Alternatives {
+		clause += " OR " + mariaDBAuth(f)
+	}
+	return clause
+}
+
+func mariaDBAuth(f Factor) string {
+	auth := f.Plugin
+	if f.AuthString != "" {
+		a := Account{AuthString: f.AuthString}
+		auth += " USING " + QuoteString(string(a.AuthBytes()))
+	}
+	return auth
+}
+
+// mariaDBClauses renders the account options MariaDB's CREATE USER and ALTER
+// USER accept. MariaDB has no password history, failed-login tracking or
+// user attributes.
+func (a *Account) mariaDBClauses() []string {
+	var parts []string
+	if c := MariaDB.IdentifiedClause(a); c != "" {
+		parts = append(parts, c)
+	}
+	if a.Require != "" {
+		parts = append(parts, "REQUIRE "+a.Require)
+	}
+	if c := a.ResourceClause(); c != "" {
+		parts = append(parts, c)
+	}
+	if c := a.ExpireClause(); c != "" {
+		parts = append(parts, c)
+	}
+	return append(parts, a.LockClause())
+}
+
+// CreateStatement renders CREATE USER, or CREATE ROLE for a MariaDB role
+func (d Dialect) CreateStatement(a *Account) string {
+	switch {
+	case d != MariaDB:
+		return a.CreateStatement()
+	case a.Role:
+		return "CREATE ROLE " + QuoteIdent(a.User)
+	}
+	return strings.Join(append([]string{"CREATE USER " + a.ID()}, a.mariaDBClauses()...), " ")
+}
+
+// AlterStatement renders ALTER USER with every option of CreateStatement.
+// MariaDB roles have no options, for them it returns an empty string.
+func (d Dialect) AlterStatement(a *Account) string {
+	switch {
+	case d != MariaDB:
+		return a.AlterStatement()
+	case a.Role:
+		return ""
+	}
+	return strings.Join(append([]string{"ALTER USER " + a.ID()}, a.mariaDBClauses()...), " ")
+}
+
+// DefaultRoleStatement renders the statement that sets the default roles of
+// the account. MariaDB uses SET DEFAULT ROLE ... FOR and takes a single role.
+func (d Dialect) DefaultRoleStatement(a *Account) string {
+	switch {
+	case d != MariaDB:
+		return a.DefaultRoleStatement()
+	case a.Role:
+		return ""
+	case len(a.DefaultRoles) == 0:
+		return "SET DEFAULT ROLE NONE FOR " + a.ID()
+	}
+	return "SET DEFAULT ROLE " + d.RoleName(a.DefaultRoles[0]) + " FOR " + a.ID()
+}
+
+// RoleGrantStatement renders the role grant as a GRANT statement for grantee
+func (d Dialect) RoleGrantStatement(r RoleGrant, grantee string) string {
+	return RoleGrant{Role: d.RoleName(r.Role), AdminOption: r.AdminOption}.Statement(grantee)
+}
+
+// DropStatement renders DROP USER IF EXISTS, or DROP ROLE IF EXISTS for a
+// MariaDB role
+func (d Dialect) DropStatement(a *Account) string {
+	if d == MariaDB && a.Role {
+		return "DROP ROLE IF EXISTS " + QuoteIdent(a.User)
+	}
+	return "DROP USER IF EXISTS " + a.ID()
+}
@@ -0,0 +1,53 @@
+package account
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mariaDBApp is an account as printed by SHOW CREATE USER on MariaDB 10.6
+const mariaDBApp = "CREATE USER `app`@`%` IDENTIFIED VIA ed25519 USING 'ZIgUREUg5PVgQ6LskhXmO+eZLS0nC8be6HPjYWR4YJY' OR unix_socket REQUIRE SSL WITH MAX_USER_CONNECTIONS 20 MAX_STATEMENT_TIME 30.000000 PASSWORD EXPIRE INTERVAL 90 DAY"
+
+func TestDialect_MariaDB(t *testing.T) {
+	a, err := ParseCreateUser(mariaDBApp)
+	assert.NoError(t, err)
+	a.DefaultRoles = []string{"`app_read`@`%`"}
+
+	assert.Equal(t, "CREATE USER `app`@`%` IDENTIFIED VIA ed25519 USING 'ZIgUREUg5PVgQ6LskhXmO+eZLS0nC8be6HPjYWR4YJY' OR unix_socket REQUIRE SSL WITH MAX_USER_CONNECTIONS 20 MAX_STATEMENT_TIME 30.000000 PASSWORD EXPIRE INTERVAL 90 DAY ACCOUNT UNLOCK", MariaDB.CreateStatement(a))
+	assert.Equal(t, "ALTER USER `app`@`%` IDENTIFIED VIA ed25519 USING 'ZIgUREUg5PVgQ6LskhXmO+eZLS0nC8be6HPjYWR4YJY' OR unix_socket REQUIRE SSL WITH MAX_USER_CONNECTIONS 20 MAX_STATEMENT_TIME 30.000000 PASSWORD EXPIRE INTERVAL 90 DAY ACCOUNT UNLOCK", MariaDB.AlterStatement(a))
+	assert.Equal(t, "SET DEFAULT ROLE `app_read` FOR `app`@`%`", MariaDB.DefaultRoleStatement(a))
+	assert.Equal(t, "GRANT `app_read` TO `app`@`%` WITH ADMIN OPTION", MariaDB.RoleGrantStatement(RoleGrant{Role: "`app_read`@`%`", AdminOption: true}, a.ID()))
+	assert.Equal(t, "DROP USER IF EXISTS `app`@`%`", MariaDB.DropStatement(a))
+
+	// MariaDB's own output parses back to the same account
+	again, err := ParseCreateUser(MariaDB.CreateStatement(a))
+	assert.NoError(t, err)
+	again.DefaultRoles = a.DefaultRoles
+	assert.Equal(t, a, again)
+
+	a.DefaultRoles = nil
+	assert.Equal(t, "SET DEFAULT ROLE NONE FOR `app`@`%`", MariaDB.DefaultRoleStatement(a))
+}
+
+func TestDialect_MariaDBRole(t *testing.T) {
+	r := &Account{User: "app_read", Host: "%", Role: true, Locked: true}
+	assert.Equal(t, "CREATE ROLE `app_read`", MariaDB.CreateStatement(r))
+	assert.Empty(t, MariaDB.AlterStatement(r))
+	assert.Empty(t, MariaDB.DefaultRoleStatement(r))
+	assert.Equal(t, "`app_read`", MariaDB.Grantee(r))
+	assert.Equal(t, "DROP ROLE IF EXISTS `app_read`", MariaDB.DropStatement(r))
+
+	// MySQL keeps roles as accounts
+	assert.Equal(t, "`app_read`@`%`", MySQL.Grantee(r))
+	assert.Equal(t, "GRANT `app_read`@`%` TO `app`@`%`", MySQL.RoleGrantStatement(RoleGrant{Role: "`app_read`@`%`"}, "`app`@`%`"))
+	assert.Equal(t, r.CreateStatement(), MySQL.CreateStatement(r))
+}
+
+func TestDialect_MariaDBNativePassword(t *testing.T) {
+	a, err := ParseCreateUser("CREATE USER `legacy`@`10.0.%` IDENTIFIED BY PASSWORD '*6BB4837EB74329105EE4568DDA7DC67ED2CA2AD9'")
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_native_password", a.Plugin)
+	assert.Equal(t, "IDENTIFIED VIA mysql_native_password USING '*6BB4837EB74329105EE4568DDA7DC67ED2CA2AD9'", MariaDB.IdentifiedClause(a))
+	assert.Equal(t, "IDENTIFIED WITH 'mysql_native_password' AS 0x2A36424234383337454237343332393130354545343536384444413744433637454432434132414439", MySQL.IdentifiedClause(a))
+}
@@ -0,0 +1,884 @@
+package account
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokIdent
+	tokString
+	tokHex
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits SQL text into tokens, dropping comments. Quoted identifiers
+// and strings are unescaped, hex literals are returned without the 0x prefix.
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || isDashComment(s[i:]):
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == '`':
+			text, n, err := readQuoted(s[i:], '`', false)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{tokIdent, text})
+			i += n
+		case c == '\'' || c == '"':
+			text, n, err := readQuoted(s[i:], c, true)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{tokString, text})
+			i += n
+		case c == '0' && i+1 < len(s) && (s[i+1] == 'x' || s[i+1] == 'X'):
+			j := i + 2
+			for j < len(s) && isHexDigit(s[j]) {
+				j++
+			}
+			toks = append(toks, token{tokHex, strings.ToUpper(s[i+2 : j])})
+			i = j
+		case isWordChar(c):
+			j := i
+			for j < len(s) && isWordChar(s[j]) {
+				j++
+			}
+			toks = append(toks, token{tokWord, s[i:j]})
+			i = j
+		default:
+			toks = append(toks, token{tokPunct, string(c)})
+			i++
+		}
+	}
+	return toks, nil
+}
+
+// readQuoted reads a quoted token starting at s[0] and returns its unescaped
+// text and the number of bytes consumed
+func readQuoted(s string, quote byte, backslash bool) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if backslash && c == '\\' && i+1 < len(s) {
+			i++
+			b.WriteByte(unescape(s[i]))
+			continue
+		}
+		if c == quote {
+			if i+1 < len(s) && s[i+1] == quote {
+				b.WriteByte(quote)
+				i++
+				continue
+			}
+			return b.String(), i + 1, nil
+		}
+		b.WriteByte(c)
+	}
+	return "", 0, fmt.Errorf("unterminated %c quote", quote)
+}
+
+func unescape(c byte) byte {
+	switch c {
+	case '0':
+		return 0
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'Z':
+		return 26
+	}
+	return c
+}
+
+// isDashComment reports whether s starts with a -- comment, which MySQL only
+// recognises when followed by whitespace
+func isDashComment(s string) bool {
+	if !strings.HasPrefix(s, "--") {
+		return false
+	}
+	return len(s) > 2 && (s[2] == ' ' || s[2] == '\t' || s[2] == '\n' || s[2] == '\r')
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '$' || c == '%' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func newParser(stmt string) (*parser, error) {
+	toks, err := tokenize(strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+	if err != nil {
+		return nil, err
+	}
+	return &parser{toks: toks}, nil
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.toks)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: tokPunct}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// isWords reports whether the next tokens are the given keywords
+func (p *parser) isWords(words ...string) bool {
+	for i, w := range words {
+		if p.pos+i >= len(p.toks) {
+			return false
+		}
+		t := p.toks[p.pos+i]
+		if t.kind != tokWord || !strings.EqualFold(t.text, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// acceptWords consumes the given keywords if they are next
+func (p *parser) acceptWords(words ...string) bool {
+	if !p.isWords(words...) {
+		return false
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *parser) expectWords(words ...string) error {
+	if !p.acceptWords(words...) {
+		return fmt.Errorf("expected %s near %q", strings.Join(words, " "), p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) isPunct(c string) bool {
+	t := p.peek()
+	return !p.done() && t.kind == tokPunct && t.text == c
+}
+
+func (p *parser) acceptPunct(c string) bool {
+	if !p.isPunct(c) {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+// name reads an identifier, string or bare word
+func (p *parser) name() (string, error) {
+	t := p.peek()
+	if p.done() || t.kind == tokPunct || t.kind == tokHex {
+		return "", fmt.Errorf("expected name near %q", t.text)
+	}
+	p.pos++
+	return t.text, nil
+}
+
+// accountName reads user[@host]; the host defaults to %
+func (p *parser) accountName() (string, string, error) {
+	user, err := p.name()
+	if err != nil {
+		return "", "", err
+	}
+	host := "%"
+	if p.acceptPunct("@") {
+		if host, err = p.name(); err != nil {
+			return "", "", err
+		}
+	}
+	return user, host, nil
+}
+
+// accountList reads a comma separated list of account names in quoted form
+func (p *parser) accountList() ([]string, error) {
+	var names []string
+	for {
+		user, host, err := p.accountName()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, Quote(user, host))
+		if !p.acceptPunct(",") {
+			return names, nil
+		}
+	}
+}
+
+// authString reads a string or hex literal and returns it hex-encoded
+func (p *parser) authString() (string, error) {
+	t := p.next()
+	switch t.kind {
+	case tokHex:
+		return t.text, nil
+	case tokString:
+		return strings.ToUpper(hex.EncodeToString([]byte(t.text))), nil
+	}
+	return "", fmt.Errorf("expected authentication string near %q", t.text)
+}
+
+// ParseCreateUser parses a CREATE USER statement as printed by SHOW CREATE USER
+func ParseCreateUser(stmt string) (*Account, error) {
+	p, err := newParser(stmt)
+	if err != nil {
+		return nil, err
+	}
+	return p.createUser()
+}
+
+func (p *parser) createUser() (*Account, error) {
+	if err := p.expectWords("CREATE", "USER"); err != nil {
+		return nil, err
+	}
+	p.acceptWords("IF", "NOT", "EXISTS")
+	user, host, err := p.accountName()
+	if err != nil {
+		return nil, err
+	}
+	a := &Account{User: user, Host: host}
+	if err := p.userOptions(a); err != nil {
+		return nil, fmt.Errorf("%s: %w", a.ID(), err)
+	}
+	return a, nil
+}
+
+// userOptions parses the clauses following the account name of a CREATE USER
+// or ALTER USER statement. Clauses go-pass does not track are skipped.
+func (p *parser) userOptions(a *Account) error {
+	for !p.done() {
+		switch {
+		case p.acceptWords("IDENTIFIED"):
+			if err := p.identified(&a.Plugin, &a.AuthString); err != nil {
+				return err
+			}
+		case p.acceptWords("OR"):
+			// MariaDB: IDENTIFIED VIA plugin USING ... OR plugin ...
+			f, err := p.alternative()
+			if err != nil {
+				return err
+			}
+			a.Alternatives = append(a.Alternatives, f)
+		case p.acceptWords("AND", "IDENTIFIED"):
+			var f Factor
+			if err := p.identified(&f.Plugin, &f.AuthString); err != nil {
+				return err
+			}
+			a.Factors = append(a.Factors, f)
+		case p.acceptWords("INITIAL", "AUTHENTICATION", "IDENTIFIED"):
+			// a temporary password for the registration of a FIDO factor
+			var plugin, auth string
+			if err := p.identified(&plugin, &auth); err != nil {
+				return err
+			}
+		case p.isWords("ADD") || p.isWords("MODIFY") || p.isWords("DROP"):
+			if err := p.factorOptions(a); err != nil {
+				return err
+			}
+		case p.acceptWords("DEFAULT", "ROLE"):
+			if p.acceptWords("NONE") {
+				a.DefaultRoles = nil
+				continue
+			}
+			roles, err := p.accountList()
+			if err != nil {
+				return err
+			}
+			a.DefaultRoles = roles
+		case p.acceptWords("REQUIRE"):
+			a.Require = p.require()
+		case p.acceptWords("WITH"):
+			if err := p.resourceLimits(a); err != nil {
+				return err
+			}
+		case p.acceptWords("PASSWORD", "EXPIRE"):
+			a.PasswordExpire = p.passwordExpire()
+		case p.acceptWords("PASSWORD", "HISTORY"):
+			a.PasswordHistory = strings.ToUpper(p.next().text)
+		case p.acceptWords("PASSWORD", "REUSE", "INTERVAL"):
+			a.PasswordReuseInterval = strings.ToUpper(p.next().text)
+			p.acceptWords("DAY")
+		case p.acceptWords("PASSWORD", "REQUIRE", "CURRENT"):
+			a.PasswordRequireCurrent = "REQUIRED"
+			if p.isWords("DEFAULT") || p.isWords("OPTIONAL") {
+				a.PasswordRequireCurrent = strings.ToUpper(p.next().text)
+			}
+		case p.acceptWords("FAILED_LOGIN_ATTEMPTS"):
+			n, err := p.number()
+			if err != nil {
+				return err
+			}
+			a.FailedLoginAttempts = n
+		case p.acceptWords("PASSWORD_LOCK_TIME"):
+			a.PasswordLockTime = strings.ToUpper(p.next().text)
+		case p.acceptWords("COMMENT"):
+			a.Comment = p.next().text
+		case p.acceptWords("ATTRIBUTE"):
+			if err := p.attribute(a); err != nil {
+				return err
+			}
+		case p.acceptWords("ACCOUNT", "LOCK"):
+			a.Locked = true
+		case p.acceptWords("ACCOUNT", "UNLOCK"):
+			a.Locked = false
+		default:
+			p.next()
+		}
+	}
+	return nil
+}
+
+// identified parses the rest of an IDENTIFIED clause into the plugin and
+// authentication string of one factor. IDENTIFIED BY a cleartext password
+// keeps the plugin and leaves the hash unknown. MariaDB's VIA and USING are
+// synonyms of WITH and AS.
+func (p *parser) identified(plugin, auth *string) error {
+	switch {
+	case p.acceptWords("WITH"), p.acceptWords("VIA"):
+		f, err := p.alternative()
+		if err != nil {
+			return err
+		}
+		*plugin, *auth = f.Plugin, f.AuthString
+	case p.acceptWords("BY", "PASSWORD"):
+		hash, err := p.authString()
+		if err != nil {
+			return err
+		}
+		*plugin = "mysql_native_password"
+		*auth = hash
+	case p.acceptWords("BY"):
+		p.next()
+	}
+	return nil
+}
+
+// alternative parses a plugin with its optional AS, USING or BY clause
+func (p *parser) alternative() (Factor, error) {
+	var f Factor
+	name, err := p.name()
+	if err != nil {
+		return f, err
+	}
+	f.Plugin = name
+	if p.acceptWords("AS") || p.acceptWords("USING") {
+		if f.AuthString, err = p.authString(); err != nil {
+			return f, err
+		}
+	} else if p.acceptWords("BY") {
+		p.next()
+	}
+	return f, nil
+}
+
+// factorOptions parses the ADD, MODIFY and DROP n FACTOR options of ALTER
+// USER. Factor numbers refer to the account before the statement, so drops
+// are applied last and from the highest factor down.
+func (p *parser) factorOptions(a *Account) error {
+	var drops []int
+	for p.isWords("ADD") || p.isWords("MODIFY") || p.isWords("DROP") {
+		op := strings.ToUpper(p.next().text)
+		n, err := p.number()
+		if err != nil {
+			return err
+		}
+		if err := p.expectWords("FACTOR"); err != nil {
+			return err
+		}
+		i := n - 2
+		if i < 0 || i > len(a.Factors) || (op != "ADD" && i == len(a.Factors)) {
+			return fmt.Errorf("cannot %s factor %d of %d", strings.ToLower(op), n, len(a.Factors)+1)
+		}
+		if op == "DROP" {
+			drops = append(drops, i)
+			continue
+		}
+		if err := p.expectWords("IDENTIFIED"); err != nil {
+			return err
+		}
+		var f Factor
+		if err := p.identified(&f.Plugin, &f.AuthString); err != nil {
+			return err
+		}
+		if i == len(a.Factors) {
+			a.Factors = append(a.Factors, f)
+		} else {
+			a.Factors[i] = f
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(drops)))
+	for _, i := range drops {
+		a.Factors = append(a.Factors[:i:i], a.Factors[i+1:]...)
+	}
+	if len(a.Factors) == 0 {
+		a.Factors = nil
+	}
+	return nil
+}
+
+// resourceLimits parses the MAX_* options following WITH
+func (p *parser) resourceLimits(a *Account) error {
+	limits := map[string]*int{
+		"MAX_QUERIES_PER_HOUR":     &a.MaxQueriesPerHour,
+		"MAX_UPDATES_PER_HOUR":     &a.MaxUpdatesPerHour,
+		"MAX_CONNECTIONS_PER_HOUR": &a.MaxConnectionsPerHour,
+		"MAX_USER_CONNECTIONS":     &a.MaxUserConnections,
+	}
+	for !p.done() {
+		if p.acceptWords("MAX_STATEMENT_TIME") {
+			// MariaDB prints the seconds with a fraction, e.g. 10.000000
+			a.MaxStatementTime = p.next().text
+			if p.acceptPunct(".") {
+				a.MaxStatementTime += "." + p.next().text
+			}
+			continue
+		}
+		limit, ok := limits[strings.ToUpper(p.peek().text)]
+		if !ok || p.peek().kind != tokWord {
+			return nil
+		}
+		p.next()
+		n, err := p.number()
+		if err != nil {
+			return err
+		}
+		*limit = n
+	}
+	return nil
+}
+
+func (p *parser) number() (int, error) {
+	t := p.next()
+	n, err := strconv.Atoi(t.text)
+	if err != nil || t.kind != tokWord {
+		return 0, fmt.Errorf("expected number near %q", t.text)
+	}
+	return n, nil
+}
+
+// attribute parses the JSON object of an ATTRIBUTE clause, which SHOW CREATE
+// USER also uses to print the comment. Like MySQL the object is merged into
+// the existing attributes and null values remove keys.
+func (p *parser) attribute(a *Account) error {
+	t := p.next()
+	if t.kind != tokString {
+		return fmt.Errorf("expected attribute string near %q", t.text)
+	}
+	var attrs map[string]any
+	if err := json.Unmarshal([]byte(t.text), &attrs); err != nil {
+		return fmt.Errorf("invalid attribute %q: %w", t.text, err)
+	}
+	for k, v := range attrs {
+		if k == "comment" {
+			a.Comment, _ = v.(string)
+			continue
+		}
+		if v == nil {
+			delete(a.Attributes, k)
+			continue
+		}
+		if a.Attributes == nil {
+			a.Attributes = make(map[string]any)
+		}
+		a.Attributes[k] = v
+	}
+	if len(a.Attributes) == 0 {
+		a.Attributes = nil
+	}
+	return nil
+}
+
+func (p *parser) require() string {
+	if p.isWords("NONE") || p.isWords("SSL") || p.isWords("X509") {
+		return strings.ToUpper(p.next().text)
+	}
+	var parts []string
+	for p.isWords("CIPHER") || p.isWords("ISSUER") || p.isWords("SUBJECT") {
+		kw := strings.ToUpper(p.next().text)
+		parts = append(parts, kw+" "+QuoteString(p.next().text))
+		p.acceptWords("AND")
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// passwordExpire returns DEFAULT, NEVER, INTERVAL n DAY or NOW for a bare
+// PASSWORD EXPIRE (password already expired)
+func (p *parser) passwordExpire() string {
+	switch {
+	case p.acceptWords("DEFAULT"):
+		return "DEFAULT"
+	case p.acceptWords("NEVER"):
+		return "NEVER"
+	case p.acceptWords("INTERVAL"):
+		n := p.next().text
+		p.acceptWords("DAY")
+		return "INTERVAL " + n + " DAY"
+	}
+	return "NOW"
+}
+
+// GrantStatement is a parsed GRANT or REVOKE statement
+type GrantStatement struct {
+	Revoke    bool
+	RevokeAll bool        // REVOKE ALL PRIVILEGES, GRANT OPTION FROM ...
+	Grant     *Grant      // privilege grant, nil for role grants
+	Roles     []RoleGrant // role grants
+	Grantees  []Name
+	// Identified is the authentication set by GRANT ... IDENTIFIED, which
+	// MySQL 5.7 and MariaDB accept and older servers print in SHOW GRANTS
+	Identified *Factor
+}
+
+// Name is an unquoted account name
+type Name struct {
+	User string
+	Host string
+}
+
+// ID returns the quoted form of the name
+func (n Name) ID() string {
+	return Quote(n.User, n.Host)
+}
+
+// ParseName parses an account name given on the command line. Both quoted
+// ('app'@'10.%') and bare (app@10.%) forms are accepted and the host
+// defaults to %.
+func ParseName(s string) (Name, error) {
+	if !strings.ContainsAny(s, "'\"`") {
+		user, host := s, "%"
+		if i := strings.LastIndexByte(s, '@'); i >= 0 {
+			user, host = s[:i], s[i+1:]
+		}
+		if user == "" {
+			return Name{}, fmt.Errorf("invalid account name %q", s)
+		}
+		return Name{User: user, Host: host}, nil
+	}
+
+	p, err := newParser(s)
+	if err != nil {
+		return Name{}, err
+	}
+	user, host, err := p.accountName()
+	if err != nil {
+		return Name{}, err
+	}
+	if !p.done() {
+		return Name{}, fmt.Errorf("invalid account name %q", s)
+	}
+	return Name{User: user, Host: host}, nil
+}
+
+// ParseGrant parses a GRANT or REVOKE statement as printed by SHOW GRANTS
+func ParseGrant(stmt string) (*GrantStatement, error) {
+	p, err := newParser(stmt)
+	if err != nil {
+		return nil, err
+	}
+	return p.grantStatement()
+}
+
+// ParseDefaultRole parses a SET DEFAULT ROLE statement, which MariaDB's SHOW
+// GRANTS prints, into the default roles and the accounts they are set for
+func ParseDefaultRole(stmt string) ([]string, []Name, error) {
+	p, err := newParser(stmt)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := p.expectWords("SET", "DEFAULT", "ROLE"); err != nil {
+		return nil, nil, err
+	}
+	return p.defaultRole()
+}
+
+// defaultRole parses the rest of SET DEFAULT ROLE: MySQL's list of roles TO
+// accounts or MariaDB's single role FOR an account. NONE and ALL return no
+// roles.
+func (p *parser) defaultRole() ([]string, []Name, error) {
+	var roles []string
+	if !p.acceptWords("NONE") && !p.acceptWords("ALL") {
+		var err error
+		if roles, err = p.accountList(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if !p.acceptWords("TO") && !p.acceptWords("FOR") {
+		return nil, nil, fmt.Errorf("expected TO or FOR near %q", p.peek().text)
+	}
+	var names []Name
+	for {
+		user, host, err := p.accountName()
+		if err != nil {
+			return nil, nil, err
+		}
+		names = append(names, Name{user, host})
+		if !p.acceptPunct(",") {
+			return roles, names, nil
+		}
+	}
+}
+
+func (p *parser) grantStatement() (*GrantStatement, error) {
+	gs := &GrantStatement{}
+	switch {
+	case p.acceptWords("GRANT"):
+	case p.acceptWords("REVOKE"):
+		gs.Revoke = true
+		p.acceptWords("IF", "EXISTS")
+		gs.RevokeAll = p.revokeAll()
+	default:
+		return nil, fmt.Errorf("expected GRANT or REVOKE near %q", p.peek().text)
+	}
+	if gs.RevokeAll {
+		if err := p.expectWords("FROM"); err != nil {
+			return nil, err
+		}
+		return gs, p.grantees(gs)
+	}
+
+	items := p.grantItems()
+	if p.acceptWords("ON") {
+		g, err := p.privilegeGrant(items)
+		if err != nil {
+			return nil, err
+		}
+		gs.Grant = g
+	} else {
+		for _, item := range items {
+			ip := &parser{toks: item}
+			user, host, err := ip.accountName()
+			if err != nil {
+				return nil, err
+			}
+			gs.Roles = append(gs.Roles, RoleGrant{Role: Quote(user, host)})
+		}
+	}
+
+	if !p.acceptWords("TO") && !p.acceptWords("FROM") {
+		return nil, fmt.Errorf("expected TO or FROM near %q", p.peek().text)
+	}
+	if err := p.grantees(gs); err != nil {
+		return nil, err
+	}
+
+	for !p.done() {
+		switch {
+		case p.acceptWords("WITH", "GRANT", "OPTION"):
+			if gs.Grant != nil {
+				gs.Grant.GrantOption = true
+			}
+		case p.acceptWords("WITH", "ADMIN", "OPTION"):
+			for i := range gs.Roles {
+				gs.Roles[i].AdminOption = true
+			}
+		case p.acceptWords("IDENTIFIED"):
+			var f Factor
+			if err := p.identified(&f.Plugin, &f.AuthString); err != nil {
+				return nil, err
+			}
+			if f.Plugin != "" {
+				gs.Identified = &f
+			}
+		default:
+			p.next()
+		}
+	}
+	return gs, nil
+}
+
+// revokeAll consumes ALL [PRIVILEGES], GRANT OPTION if it is next
+func (p *parser) revokeAll() bool {
+	start := p.pos
+	if p.acceptWords("ALL") {
+		p.acceptWords("PRIVILEGES")
+		if p.acceptPunct(",") && p.acceptWords("GRANT", "OPTION") && p.isWords("FROM") {
+			return true
+		}
+	}
+	p.pos = start
+	return false
+}
+
+func (p *parser) grantees(gs *GrantStatement) error {
+	for {
+		user, host, err := p.accountName()
+		if err != nil {
+			return err
+		}
+		gs.Grantees = append(gs.Grantees, Name{user, host})
+		if !p.acceptPunct(",") {
+			return nil
+		}
+	}
+}
+
+// grantItems collects the comma separated items between GRANT and ON/TO/FROM
+func (p *parser) grantItems() [][]token {
+	var items [][]token
+	var cur []token
+	depth := 0
+	for !p.done() {
+		if depth == 0 && (p.isWords("ON") || p.isWords("TO") || p.isWords("FROM")) {
+			break
+		}
+		t := p.next()
+		switch {
+		case t.kind == tokPunct && t.text == "(":
+			depth++
+		case t.kind == tokPunct && t.text == ")":
+			depth--
+		case t.kind == tokPunct && t.text == "," && depth == 0:
+			items = append(items, cur)
+			cur = nil
+			continue
+		}
+		cur = append(cur, t)
+	}
+	if len(cur) > 0 {
+		items = append(items, cur)
+	}
+	return items
+}
+
+// privilegeGrant builds a Grant from the privilege items and the level after ON
+func (p *parser) privilegeGrant(items [][]token) (*Grant, error) {
+	g := &Grant{}
+	for _, item := range items {
+		var words []string
+		var priv Privilege
+		inCols := false
+		for _, t := range item {
+			switch {
+			case t.kind == tokPunct && t.text == "(":
+				inCols = true
+			case t.kind == tokPunct && (t.text == ")" || t.text == ","):
+			case inCols:
+				priv.Columns = append(priv.Columns, t.text)
+			default:
+				words = append(words, strings.ToUpper(t.text))
+			}
+		}
+		priv.Name = strings.Join(words, " ")
+		if priv.Name == "ALL" {
+			priv.Name = "ALL PRIVILEGES"
+		}
+		g.Privileges = append(g.Privileges, priv)
+	}
+
+	if len(g.Privileges) == 1 && g.Privileges[0].Name == "PROXY" {
+		user, host, err := p.accountName()
+		if err != nil {
+			return nil, err
+		}
+		g.ObjectType = "PROXY"
+		g.Object = Quote(user, host)
+		return g, nil
+	}
+
+	for _, typ := range []string{"TABLE", "FUNCTION", "PROCEDURE"} {
+		if p.acceptWords(typ) {
+			g.ObjectType = typ
+		}
+	}
+	schema, err := p.levelPart()
+	if err != nil {
+		return nil, err
+	}
+	if !p.acceptPunct(".") {
+		return nil, fmt.Errorf("unqualified privilege level %q is not supported", schema)
+	}
+	object, err := p.levelPart()
+	if err != nil {
+		return nil, err
+	}
+	g.Schema, g.Object = schema, object
+	return g, nil
+}
+
+func (p *parser) levelPart() (string, error) {
+	if p.acceptPunct("*") {
+		return "*", nil
+	}
+	return p.name()
+}
+
+// Apply records a statement printed by SHOW GRANTS on the account. REVOKE
+// lines in that output are partial revokes.
+func (a *Account) Apply(gs *GrantStatement) {
+	if gs.Identified != nil && !gs.Revoke {
+		a.Plugin, a.AuthString = gs.Identified.Plugin, gs.Identified.AuthString
+	}
+	switch {
+	case gs.RevokeAll:
+		a.Grants = nil
+		a.Revokes = nil
+	case gs.Grant != nil && gs.Revoke:
+		a.Revokes = append(a.Revokes, *gs.Grant)
+	case gs.Grant != nil:
+		a.Grants = append(a.Grants, *gs.Grant)
+	case gs.Revoke:
+		var kept []RoleGrant
+		for _, r := range a.Roles {
+			if !containsRole(gs.Roles, r.Role) {
+				kept = append(kept, r)
+			}
+		}
+		a.Roles = kept
+	default:
+		a.Roles = append(a.Roles, gs.Roles...)
+	}
+}
+
+func containsRole(roles []RoleGrant, role string) bool {
+	for _, r := range roles {
+		if r.Role == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,332 @@
+package account
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCreateUser(t *testing.T) {
+	a, err := ParseCreateUser("CREATE USER `flyway`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24412430303524 DEFAULT ROLE `app_read`@`%`,`app_write`@`%` REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK PASSWORD HISTORY DEFAULT PASSWORD REUSE INTERVAL DEFAULT PASSWORD REQUIRE CURRENT DEFAULT")
+	assert.NoError(t, err)
+	assert.Equal(t, "flyway", a.User)
+	assert.Equal(t, "%", a.Host)
+	assert.Equal(t, "caching_sha2_password", a.Plugin)
+	assert.Equal(t, "24412430303524", a.AuthString)
+	assert.Equal(t, []byte("$A$005$"), a.AuthBytes())
+	assert.Equal(t, []string{"`app_read`@`%`", "`app_write`@`%`"}, a.DefaultRoles)
+	assert.Equal(t, "NONE", a.Require)
+	assert.Equal(t, "DEFAULT", a.PasswordExpire)
+	assert.False(t, a.Locked)
+}
+
+func TestParseCreateUser_QuotedAuthString(t *testing.T) {
+	a, err := ParseCreateUser("CREATE USER 'legacy'@'10.%' IDENTIFIED WITH 'mysql_native_password' AS '*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19' REQUIRE SSL PASSWORD EXPIRE INTERVAL 90 DAY ACCOUNT LOCK")
+	assert.NoError(t, err)
+	assert.Equal(t, "`legacy`@`10.%`", a.ID())
+	assert.Equal(t, "*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19", string(a.AuthBytes()))
+	assert.Equal(t, "SSL", a.Require)
+	assert.Equal(t, "INTERVAL 90 DAY", a.PasswordExpire)
+	assert.True(t, a.Locked)
+}
+
+func TestParseCreateUser_RequireX509Subject(t *testing.T) {
+	a, err := ParseCreateUser("CREATE USER `repl`@`%` IDENTIFIED WITH 'caching_sha2_password' REQUIRE SUBJECT '/CN=repl' AND ISSUER '/CN=ca' PASSWORD EXPIRE NEVER ACCOUNT UNLOCK")
+	assert.NoError(t, err)
+	assert.Equal(t, "SUBJECT '/CN=repl' AND ISSUER '/CN=ca'", a.Require)
+	assert.Equal(t, "NEVER", a.PasswordExpire)
+	assert.Empty(t, a.AuthString)
+}
+
+func TestParseCreateUser_AllClauses(t *testing.T) {
+	stmt := "CREATE USER `app`@`10.%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24412430303524 DEFAULT ROLE `reader`@`%` REQUIRE SSL WITH MAX_QUERIES_PER_HOUR 1000 MAX_USER_CONNECTIONS 50 PASSWORD EXPIRE INTERVAL 90 DAY ACCOUNT UNLOCK PASSWORD HISTORY 5 PASSWORD REUSE INTERVAL 365 DAY PASSWORD REQUIRE CURRENT FAILED_LOGIN_ATTEMPTS 3 PASSWORD_LOCK_TIME UNBOUNDED ATTRIBUTE '{\"team\": \"payments\", \"comment\": \"orders service\"}'"
+	a, err := ParseCreateUser(stmt)
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, a.MaxQueriesPerHour)
+	assert.Equal(t, 0, a.MaxUpdatesPerHour)
+	assert.Equal(t, 50, a.MaxUserConnections)
+	assert.Equal(t, "INTERVAL 90 DAY", a.PasswordExpire)
+	assert.Equal(t, "5", a.PasswordHistory)
+	assert.Equal(t, "365", a.PasswordReuseInterval)
+	assert.Equal(t, "REQUIRED", a.PasswordRequireCurrent)
+	assert.Equal(t, 3, a.FailedLoginAttempts)
+	assert.Equal(t, "UNBOUNDED", a.PasswordLockTime)
+	assert.Equal(t, "orders service", a.Comment)
+	assert.Equal(t, map[string]any{"team": "payments"}, a.Attributes)
+
+	assert.Equal(t, "CREATE USER `app`@`10.%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24412430303524 REQUIRE SSL WITH MAX_QUERIES_PER_HOUR 1000 MAX_USER_CONNECTIONS 50 PASSWORD EXPIRE INTERVAL 90 DAY ACCOUNT UNLOCK PASSWORD HISTORY 5 PASSWORD REUSE INTERVAL 365 DAY PASSWORD REQUIRE CURRENT FAILED_LOGIN_ATTEMPTS 3 PASSWORD_LOCK_TIME UNBOUNDED ATTRIBUTE '{\"comment\":\"orders service\",\"team\":\"payments\"}'", a.CreateStatement())
+
+	// ALTER USER carries every clause except the default roles
+	alter, err := ParseSQL("CREATE USER IF NOT EXISTS `app`@`10.%`;\n" + a.AlterStatement() + ";")
+	assert.NoError(t, err)
+	want := *a
+	want.DefaultRoles = nil
+	assert.Equal(t, want, alter[0])
+	assert.Equal(t, "ALTER USER `app`@`10.%` DEFAULT ROLE `reader`@`%`", a.DefaultRoleStatement())
+}
+
+func TestParseCreateUser_Comment(t *testing.T) {
+	a, err := ParseCreateUser("CREATE USER `app`@`%` PASSWORD REQUIRE CURRENT OPTIONAL COMMENT 'legacy app'")
+	assert.NoError(t, err)
+	assert.Equal(t, "OPTIONAL", a.PasswordRequireCurrent)
+	assert.Equal(t, "legacy app", a.Comment)
+	assert.Nil(t, a.Attributes)
+	assert.Equal(t, "CREATE USER `app`@`%` ACCOUNT UNLOCK PASSWORD REQUIRE CURRENT OPTIONAL COMMENT 'legacy app'", a.CreateStatement())
+
+	_, err = ParseCreateUser("CREATE USER `app`@`%` ATTRIBUTE 'not json'")
+	assert.Error(t, err)
+	_, err = ParseCreateUser("CREATE USER `app`@`%` FAILED_LOGIN_ATTEMPTS many")
+	assert.Error(t, err)
+}
+
+func TestSetAttributes(t *testing.T) {
+	a := &Account{Comment: "stale", Attributes: map[string]any{"old": "x"}}
+	assert.NoError(t, a.SetAttributes(`{"comment": "legacy app", "team": "payments"}`))
+	assert.Equal(t, "legacy app", a.Comment)
+	assert.Equal(t, map[string]any{"team": "payments"}, a.Attributes)
+
+	assert.NoError(t, a.SetAttributes(`{}`))
+	assert.Empty(t, a.Comment)
+	assert.Nil(t, a.Attributes)
+	assert.Error(t, a.SetAttributes("not json"))
+}
+
+func TestParseCreateUser_Factors(t *testing.T) {
+	tests := []struct {
+		name    string
+		stmt    string
+		factors []Factor
+		modify  string
+	}{
+		{
+			name: "one factor",
+			stmt: "CREATE USER `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24412430303524 REQUIRE NONE",
+		},
+		{
+			name:    "two factors",
+			stmt:    "CREATE USER `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24412430303524 AND IDENTIFIED WITH 'authentication_ldap_sasl' AS 0x75696431 REQUIRE NONE",
+			factors: []Factor{{Plugin: "authentication_ldap_sasl", AuthString: "75696431"}},
+			modify:  "ALTER USER `app`@`%` MODIFY 2 FACTOR IDENTIFIED WITH 'authentication_ldap_sasl' AS 0x75696431",
+		},
+		{
+			name: "three factors",
+			stmt: "CREATE USER `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24412430303524 AND IDENTIFIED WITH 'authentication_ldap_sasl' AS 0x75696431 AND IDENTIFIED WITH 'authentication_fido' REQUIRE NONE",
+			factors: []Factor{
+				{Plugin: "authentication_ldap_sasl", AuthString: "75696431"},
+				{Plugin: "authentication_fido"},
+			},
+			modify: "ALTER USER `app`@`%` MODIFY 2 FACTOR IDENTIFIED WITH 'authentication_ldap_sasl' AS 0x75696431 MODIFY 3 FACTOR IDENTIFIED WITH 'authentication_fido'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := ParseCreateUser(tt.stmt)
+			assert.NoError(t, err)
+			assert.Equal(t, "caching_sha2_password", a.Plugin)
+			assert.Equal(t, "24412430303524", a.AuthString)
+			assert.Equal(t, tt.factors, a.Factors)
+			assert.Equal(t, "NONE", a.Require)
+			assert.Equal(t, tt.stmt+" ACCOUNT UNLOCK", a.CreateStatement())
+			assert.Equal(t, "ALTER USER `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24412430303524 REQUIRE NONE ACCOUNT UNLOCK", a.AlterStatement())
+			assert.Equal(t, tt.modify, a.FactorStatement("MODIFY"))
+		})
+	}
+}
+
+func TestParseCreateUser_InitialAuthentication(t *testing.T) {
+	a, err := ParseCreateUser("CREATE USER `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24 AND IDENTIFIED WITH 'authentication_fido' INITIAL AUTHENTICATION IDENTIFIED BY 'temp'")
+	assert.NoError(t, err)
+	assert.Equal(t, "caching_sha2_password", a.Plugin)
+	assert.Equal(t, "24", a.AuthString)
+	assert.Equal(t, []Factor{{Plugin: "authentication_fido"}}, a.Factors)
+}
+
+func TestParseCreateUser_Invalid(t *testing.T) {
+	_, err := ParseCreateUser("DROP USER `x`@`%`")
+	assert.Error(t, err)
+	_, err = ParseCreateUser("CREATE USER `x@`%`")
+	assert.Error(t, err)
+}
+
+func TestParseGrant(t *testing.T) {
+	tests := []struct {
+		name  string
+		stmt  string
+		level string
+		privs []Privilege
+		gopt  bool
+	}{
+		{
+			name:  "global",
+			stmt:  "GRANT SELECT, INSERT, SHOW DATABASES ON *.* TO `app`@`%`",
+			level: "*.*",
+			privs: []Privilege{{Name: "SELECT"}, {Name: "INSERT"}, {Name: "SHOW DATABASES"}},
+		},
+		{
+			name:  "dynamic privileges",
+			stmt:  "GRANT BACKUP_ADMIN,CLONE_ADMIN ON *.* TO `dba`@`localhost` WITH GRANT OPTION",
+			level: "*.*",
+			privs: []Privilege{{Name: "BACKUP_ADMIN"}, {Name: "CLONE_ADMIN"}},
+			gopt:  true,
+		},
+		{
+			name:  "schema",
+			stmt:  "GRANT ALL ON `pay\\_%`.* TO `app`@`%`",
+			level: "`pay\\_%`.*",
+			privs: []Privilege{{Name: "ALL PRIVILEGES"}},
+		},
+		{
+			name:  "columns",
+			stmt:  "GRANT SELECT (`id`, `name`), UPDATE (`name`) ON `shop`.`customers` TO `app`@`%`",
+			level: "`shop`.`customers`",
+			privs: []Privilege{{Name: "SELECT", Columns: []string{"id", "name"}}, {Name: "UPDATE", Columns: []string{"name"}}},
+		},
+		{
+			name:  "routine",
+			stmt:  "GRANT EXECUTE ON PROCEDURE `shop`.`refund` TO `app`@`%`",
+			level: "PROCEDURE `shop`.`refund`",
+			privs: []Privilege{{Name: "EXECUTE"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gs, err := ParseGrant(tt.stmt)
+			assert.NoError(t, err)
+			assert.NotNil(t, gs.Grant)
+			assert.Equal(t, tt.level, gs.Grant.Level())
+			assert.Equal(t, tt.privs, gs.Grant.Privileges)
+			assert.Equal(t, tt.gopt, gs.Grant.GrantOption)
+
+			// rendering and parsing again must be lossless
+			again, err := ParseGrant(gs.Grant.Statement(gs.Grantees[0].ID()))
+			assert.NoError(t, err)
+			assert.Equal(t, gs, again)
+		})
+	}
+}
+
+func TestParseGrant_Roles(t *testing.T) {
+	gs, err := ParseGrant("GRANT `app_read`@`%`,`app_write`@`%` TO `app`@`%` WITH ADMIN OPTION")
+	assert.NoError(t, err)
+	assert.Nil(t, gs.Grant)
+	assert.Equal(t, []RoleGrant{{Role: "`app_read`@`%`", AdminOption: true}, {Role: "`app_write`@`%`", AdminOption: true}}, gs.Roles)
+	assert.Equal(t, []Name{{User: "app", Host: "%"}}, gs.Grantees)
+}
+
+func TestParseGrant_PartialRevoke(t *testing.T) {
+	gs, err := ParseGrant("REVOKE INSERT, UPDATE ON `mysql`.* FROM `app`@`%`")
+	assert.NoError(t, err)
+	assert.True(t, gs.Revoke)
+
+	a := &Account{User: "app", Host: "%"}
+	a.Apply(gs)
+	assert.Empty(t, a.Grants)
+	assert.Len(t, a.Revokes, 1)
+	assert.Equal(t, "REVOKE INSERT, UPDATE ON `mysql`.* FROM `app`@`%`", a.Revokes[0].RevokeStatement(a.ID()))
+}
+
+func TestParseGrant_Proxy(t *testing.T) {
+	gs, err := ParseGrant("GRANT PROXY ON ``@`` TO `root`@`localhost` WITH GRANT OPTION")
+	assert.NoError(t, err)
+	assert.Equal(t, "PROXY", gs.Grant.ObjectType)
+	assert.Equal(t, "``@``", gs.Grant.Level())
+	assert.True(t, gs.Grant.GrantOption)
+}
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Name
+	}{
+		{"app", Name{"app", "%"}},
+		{"app@10.%", Name{"app", "10.%"}},
+		{"me@example.com@localhost", Name{"me@example.com", "localhost"}},
+		{"'app'@'10.%'", Name{"app", "10.%"}},
+		{"`app`@`%`", Name{"app", "%"}},
+		{"'o''brien'", Name{"o'brien", "%"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseName(tt.in)
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	for _, in := range []string{"", "@localhost", "'app'@'%' x", "'app"} {
+		_, err := ParseName(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestPrivilegeSet(t *testing.T) {
+	grants := []Grant{
+		{Privileges: []Privilege{{Name: "USAGE"}}, Schema: "*", Object: "*"},
+		{Privileges: []Privilege{{Name: "SELECT"}, {Name: "UPDATE", Columns: []string{"a", "b"}}}, Schema: "db", Object: "t", GrantOption: true},
+	}
+	set := PrivilegeSet(grants)
+	assert.NotContains(t, set, "*.*")
+	assert.Equal(t, []string{"GRANT OPTION", "SELECT", "UPDATE (`a`)", "UPDATE (`b`)"}, SortedKeys(set["`db`.`t`"]))
+}
+
+func TestParseCreateUser_MariaDB(t *testing.T) {
+	a, err := ParseCreateUser(mariaDBApp)
+	assert.NoError(t, err)
+	assert.Equal(t, "ed25519", a.Plugin)
+	assert.Equal(t, "ZIgUREUg5PVgQ6LskhXmO+eZLS0nC8be6HPjYWR4YJY", string(a.AuthBytes()))
+	assert.Equal(t, []Factor{{Plugin: "unix_socket"}}, a.Alternatives)
+	assert.Empty(t, a.Factors)
+	assert.Equal(t, "SSL", a.Require)
+	assert.Equal(t, 20, a.MaxUserConnections)
+	assert.Equal(t, "30.000000", a.MaxStatementTime)
+	assert.Equal(t, "INTERVAL 90 DAY", a.PasswordExpire)
+}
+
+func TestParseGrant_MariaDB(t *testing.T) {
+	// MariaDB prints the authentication and limits on the USAGE grant
+	gs, err := ParseGrant("GRANT USAGE ON *.* TO `app`@`%` IDENTIFIED VIA ed25519 USING 'ZIgUREUg5PVgQ6LskhXmO+eZLS0nC8be6HPjYWR4YJY' OR unix_socket REQUIRE SSL WITH MAX_USER_CONNECTIONS 20")
+	assert.NoError(t, err)
+	assert.Equal(t, "USAGE", gs.Grant.Privileges[0].Name)
+	assert.Equal(t, []Name{{"app", "%"}}, gs.Grantees)
+	assert.False(t, gs.Grant.GrantOption)
+
+	// roles have no host
+	gs, err = ParseGrant("GRANT `app_read` TO `app`@`%` WITH ADMIN OPTION")
+	assert.NoError(t, err)
+	assert.Equal(t, []RoleGrant{{Role: "`app_read`@`%`", AdminOption: true}}, gs.Roles)
+
+	gs, err = ParseGrant("GRANT SELECT ON `shop`.* TO `app_read`")
+	assert.NoError(t, err)
+	assert.Equal(t, []Name{{"app_read", "%"}}, gs.Grantees)
+}
+
+func TestParseDefaultRole(t *testing.T) {
+	roles, names, err := ParseDefaultRole("SET DEFAULT ROLE `app_read` FOR `app`@`%`")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"`app_read`@`%`"}, roles)
+	assert.Equal(t, []Name{{"app", "%"}}, names)
+
+	roles, names, err = ParseDefaultRole("SET DEFAULT ROLE `r1`@`%`, `r2`@`%` TO `app`@`%`, `web`@`%`")
+	assert.NoError(t, err)
+	assert.Len(t, roles, 2)
+	assert.Len(t, names, 2)
+
+	_, _, err = ParseDefaultRole("SET DEFAULT ROLE `app_read`")
+	assert.Error(t, err)
+}
+
+func TestParseGrant_Identified(t *testing.T) {
+	// MySQL 5.6 and early 5.7 print the password hash on the USAGE grant
+	gs, err := ParseGrant("GRANT USAGE ON *.* TO 'legacy'@'10.%' IDENTIFIED BY PASSWORD '*6BB4837EB74329105EE4568DDA7DC67ED2CA2AD9'")
+	assert.NoError(t, err)
+	assert.Equal(t, &Factor{Plugin: "mysql_native_password", AuthString: "2A36424234383337454237343332393130354545343536384444413744433637454432434132414439"}, gs.Identified)
+
+	var a Account
+	a.Apply(gs)
+	assert.Equal(t, "mysql_native_password", a.Plugin)
+	assert.Equal(t, "*6BB4837EB74329105EE4568DDA7DC67ED2CA2AD9", string(a.AuthBytes()))
+
+	// a cleartext password leaves the hash unknown
+	gs, err = ParseGrant("GRANT SELECT ON `shop`.* TO `app`@`%` IDENTIFIED BY 'secret'")
+	assert.NoError(t, err)
+	assert.Nil(t, gs.Identified)
+}
@@ -0,0 +1,53 @@
+package account
+
+import "strings"
+
+// Like matches s against a LIKE pattern, as used in account hosts and the
+// schema names of grants: % matches any run of characters, _ a single one,
+// and \ escapes the character after it. Comparison is case sensitive; host
+// names are lower-cased by the caller.
+func Like(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch c := pattern[0]; c {
+		case '%':
+			for i := 0; i <= len(s); i++ {
+				if Like(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '_':
+			if s == "" {
+				return false
+			}
+		default:
+			if c == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+				c = pattern[0]
+			}
+			if s == "" || s[0] != c {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return s == ""
+}
+
+// LiteralPrefix returns the part of a LIKE pattern before its first
+// wildcard, with escapes removed
+func LiteralPrefix(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '%', '_':
+			return b.String()
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+		}
+		b.WriteByte(pattern[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,38 @@
+package account
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLike(t *testing.T) {
+	for _, c := range []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"%", "", true},
+		{"%", "anything", true},
+		{"", "", true},
+		{"", "x", false},
+		{"10.0.0._", "10.0.0.5", true},
+		{"10.0.0._", "10.0.0.55", false},
+		{"10.%", "110.0.0.1", false},
+		{"db-%.example.com", "db-1.example.com", true},
+		{`pay\_%`, "pay_eu", true},
+		{`pay\_%`, "payeu", false},
+		{`pay\%`, "pay%", true},
+		{`pay\%`, "pay_", false},
+		{"Shop", "shop", false},
+	} {
+		assert.Equal(t, c.match, Like(c.pattern, c.s), "%s %s", c.pattern, c.s)
+	}
+}
+
+func TestLiteralPrefix(t *testing.T) {
+	assert.Equal(t, "10.1.", LiteralPrefix("10.1.%"))
+	assert.Equal(t, "pay_", LiteralPrefix(`pay\_%`))
+	assert.Equal(t, "db-", LiteralPrefix("db-_.example.com"))
+	assert.Equal(t, "localhost", LiteralPrefix("localhost"))
+	assert.Equal(t, "", LiteralPrefix("%"))
+}
@@ -0,0 +1,203 @@
+package account
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/sqlsplit"
+)
+
+// ParseSQL replays a SQL file written by go-pass (import or pt-like format)
+// and returns the accounts it describes. CREATE USER, CREATE ROLE, ALTER
+// USER, GRANT, SET DEFAULT ROLE, DROP USER and DROP ROLE statements are
+// understood; REVOKE lines are treated as partial revokes, as printed by
+// SHOW GRANTS. Anything else, such as SHOW statements, is ignored.
+func ParseSQL(data string) ([]Account, error) {
+	statements, err := sqlsplit.Split(data)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*Account)
+	var order []string
+	get := func(user, host string) *Account {
+		id := Quote(user, host)
+		if a, ok := byID[id]; ok {
+			return a
+		}
+		byID[id] = &Account{User: user, Host: host}
+		order = append(order, id)
+		return byID[id]
+	}
+
+	for n, stmt := range statements {
+		p, err := newParser(stmt)
+		if err == nil {
+			err = p.replay(byID, get)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("statement %d: %w", n+1, err)
+		}
+	}
+
+	accounts := make([]Account, 0, len(order))
+	for _, id := range order {
+		if a, ok := byID[id]; ok {
+			accounts = append(accounts, *a)
+		}
+	}
+	Sort(accounts)
+	return accounts, nil
+}
+
+// replay applies a single statement to the accounts collected so far
+func (p *parser) replay(byID map[string]*Account, get func(user, host string) *Account) error {
+	switch {
+	case p.isWords("CREATE", "USER"):
+		ifNotExists := p.isWords("CREATE", "USER", "IF", "NOT", "EXISTS")
+		a, err := p.createUser()
+		if err != nil {
+			return err
+		}
+		if _, ok := byID[a.ID()]; ok && ifNotExists {
+			return nil
+		}
+		*get(a.User, a.Host) = *a
+	case p.acceptWords("ALTER", "USER"):
+		p.acceptWords("IF", "EXISTS")
+		user, host, err := p.accountName()
+		if err != nil {
+			return err
+		}
+		return p.userOptions(get(user, host))
+	case p.isWords("GRANT"), p.isWords("REVOKE"):
+		gs, err := p.grantStatement()
+		if err != nil {
+			return err
+		}
+		for _, g := range gs.Grantees {
+			get(g.User, g.Host).Apply(gs)
+		}
+	case p.acceptWords("CREATE", "ROLE"):
+		ifNotExists := p.acceptWords("IF", "NOT", "EXISTS")
+		for {
+			start := p.pos
+			user, host, err := p.accountName()
+			if err != nil {
+				return err
+			}
+			if _, ok := byID[Quote(user, host)]; !ok || !ifNotExists {
+				// a role without a host is a MariaDB role; MySQL creates
+				// roles as locked accounts
+				*get(user, host) = Account{User: user, Host: host, Role: p.pos-start == 1, Locked: true}
+			}
+			if !p.acceptPunct(",") {
+				break
+			}
+		}
+	case p.acceptWords("SET", "DEFAULT", "ROLE"):
+		roles, names, err := p.defaultRole()
+		if err != nil {
+			return err
+		}
+		for _, n := range names {
+			get(n.User, n.Host).DefaultRoles = roles
+		}
+	case p.acceptWords("DROP", "USER"), p.acceptWords("DROP", "ROLE"):
+		p.acceptWords("IF", "EXISTS")
+		for {
+			user, host, err := p.accountName()
+			if err != nil {
+				return err
+			}
+			delete(byID, Quote(user, host))
+			if !p.acceptPunct(",") {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// FilterUser returns only the accounts whose user name is user. An empty user
+// returns every account except MySQL's internal system accounts, matching the
+// accounts go-pass reads from a server.
+func FilterUser(accounts []Account, user string) []Account {
+	var out []Account
+	for _, a := range accounts {
+		if user != "" && a.User != user {
+			continue
+		}
+		if user == "" && isSystemUser(a.User) {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+func isSystemUser(user string) bool {
+	switch strings.ToLower(user) {
+	case "mysql.infoschema", "mysql.session", "mysql.sys", "mariadb.sys":
+		return true
+	}
+	return false
+}
+
+// StatementAccounts returns the accounts a statement modifies: the accounts of
+// CREATE, ALTER, DROP and RENAME USER and the grantees of GRANT, REVOKE and
+// SET DEFAULT ROLE. Other statements, or ones that cannot be parsed, return nil.
+func StatementAccounts(stmt string) []Name {
+	p, err := newParser(stmt)
+	if err != nil {
+		return nil
+	}
+	list := func() []Name {
+		var names []Name
+		for {
+			user, host, err := p.accountName()
+			if err != nil {
+				return names
+			}
+			names = append(names, Name{user, host})
+			if !p.acceptPunct(",") {
+				return names
+			}
+		}
+	}
+
+	switch {
+	case p.acceptWords("CREATE", "USER"), p.acceptWords("ALTER", "USER"):
+		p.acceptWords("IF", "NOT", "EXISTS")
+		p.acceptWords("IF", "EXISTS")
+		user, host, err := p.accountName()
+		if err != nil {
+			return nil
+		}
+		return []Name{{user, host}}
+	case p.acceptWords("CREATE", "ROLE"), p.acceptWords("DROP", "USER"), p.acceptWords("DROP", "ROLE"):
+		p.acceptWords("IF", "NOT", "EXISTS")
+		p.acceptWords("IF", "EXISTS")
+		return list()
+	case p.acceptWords("RENAME", "USER"):
+		var names []Name
+		for {
+			names = append(names, list()...)
+			if !p.acceptWords("TO") {
+				return names
+			}
+		}
+	case p.isWords("GRANT"), p.isWords("REVOKE"):
+		gs, err := p.grantStatement()
+		if err != nil {
+			return nil
+		}
+		return gs.Grantees
+	case p.acceptWords("SET", "DEFAULT", "ROLE"):
+		for !p.done() && !p.acceptWords("TO") && !p.acceptWords("FOR") {
+			p.next()
+		}
+		return list()
+	}
+	return nil
+}
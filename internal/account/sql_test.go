@@ -0,0 +1,181 @@
+package account
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSQL_Import(t *testing.T) {
+	data := "-- CREATE USER IF NOT EXISTS for flyway@%: \n" +
+		"CREATE USER IF NOT EXISTS `flyway`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x2441243030352400 REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK;\n" +
+		"GRANT SELECT, INSERT ON *.* TO `flyway`@`%`;\n" +
+		"GRANT BACKUP_ADMIN ON *.* TO `flyway`@`%`;\n" +
+		"-- CREATE USER IF NOT EXISTS for app@10.%: \n" +
+		"CREATE USER IF NOT EXISTS `app`@`10.%` IDENTIFIED WITH 'mysql_native_password' AS '*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19; not a terminator' REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT LOCK;\n" +
+		"GRANT USAGE ON *.* TO `app`@`10.%`;\n" +
+		"GRANT `reader`@`%` TO `app`@`10.%`;\n" +
+		"REVOKE INSERT ON `mysql`.* FROM `flyway`@`%`;\n"
+
+	accounts, err := ParseSQL(data)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 2)
+
+	app, flyway := accounts[0], accounts[1]
+	assert.Equal(t, "`app`@`10.%`", app.ID())
+	assert.True(t, app.Locked)
+	assert.Equal(t, "*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19; not a terminator", string(app.AuthBytes()))
+	assert.Equal(t, []RoleGrant{{Role: "`reader`@`%`"}}, app.Roles)
+
+	assert.Equal(t, "2441243030352400", flyway.AuthString)
+	assert.Len(t, flyway.Grants, 2)
+	assert.Len(t, flyway.Revokes, 1)
+}
+
+func TestParseSQL_PtLike(t *testing.T) {
+	data := `-- Grants dumped by go-pass
+-- Grants for 'app'@'%'
+CREATE USER IF NOT EXISTS ` + "`app`@`%`" + `;
+ALTER USER ` + "`app`@`%`" + ` IDENTIFIED WITH 'caching_sha2_password' AS 0x24 REQUIRE NONE PASSWORD EXPIRE NEVER ACCOUNT UNLOCK;
+ALTER USER ` + "`app`@`%`" + ` DEFAULT ROLE ` + "`reader`@`%`" + `;
+GRANT SELECT ON ` + "`shop`.*" + ` TO ` + "`app`@`%`" + `;
+# replayed statements may also drop accounts
+CREATE USER ` + "`gone`@`%`" + `;
+DROP USER IF EXISTS ` + "`gone`@`%`" + `;
+SET DEFAULT ROLE NONE TO ` + "`app`@`%`" + `;
+`
+	accounts, err := ParseSQL(data)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 1)
+	assert.Equal(t, "caching_sha2_password", accounts[0].Plugin)
+	assert.Equal(t, "24", accounts[0].AuthString)
+	assert.Equal(t, "NEVER", accounts[0].PasswordExpire)
+	assert.Empty(t, accounts[0].DefaultRoles)
+	assert.Equal(t, "`shop`.*", accounts[0].Grants[0].Level())
+}
+
+func TestParseSQL_MariaDB(t *testing.T) {
+	data := "-- CREATE ROLE IF NOT EXISTS for app_read: \n" +
+		"CREATE ROLE IF NOT EXISTS `app_read`;\n" +
+		"GRANT USAGE ON *.* TO `app_read`;\n" +
+		"GRANT SELECT ON `shop`.* TO `app_read`;\n" +
+		"-- CREATE USER IF NOT EXISTS for app@%: \n" +
+		"CREATE USER IF NOT EXISTS `app`@`%` IDENTIFIED VIA ed25519 USING 'ZIgUREUg5PVgQ6LskhXmO+eZLS0nC8be6HPjYWR4YJY';\n" +
+		"GRANT USAGE ON *.* TO `app`@`%` IDENTIFIED VIA ed25519 USING 'ZIgUREUg5PVgQ6LskhXmO+eZLS0nC8be6HPjYWR4YJY';\n" +
+		"GRANT `app_read` TO `app`@`%`;\n" +
+		"SET DEFAULT ROLE `app_read` FOR `app`@`%`;\n" +
+		"CREATE ROLE `gone`;\n" +
+		"DROP ROLE `gone`;\n"
+
+	accounts, err := ParseSQL(data)
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 2)
+
+	app, role := accounts[0], accounts[1]
+	assert.Equal(t, "ed25519", app.Plugin)
+	assert.Equal(t, []RoleGrant{{Role: "`app_read`@`%`"}}, app.Roles)
+	assert.Equal(t, []string{"`app_read`@`%`"}, app.DefaultRoles)
+	assert.False(t, app.Role)
+
+	assert.Equal(t, "`app_read`@`%`", role.ID())
+	assert.True(t, role.Role)
+	assert.Equal(t, "GRANT SELECT ON `shop`.* TO `app_read`", role.Grants[1].Statement(MariaDB.Grantee(&role)))
+}
+
+func TestParseSQL_AlterAttributes(t *testing.T) {
+	data := "CREATE USER `app`@`%` ATTRIBUTE '{\"team\": \"payments\", \"tier\": 1}';\n" +
+		"ALTER USER `app`@`%` ATTRIBUTE '{\"tier\": null, \"owner\": \"ops\"}';\n" +
+		"ALTER USER `app`@`%` WITH MAX_USER_CONNECTIONS 10 COMMENT 'orders service';\n"
+	accounts, err := ParseSQL(data)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"team": "payments", "owner": "ops"}, accounts[0].Attributes)
+	assert.Equal(t, "orders service", accounts[0].Comment)
+	assert.Equal(t, 10, accounts[0].MaxUserConnections)
+}
+
+func TestParseSQL_AlterFactors(t *testing.T) {
+	create := "CREATE USER `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24;\n"
+	tests := []struct {
+		name    string
+		alter   string
+		factors []Factor
+	}{
+		{"add two", "ALTER USER `app`@`%` ADD 2 FACTOR IDENTIFIED WITH 'authentication_ldap_sasl' AS 0x01 ADD 3 FACTOR IDENTIFIED WITH 'authentication_fido'",
+			[]Factor{{Plugin: "authentication_ldap_sasl", AuthString: "01"}, {Plugin: "authentication_fido"}}},
+		{"modify", "ALTER USER `app`@`%` ADD 2 FACTOR IDENTIFIED WITH 'authentication_fido';\nALTER USER `app`@`%` MODIFY 2 FACTOR IDENTIFIED WITH 'authentication_ldap_sasl' AS 0x02",
+			[]Factor{{Plugin: "authentication_ldap_sasl", AuthString: "02"}}},
+		{"drop both", "ALTER USER `app`@`%` ADD 2 FACTOR IDENTIFIED WITH 'authentication_ldap_sasl' ADD 3 FACTOR IDENTIFIED WITH 'authentication_fido';\nALTER USER `app`@`%` DROP 2 FACTOR DROP 3 FACTOR",
+			nil},
+		{"drop second of three", "ALTER USER `app`@`%` ADD 2 FACTOR IDENTIFIED WITH 'authentication_ldap_sasl' ADD 3 FACTOR IDENTIFIED WITH 'authentication_fido';\nALTER USER `app`@`%` DROP 2 FACTOR",
+			[]Factor{{Plugin: "authentication_fido"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts, err := ParseSQL(create + tt.alter + ";")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.factors, accounts[0].Factors)
+			assert.Equal(t, "24", accounts[0].AuthString)
+		})
+	}
+
+	_, err := ParseSQL(create + "ALTER USER `app`@`%` MODIFY 2 FACTOR IDENTIFIED WITH 'authentication_fido';")
+	assert.ErrorContains(t, err, "cannot modify factor 2 of 1")
+}
+
+func TestParseSQL_CommentsAndDelimiter(t *testing.T) {
+	data := "/* accounts; exported by hand */\n" +
+		"DELIMITER $$\n" +
+		"CREATE USER `app`@`%` # trailing; comment\n" +
+		"  IDENTIFIED WITH 'mysql_native_password' AS 'a;b'$$\n" +
+		"DELIMITER ;\n" +
+		"GRANT SELECT ON `db`.* TO `app`@`%`; -- done\n"
+
+	accounts, err := ParseSQL(data)
+	assert.NoError(t, err)
+	if assert.Len(t, accounts, 1) {
+		assert.Equal(t, "a;b", string(accounts[0].AuthBytes()))
+		assert.Len(t, accounts[0].Grants, 1)
+	}
+}
+
+func TestParseSQL_IgnoresRawFormat(t *testing.T) {
+	accounts, err := ParseSQL("SHOW CREATE USER `app`@`%`; SHOW GRANTS FOR `app`@`%`;\n")
+	assert.NoError(t, err)
+	assert.Empty(t, accounts)
+}
+
+func TestParseSQL_Error(t *testing.T) {
+	_, err := ParseSQL("CREATE USER `ok`@`%`;\nGRANT SELECT ON db TO `ok`@`%`;")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "statement 2")
+}
+
+func TestFilterUser(t *testing.T) {
+	accounts := []Account{{User: "app"}, {User: "mysql.sys"}, {User: "mariadb.sys"}, {User: "root"}}
+	assert.Len(t, FilterUser(accounts, ""), 2)
+	assert.Equal(t, []Account{{User: "root"}}, FilterUser(accounts, "root"))
+}
+
+func TestStatementAccounts(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want []Name
+	}{
+		{"CREATE USER IF NOT EXISTS `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24", []Name{{"app", "%"}}},
+		{"ALTER USER IF EXISTS 'app'@'10.%' ACCOUNT LOCK", []Name{{"app", "10.%"}}},
+		{"DROP USER IF EXISTS `a`@`%`, `b`@`localhost`", []Name{{"a", "%"}, {"b", "localhost"}}},
+		{"RENAME USER `a`@`%` TO `b`@`%`, `c`@`%` TO `d`@`%`", []Name{{"a", "%"}, {"b", "%"}, {"c", "%"}, {"d", "%"}}},
+		{"GRANT SELECT ON `shop`.* TO `app`@`%`, `web`@`%`", []Name{{"app", "%"}, {"web", "%"}}},
+		{"REVOKE ALL PRIVILEGES, GRANT OPTION FROM `app`@`%`", []Name{{"app", "%"}}},
+		{"GRANT `reader`@`%` TO `app`@`%`", []Name{{"app", "%"}}},
+		{"SET DEFAULT ROLE `reader`@`%` TO `app`@`%`", []Name{{"app", "%"}}},
+		{"SET DEFAULT ROLE `reader` FOR `app`@`%`", []Name{{"app", "%"}}},
+		{"CREATE ROLE IF NOT EXISTS `reader`", []Name{{"reader", "%"}}},
+		{"DROP ROLE `reader`", []Name{{"reader", "%"}}},
+		{"SHOW GRANTS FOR `app`@`%`", nil},
+		{"GRANT SELECT ON `unterminated", nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, StatementAccounts(tt.stmt), tt.stmt)
+	}
+}
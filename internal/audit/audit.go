@@ -0,0 +1,137 @@
+// Package audit checks structured account data for insecure settings and
+// reports the findings ranked by severity
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/fatih/color"
+)
+
+var red = color.New(color.FgRed).SprintFunc()
+var yellow = color.New(color.FgYellow).SprintFunc()
+
+// Severity ranks how urgent a finding is
+type Severity string
+
+const (
+	Critical Severity = "critical"
+	High     Severity = "high"
+	Medium   Severity = "medium"
+	Low      Severity = "low"
+)
+
+// Severities lists the severities from most to least urgent
+var Severities = []Severity{Critical, High, Medium, Low}
+
+// Rank orders severities, higher is more urgent
+func (s Severity) Rank() int {
+	for i, sev := range Severities {
+		if s == sev {
+			return len(Severities) - i
+		}
+	}
+	return 0
+}
+
+// Rule describes a check findings are reported for
+type Rule struct {
+	ID          string   `json:"id"`
+	Severity    Severity `json:"severity"`
+	Description string   `json:"description"`
+}
+
+// Finding is a single problem found on an account
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Account  string   `json:"account"`
+	Message  string   `json:"message"`
+}
+
+// Report holds the findings for one host or dump file
+type Report struct {
+	Source   string    `json:"source"`
+	Rules    []Rule    `json:"-"`
+	Findings []Finding `json:"findings"`
+}
+
+// Run evaluates every audit rule against the accounts
+func Run(source string, accounts []account.Account) *Report {
+	r := &Report{Source: source, Rules: Rules(), Findings: []Finding{}}
+	for i := range accounts {
+		a := &accounts[i]
+		for _, c := range checks {
+			for _, msg := range c.run(a) {
+				r.Add(c.Rule, a.ID(), msg)
+			}
+		}
+	}
+	r.Sort()
+	return r
+}
+
+// Add records a finding for rule on an account
+func (r *Report) Add(rule Rule, account, message string) {
+	r.Findings = append(r.Findings, Finding{Rule: rule.ID, Severity: rule.Severity, Account: account, Message: message})
+}
+
+// Sort orders findings by severity, then account, then rule
+func (r *Report) Sort() {
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		a, b := r.Findings[i], r.Findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity.Rank() > b.Severity.Rank()
+		}
+		if a.Account != b.Account {
+			return a.Account < b.Account
+		}
+		return a.Rule < b.Rule
+	})
+}
+
+// Count returns the number of findings with the given severity
+func (r *Report) Count(s Severity) int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Severity == s {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes a human-readable, colored report
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Auditing %s\n", r.Source)
+	for _, f := range r.Findings {
+		label := "[" + strings.ToUpper(string(f.Severity)) + "]"
+		switch f.Severity {
+		case Critical, High:
+			label = red(label)
+		case Medium:
+			label = yellow(label)
+		}
+		fmt.Fprintf(&b, "%s %s %s: %s\n", label, f.Rule, f.Account, f.Message)
+	}
+	var totals []string
+	for _, s := range Severities {
+		totals = append(totals, fmt.Sprintf("%s%s: %d", strings.ToUpper(string(s[:1])), s[1:], r.Count(s)))
+	}
+	fmt.Fprintln(&b, strings.Join(totals, ", "))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
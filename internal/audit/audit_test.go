@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+const auditSQL = `
+CREATE USER 'app'@'%' IDENTIFIED WITH 'mysql_native_password' AS '*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19' REQUIRE NONE PASSWORD EXPIRE NEVER;
+GRANT SELECT ON *.* TO 'app'@'%';
+CREATE USER ''@'localhost' IDENTIFIED WITH 'caching_sha2_password' AS 0x24;
+CREATE USER 'safe'@'localhost' IDENTIFIED WITH 'caching_sha2_password' AS 0x24 REQUIRE SSL;
+`
+
+func TestRun(t *testing.T) {
+	r := Run("dump.sql", parseAccounts(t, auditSQL))
+	var got [][]string
+	for _, f := range r.Findings {
+		got = append(got, []string{string(f.Severity), f.Rule, f.Account})
+	}
+	assert.Equal(t, [][]string{
+		{"critical", "anonymous-user", "``@`localhost`"},
+		{"high", "wildcard-host-privileged", "`app`@`%`"},
+		{"medium", "native-password", "`app`@`%`"},
+		{"medium", "require-none-remote", "`app`@`%`"},
+		{"low", "password-never-expires", "`app`@`%`"},
+	}, got)
+	assert.Equal(t, 2, r.Count(Medium))
+}
+
+func TestRun_Clean(t *testing.T) {
+	r := Run("db1", parseAccounts(t, "CREATE USER 'safe'@'localhost' IDENTIFIED WITH 'caching_sha2_password' AS 0x24"))
+	assert.Empty(t, r.Findings)
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.WriteJSON(&buf))
+	assert.JSONEq(t, `{"source":"db1","findings":[]}`, buf.String())
+}
+
+func TestWriteText(t *testing.T) {
+	color.NoColor = true
+	r := Run("dump.sql", parseAccounts(t, auditSQL))
+	var buf bytes.Buffer
+	assert.NoError(t, r.WriteText(&buf))
+	assert.Equal(t, "Auditing dump.sql\n"+
+		"[CRITICAL] anonymous-user ``@`localhost`: anonymous account\n"+
+		"[HIGH] wildcard-host-privileged `app`@`%`: global privileges from host '%': SELECT\n"+
+		"[MEDIUM] native-password `app`@`%`: uses mysql_native_password\n"+
+		"[MEDIUM] require-none-remote `app`@`%`: REQUIRE NONE for host '%'\n"+
+		"[LOW] password-never-expires `app`@`%`: PASSWORD EXPIRE NEVER\n"+
+		"Critical: 1, High: 1, Medium: 2, Low: 1\n", buf.String())
+}
+
+func TestWriteJSON(t *testing.T) {
+	r := Run("dump.sql", parseAccounts(t, auditSQL))
+	var buf bytes.Buffer
+	assert.NoError(t, r.WriteJSON(&buf))
+
+	var decoded Report
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, r.Findings, decoded.Findings)
+}
@@ -0,0 +1,161 @@
+package audit
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/passhash"
+)
+
+// check pairs a rule with the function returning one message per problem
+type check struct {
+	Rule
+	run func(a *account.Account) []string
+}
+
+// Rules that only concern logging in are not evaluated for locked accounts,
+// which includes roles.
+var checks = []check{
+	{Rule{"anonymous-user", Critical, "Anonymous accounts let anyone connect"}, anonymousUser},
+	{Rule{"empty-password", Critical, "Accounts that can log in without a password"}, emptyPassword},
+	{Rule{"app-all-privileges", High, "Accounts other than local root with ALL PRIVILEGES on *.*"}, appAllPrivileges},
+	{Rule{"dangerous-privilege", High, "Holders of SUPER, FILE, SHUTDOWN or SYSTEM_USER"}, dangerousPrivilege},
+	{Rule{"wildcard-host-privileged", High, "Privileged accounts that can connect from any host"}, wildcardHostPrivileged},
+	{Rule{"grant-option", Medium, "Accounts that can pass their privileges on"}, grantOption},
+	{Rule{"native-password", Medium, "Accounts still using the deprecated mysql_native_password plugin"}, nativePassword},
+	{Rule{"require-none-remote", Medium, "Remote accounts that do not require TLS"}, requireNoneRemote},
+	{Rule{"password-never-expires", Low, "Accounts whose password never expires"}, passwordNeverExpires},
+}
+
+// Rules returns the built-in audit rules
+func Rules() []Rule {
+	rules := make([]Rule, len(checks))
+	for i, c := range checks {
+		rules[i] = c.Rule
+	}
+	return rules
+}
+
+// dangerousPrivileges are global privileges that allow taking over or
+// stopping the server
+var dangerousPrivileges = []string{"SUPER", "FILE", "SHUTDOWN", "SYSTEM_USER"}
+
+// staticPrivileges are the privileges SHOW GRANTS lists instead of ALL
+// PRIVILEGES on MySQL 8.0
+var staticPrivileges = []string{
+	"SELECT", "INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "RELOAD", "SHUTDOWN",
+	"PROCESS", "FILE", "REFERENCES", "INDEX", "ALTER", "SHOW DATABASES", "SUPER",
+	"CREATE TEMPORARY TABLES", "LOCK TABLES", "EXECUTE", "REPLICATION SLAVE",
+	"REPLICATION CLIENT", "CREATE VIEW", "SHOW VIEW", "CREATE ROUTINE", "ALTER ROUTINE",
+	"CREATE USER", "EVENT", "TRIGGER", "CREATE TABLESPACE", "CREATE ROLE", "DROP ROLE",
+}
+
+// globalPrivileges returns the privileges granted on *.*, without GRANT OPTION
+func globalPrivileges(a *account.Account) map[string]bool {
+	privs := account.PrivilegeSet(a.Grants)["*.*"]
+	delete(privs, "GRANT OPTION")
+	return privs
+}
+
+// hasAllPrivileges reports whether privs is ALL PRIVILEGES, written out or not
+func hasAllPrivileges(privs map[string]bool) bool {
+	if privs["ALL PRIVILEGES"] {
+		return true
+	}
+	for _, p := range staticPrivileges {
+		if !privs[p] {
+			return false
+		}
+	}
+	return true
+}
+
+func isLocalHost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+func anonymousUser(a *account.Account) []string {
+	if a.User == "" {
+		return []string{"anonymous account"}
+	}
+	return nil
+}
+
+func emptyPassword(a *account.Account) []string {
+	// plugins such as auth_socket have no password to be empty
+	if a.Locked || a.AuthString != "" || !slices.Contains(passhash.Plugins, a.Plugin) {
+		return nil
+	}
+	return []string{"empty password with " + a.Plugin}
+}
+
+func appAllPrivileges(a *account.Account) []string {
+	if (a.User == "root" && isLocalHost(a.Host)) || a.Locked || !hasAllPrivileges(globalPrivileges(a)) {
+		return nil
+	}
+	return []string{"ALL PRIVILEGES on *.*"}
+}
+
+func dangerousPrivilege(a *account.Account) []string {
+	privs := globalPrivileges(a)
+	if privs["ALL PRIVILEGES"] {
+		return []string{"holds ALL PRIVILEGES on *.*, including " + strings.Join(dangerousPrivileges, ", ")}
+	}
+	var msgs []string
+	for _, p := range dangerousPrivileges {
+		if privs[p] {
+			msgs = append(msgs, "holds "+p)
+		}
+	}
+	return msgs
+}
+
+func wildcardHostPrivileged(a *account.Account) []string {
+	if a.Locked || !strings.Contains(a.Host, "%") {
+		return nil
+	}
+	if privs := globalPrivileges(a); len(privs) > 0 {
+		return []string{"global privileges from host " + account.QuoteString(a.Host) + ": " + strings.Join(account.SortedKeys(privs), ", ")}
+	}
+	return nil
+}
+
+func grantOption(a *account.Account) []string {
+	var msgs []string
+	for _, g := range a.Grants {
+		if g.GrantOption {
+			msgs = append(msgs, "WITH GRANT OPTION on "+g.Level())
+		}
+	}
+	return msgs
+}
+
+func nativePassword(a *account.Account) []string {
+	if a.Locked || a.Plugin != passhash.NativePassword {
+		return nil
+	}
+	return []string{"uses mysql_native_password"}
+}
+
+func requireNoneRemote(a *account.Account) []string {
+	if a.Locked || isLocalHost(a.Host) || (a.Require != "" && a.Require != "NONE") {
+		return nil
+	}
+	return []string{"REQUIRE NONE for host " + account.QuoteString(a.Host)}
+}
+
+// passwordNeverExpires also flags PASSWORD EXPIRE DEFAULT, which follows
+// default_password_lifetime and never expires with its default of 0
+func passwordNeverExpires(a *account.Account) []string {
+	if a.Locked {
+		return nil
+	}
+	switch a.PasswordExpire {
+	case "NEVER":
+		return []string{"PASSWORD EXPIRE NEVER"}
+	case "DEFAULT":
+		return []string{"PASSWORD EXPIRE DEFAULT, which never expires unless default_password_lifetime is set"}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func parseAccounts(t *testing.T, sql string) []account.Account {
+	t.Helper()
+	accounts, err := account.ParseSQL(sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return accounts
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		rule string
+		want []string
+	}{
+		{"anonymous", "CREATE USER ''@'localhost' IDENTIFIED WITH 'caching_sha2_password' AS 0x24 REQUIRE SSL", "anonymous-user", []string{"anonymous account"}},
+		{"empty password", "CREATE USER 'app'@'localhost' IDENTIFIED WITH 'caching_sha2_password'", "empty-password", []string{"empty password with caching_sha2_password"}},
+		{"empty auth_socket", "CREATE USER 'root'@'localhost' IDENTIFIED WITH 'auth_socket'", "empty-password", nil},
+		{"empty but locked", "CREATE USER 'role'@'%' IDENTIFIED WITH 'caching_sha2_password' ACCOUNT LOCK", "empty-password", nil},
+		{"all privileges", "CREATE USER 'app'@'10.%'; GRANT ALL PRIVILEGES ON *.* TO 'app'@'10.%'", "app-all-privileges", []string{"ALL PRIVILEGES on *.*"}},
+		{"all privileges root", "CREATE USER 'root'@'localhost'; GRANT ALL PRIVILEGES ON *.* TO 'root'@'localhost'", "app-all-privileges", nil},
+		{"all privileges remote root", "CREATE USER 'root'@'%'; GRANT ALL PRIVILEGES ON *.* TO 'root'@'%'", "app-all-privileges", []string{"ALL PRIVILEGES on *.*"}},
+		{"all privileges schema", "CREATE USER 'app'@'10.%'; GRANT ALL PRIVILEGES ON `app`.* TO 'app'@'10.%'", "app-all-privileges", nil},
+		{"dangerous", "CREATE USER 'ops'@'localhost'; GRANT FILE, SUPER, SELECT ON *.* TO 'ops'@'localhost'; GRANT SYSTEM_USER ON *.* TO 'ops'@'localhost'", "dangerous-privilege", []string{"holds SUPER", "holds FILE", "holds SYSTEM_USER"}},
+		{"dangerous all", "CREATE USER 'ops'@'localhost'; GRANT ALL ON *.* TO 'ops'@'localhost'", "dangerous-privilege", []string{"holds ALL PRIVILEGES on *.*, including SUPER, FILE, SHUTDOWN, SYSTEM_USER"}},
+		{"wildcard host", "CREATE USER 'ops'@'%'; GRANT PROCESS, RELOAD ON *.* TO 'ops'@'%'", "wildcard-host-privileged", []string{"global privileges from host '%': PROCESS, RELOAD"}},
+		{"wildcard host schema grant", "CREATE USER 'app'@'%'; GRANT SELECT ON `app`.* TO 'app'@'%'", "wildcard-host-privileged", nil},
+		{"grant option", "CREATE USER 'ops'@'localhost'; GRANT SELECT ON `app`.* TO 'ops'@'localhost' WITH GRANT OPTION", "grant-option", []string{"WITH GRANT OPTION on `app`.*"}},
+		{"native", "CREATE USER 'legacy'@'localhost' IDENTIFIED WITH 'mysql_native_password' AS '*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19'", "native-password", []string{"uses mysql_native_password"}},
+		{"require none remote", "CREATE USER 'app'@'10.%' REQUIRE NONE", "require-none-remote", []string{"REQUIRE NONE for host '10.%'"}},
+		{"require ssl remote", "CREATE USER 'app'@'10.%' REQUIRE SSL", "require-none-remote", nil},
+		{"require none local", "CREATE USER 'app'@'localhost' REQUIRE NONE", "require-none-remote", nil},
+		{"never expires", "CREATE USER 'app'@'localhost' PASSWORD EXPIRE NEVER", "password-never-expires", []string{"PASSWORD EXPIRE NEVER"}},
+		{"default expiry", "CREATE USER 'app'@'localhost' PASSWORD EXPIRE DEFAULT", "password-never-expires", []string{"PASSWORD EXPIRE DEFAULT, which never expires unless default_password_lifetime is set"}},
+		{"expiry interval", "CREATE USER 'app'@'localhost' PASSWORD EXPIRE INTERVAL 90 DAY", "password-never-expires", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := parseAccounts(t, tt.sql)[0]
+			for _, c := range checks {
+				if c.ID == tt.rule {
+					assert.Equal(t, tt.want, c.run(&a))
+					return
+				}
+			}
+			t.Fatalf("unknown rule %s", tt.rule)
+		})
+	}
+}
+
+func TestHasAllPrivileges(t *testing.T) {
+	privs := make(map[string]bool)
+	for _, p := range staticPrivileges {
+		privs[p] = true
+	}
+	assert.True(t, hasAllPrivileges(privs))
+	delete(privs, "DROP ROLE")
+	assert.False(t, hasAllPrivileges(privs))
+	assert.True(t, hasAllPrivileges(map[string]bool{"ALL PRIVILEGES": true}))
+}
@@ -0,0 +1,38 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	r := Run("dump.sql", parseAccounts(t, auditSQL))
+	var buf bytes.Buffer
+	assert.NoError(t, r.WriteSARIF(&buf, "dump.sql"))
+
+	var log sarifLog
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	run := log.Runs[0]
+	assert.Len(t, run.Tool.Driver.Rules, len(checks))
+	assert.Len(t, run.Results, len(r.Findings))
+
+	first := run.Results[0]
+	assert.Equal(t, "anonymous-user", first.RuleID)
+	assert.Equal(t, "anonymous-user", run.Tool.Driver.Rules[first.RuleIndex].ID)
+	assert.Equal(t, "error", first.Level)
+	assert.Equal(t, "dump.sql", first.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, "``@`localhost`", first.Locations[0].LogicalLocations[0].Name)
+	assert.Equal(t, "note", run.Results[len(run.Results)-1].Level)
+}
+
+func TestWriteSARIF_Host(t *testing.T) {
+	r := Run("db1", nil)
+	var buf bytes.Buffer
+	assert.NoError(t, r.WriteSARIF(&buf, ""))
+	assert.Contains(t, buf.String(), `"results": []`)
+	assert.NotContains(t, buf.String(), "physicalLocation")
+}
@@ -4,11 +4,32 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"runtime"
 	"strings"
 )
 
+// Commands understood by go-pass. CmdDump is used when no command is given.
+const (
+	CmdDump      = ""
+	CmdDiff      = "diff"
+	CmdPlan      = "plan"
+	CmdApply     = "apply"
+	CmdHash      = "hash"
+	CmdVerify    = "verify-password"
+	CmdWeak      = "weak-passwords"
+	CmdAudit     = "audit"
+	CmdPolicy    = "policy-check"
+	CmdMigrate   = "migrate-plugin"
+	CmdRotate    = "rotate"
+	CmdEffective = "effective"
+	CmdWhoCan    = "who-can"
+	CmdMatch     = "match"
+	CmdLint      = "lint"
+)
+
 // Config holds the application configuration
 type Config struct {
+	Command    string
 	SourceHost string
 	DumpFile   string
 	OnlyUser   string
@@ -16,20 +37,163 @@ type Config struct {
 	Format     string
 	MySQLUser  string
 	MySQLPass  string
+	TargetHost string
+	SourceFile string
+	PlanFile   string
+	UndoFile   string
+	Plugin     string // hash: authentication plugin
+	Account    string // hash, verify-password, rotate, effective: user@host
+	// weak-passwords options
+	Wordlist string
+	Reveal   bool
+	Workers  int
+	// audit and policy-check options
+	FailOn     string
+	PolicyFile string
+	Env        string
+	// migrate-plugin options; Plugin is the plugin to migrate to
+	FromPlugin    string
+	PasswordsFile string
+	Generate      bool
+	RetainCurrent bool
+	SecretsFile   string
+	// rotate options; SecretsFile is where the new password is written
+	Hook     string
+	Finalize bool
+	// effective and who-can options
+	AllRoles  bool
+	On        string
+	Privilege string
+	// match options
+	LoginUser  string
+	ClientHost string
+	// lint options
+	SchemasFile string
+	// dump options
+	TargetVersion string // import format: MySQL version to translate accounts for
+	TargetProfile string // import format: managed service profile name or file
+	// apply options
+	DryRun          bool
+	ContinueOnError bool
+	SkipBinlog      bool
 }
 
-// ParseFlags parses command-line flags and returns a Config
+// ParseFlags parses the command line and returns a Config, exiting on errors
 func ParseFlags() *Config {
-	cfg := &Config{}
-	flag.StringVar(&cfg.SourceHost, "s", "", "Source Host")
-	flag.StringVar(&cfg.DumpFile, "f", "", "Dump file")
-	flag.StringVar(&cfg.OnlyUser, "o", "", "Only dump the specified user")
-	flag.StringVar(&cfg.Format, "format", "raw", "Output format: raw, import, pt-like")
-	flag.BoolVar(&cfg.Help, "h", false, "Print help")
-	flag.Parse()
+	cfg, err := Parse(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 	return cfg
 }
 
+// Parse parses an optional command followed by its flags
+func Parse(args []string) (*Config, error) {
+	cfg := &Config{}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cfg.Command = args[0]
+		args = args[1:]
+	}
+
+	fs := flag.NewFlagSet("go-pass", flag.ContinueOnError)
+	fs.StringVar(&cfg.SourceHost, "s", "", "Source Host")
+	fs.StringVar(&cfg.OnlyUser, "o", "", "Only dump the specified user")
+	fs.BoolVar(&cfg.Help, "h", false, "Print help")
+
+	switch cfg.Command {
+	case CmdDump:
+		fs.StringVar(&cfg.DumpFile, "f", "", "Dump file")
+		fs.StringVar(&cfg.Format, "format", "raw", "Output format: raw, import, pt-like, json, yaml")
+		fs.StringVar(&cfg.UndoFile, "undo-file", "", "Undo script path (default: <dump file>.undo.sql)")
+		fs.StringVar(&cfg.TargetVersion, "target-version", "", "Translate the import format for a MySQL version, e.g. 5.7, 8.0 or 8.4")
+		fs.StringVar(&cfg.TargetProfile, "target-profile", "", "Adjust the import format for a managed service: rds, aurora, cloudsql, azure or a profile file")
+	case CmdDiff:
+		fs.StringVar(&cfg.TargetHost, "t", "", "Target Host")
+		fs.StringVar(&cfg.SourceFile, "file", "", "Saved dump file to compare against the source host")
+		fs.StringVar(&cfg.Format, "format", "text", "Output format: text, json")
+	case CmdPlan:
+		fs.StringVar(&cfg.TargetHost, "t", "", "Target Host")
+		fs.StringVar(&cfg.SourceFile, "file", "", "Dump file to use as the source instead of a host")
+		fs.StringVar(&cfg.PlanFile, "f", "", "Plan file")
+	case CmdApply:
+		fs.StringVar(&cfg.TargetHost, "t", "", "Target Host (default: the target recorded in the plan)")
+		fs.StringVar(&cfg.UndoFile, "undo-file", "", "Undo script path (default: <file>.undo.sql)")
+		fs.BoolVar(&cfg.DryRun, "dry-run", false, "Print the statements without executing them")
+		fs.BoolVar(&cfg.ContinueOnError, "continue-on-error", false, "Keep applying after a statement fails")
+		fs.BoolVar(&cfg.SkipBinlog, "skip-binlog", false, "Run SET SESSION sql_log_bin=0 before applying")
+	case CmdHash:
+		fs.StringVar(&cfg.Plugin, "plugin", "caching_sha2_password", "Authentication plugin: caching_sha2_password, sha256_password, mysql_native_password")
+		fs.StringVar(&cfg.Account, "create-user", "", "Print a CREATE USER statement for user@host instead of the bare hash")
+	case CmdVerify:
+		fs.StringVar(&cfg.SourceFile, "file", "", "Dump file to read the authentication string from instead of a host")
+	case CmdWeak:
+		fs.StringVar(&cfg.SourceFile, "file", "", "Dump file to check instead of a host")
+		fs.StringVar(&cfg.Wordlist, "wordlist", "", "File with one candidate password per line")
+		fs.BoolVar(&cfg.Reveal, "reveal", false, "Print guessed passwords and how they were found")
+		fs.IntVar(&cfg.Workers, "workers", runtime.NumCPU(), "Number of concurrent workers")
+	case CmdAudit:
+		fs.StringVar(&cfg.SourceFile, "file", "", "Dump file to audit instead of a host")
+		fs.StringVar(&cfg.Format, "format", "text", "Report format: text, json, sarif")
+		fs.StringVar(&cfg.FailOn, "fail-on", "low", "Lowest severity that makes the exit code non-zero: critical, high, medium, low")
+	case CmdPolicy:
+		fs.StringVar(&cfg.PolicyFile, "policy", "", "YAML or JSON policy file")
+		fs.StringVar(&cfg.Env, "env", "", "Environment selecting forbidden_privileges entries")
+		fs.StringVar(&cfg.SourceFile, "file", "", "Dump file to check instead of a host")
+		fs.StringVar(&cfg.Format, "format", "text", "Report format: text, json, sarif")
+		fs.StringVar(&cfg.FailOn, "fail-on", "low", "Lowest severity that makes the exit code non-zero: critical, high, medium, low")
+	case CmdMigrate:
+		fs.StringVar(&cfg.FromPlugin, "from", "mysql_native_password", "Plugin to migrate accounts away from")
+		fs.StringVar(&cfg.Plugin, "to", "caching_sha2_password", "Plugin to migrate accounts to")
+		fs.StringVar(&cfg.PasswordsFile, "passwords", "", "CSV with user, host, password and current_password columns")
+		fs.BoolVar(&cfg.Generate, "generate", false, "Generate passwords for accounts missing from --passwords")
+		fs.BoolVar(&cfg.RetainCurrent, "retain-current", false, "Keep the current password valid with RETAIN CURRENT PASSWORD")
+		fs.StringVar(&cfg.PlanFile, "f", "", "Output file for the ALTER USER statements")
+		fs.StringVar(&cfg.SecretsFile, "secrets-out", "", "CSV file generated passwords are written to")
+	case CmdRotate:
+		fs.StringVar(&cfg.SecretsFile, "secret-file", "", "File the new password is written to")
+		fs.StringVar(&cfg.Hook, "hook", "", "Shell command that receives the new password on stdin")
+		fs.BoolVar(&cfg.Finalize, "finalize", false, "Discard the old password kept by a previous rotation")
+	case CmdEffective:
+		fs.StringVar(&cfg.SourceFile, "file", "", "Dump file to read the accounts from instead of a host")
+		fs.StringVar(&cfg.Format, "format", "text", "Output format: text, json")
+		fs.BoolVar(&cfg.AllRoles, "all-roles", false, "Treat every granted role as active, as with activate_all_roles_on_login")
+	case CmdWhoCan:
+		fs.StringVar(&cfg.On, "on", "", "Object to look up: schema, schema.table or *.*, optionally prefixed with PROCEDURE or FUNCTION")
+		fs.StringVar(&cfg.Privilege, "privilege", "", "Privilege to look up, e.g. SELECT")
+		fs.StringVar(&cfg.SourceFile, "file", "", "Dump file to read the accounts from instead of a host")
+		fs.StringVar(&cfg.Format, "format", "text", "Output format: text, json")
+	case CmdMatch:
+		fs.StringVar(&cfg.LoginUser, "user", "", "User name the client logs in with")
+		fs.StringVar(&cfg.ClientHost, "from", "", "Host name or IP address the client connects from")
+		fs.StringVar(&cfg.SourceFile, "file", "", "Dump file to read the accounts from instead of a host")
+		fs.StringVar(&cfg.Format, "format", "text", "Output format: text, json")
+	case CmdLint:
+		fs.StringVar(&cfg.SourceFile, "file", "", "Dump file to lint instead of a host")
+		fs.StringVar(&cfg.SchemasFile, "schemas", "", "File listing the schemas, tables and routines that exist, one per line")
+		fs.StringVar(&cfg.Format, "format", "text", "Output format: text, json")
+	default:
+		return nil, fmt.Errorf("unknown command %q", cfg.Command)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	positional := 0
+	switch cfg.Command {
+	case CmdApply:
+		cfg.PlanFile, positional = fs.Arg(0), 1
+	case CmdVerify, CmdRotate, CmdEffective:
+		cfg.Account, positional = fs.Arg(0), 1
+	}
+	// flag parsing stops at the first argument, so flags after it would
+	// otherwise be ignored without notice
+	if fs.NArg() > positional {
+		return nil, fmt.Errorf("unexpected argument %q: flags must come before arguments", fs.Arg(positional))
+	}
+	return cfg, nil
+}
+
 // LoadMyCnf reads the ~/.my.cnf file and sets MySQL credentials in Config
 func (c *Config) LoadMyCnf() error {
 	home := os.Getenv("HOME")
@@ -57,13 +221,189 @@ func (c *Config) LoadMyCnf() error {
 	return nil
 }
 
+// Offline reports whether the command runs without connecting to a server
+func (c *Config) Offline() bool {
+	switch c.Command {
+	case CmdHash:
+		return true
+	case CmdVerify, CmdWeak, CmdAudit, CmdPolicy, CmdEffective, CmdWhoCan, CmdMatch, CmdLint:
+		return c.SourceFile != ""
+	}
+	return false
+}
+
+// UndoPath returns where the undo script for the executed file is written
+func (c *Config) UndoPath() string {
+	if c.UndoFile != "" {
+		return c.UndoFile
+	}
+	if c.Command == CmdApply {
+		return c.PlanFile + ".undo.sql"
+	}
+	return c.DumpFile + ".undo.sql"
+}
+
 // Validate checks if required flags are set
 func (c *Config) Validate() error {
+	switch c.Command {
+	case CmdDiff:
+		return c.validateDiff()
+	case CmdPlan:
+		if (c.SourceHost == "") == (c.SourceFile == "") {
+			return fmt.Errorf("exactly one of source host (-s) or dump file (--file) is required")
+		}
+		if c.TargetHost == "" || c.PlanFile == "" {
+			return fmt.Errorf("target host (-t) and plan file (-f) are required")
+		}
+		return nil
+	case CmdApply:
+		if c.PlanFile == "" {
+			return fmt.Errorf("plan file argument is required")
+		}
+		return nil
+	case CmdHash:
+		if c.Plugin == "" {
+			return fmt.Errorf("plugin (--plugin) is required")
+		}
+		return nil
+	case CmdVerify:
+		if c.Account == "" {
+			return fmt.Errorf("account argument (user@host) is required")
+		}
+		if (c.SourceHost == "") == (c.SourceFile == "") {
+			return fmt.Errorf("exactly one of source host (-s) or dump file (--file) is required")
+		}
+		return nil
+	case CmdWeak:
+		if (c.SourceHost == "") == (c.SourceFile == "") {
+			return fmt.Errorf("exactly one of source host (-s) or dump file (--file) is required")
+		}
+		if c.Workers < 1 {
+			return fmt.Errorf("workers must be at least 1")
+		}
+		return nil
+	case CmdAudit:
+		return c.validateAudit()
+	case CmdPolicy:
+		if c.PolicyFile == "" {
+			return fmt.Errorf("policy file (--policy) is required")
+		}
+		return c.validateAudit()
+	case CmdMigrate:
+		return c.validateMigrate()
+	case CmdRotate:
+		return c.validateRotate()
+	case CmdEffective:
+		if c.Account == "" {
+			return fmt.Errorf("account argument (user@host) is required")
+		}
+		if (c.SourceHost == "") == (c.SourceFile == "") {
+			return fmt.Errorf("exactly one of source host (-s) or dump file (--file) is required")
+		}
+		if c.Format != "text" && c.Format != "json" {
+			return fmt.Errorf("unsupported %s format %q", c.Command, c.Format)
+		}
+		return nil
+	case CmdWhoCan:
+		if c.On == "" || c.Privilege == "" {
+			return fmt.Errorf("object (--on) and privilege (--privilege) are required")
+		}
+		if (c.SourceHost == "") == (c.SourceFile == "") {
+			return fmt.Errorf("exactly one of source host (-s) or dump file (--file) is required")
+		}
+		if c.Format != "text" && c.Format != "json" {
+			return fmt.Errorf("unsupported %s format %q", c.Command, c.Format)
+		}
+		return nil
+	case CmdMatch, CmdLint:
+		if c.Command == CmdMatch && (c.LoginUser == "" || c.ClientHost == "") {
+			return fmt.Errorf("user (--user) and client host (--from) are required")
+		}
+		if (c.SourceHost == "") == (c.SourceFile == "") {
+			return fmt.Errorf("exactly one of source host (-s) or dump file (--file) is required")
+		}
+		if c.Format != "text" && c.Format != "json" {
+			return fmt.Errorf("unsupported %s format %q", c.Command, c.Format)
+		}
+		return nil
+	}
 	if c.SourceHost == "" || c.DumpFile == "" {
 		return fmt.Errorf("source host (-s) and dump file (-f) are required")
 	}
 	if c.SourceHost == c.DumpFile {
 		return fmt.Errorf("source host and dump file cannot be the same")
 	}
+	if c.TargetVersion != "" && c.Format != "import" {
+		return fmt.Errorf("--target-version requires --format=import")
+	}
+	if c.TargetProfile != "" && c.Format != "import" {
+		return fmt.Errorf("--target-profile requires --format=import")
+	}
+	return nil
+}
+
+func (c *Config) validateDiff() error {
+	if c.SourceHost == "" {
+		return fmt.Errorf("source host (-s) is required")
+	}
+	if (c.TargetHost == "") == (c.SourceFile == "") {
+		return fmt.Errorf("exactly one of target host (-t) or dump file (--file) is required")
+	}
+	if c.Format != "text" && c.Format != "json" {
+		return fmt.Errorf("unsupported diff format %q", c.Format)
+	}
+	return nil
+}
+
+func (c *Config) validateAudit() error {
+	if (c.SourceHost == "") == (c.SourceFile == "") {
+		return fmt.Errorf("exactly one of source host (-s) or dump file (--file) is required")
+	}
+	if c.Format != "text" && c.Format != "json" && c.Format != "sarif" {
+		return fmt.Errorf("unsupported %s format %q", c.Command, c.Format)
+	}
+	switch c.FailOn {
+	case "critical", "high", "medium", "low":
+	default:
+		return fmt.Errorf("unsupported severity %q", c.FailOn)
+	}
+	return nil
+}
+
+func (c *Config) validateMigrate() error {
+	if c.SourceHost == "" {
+		return fmt.Errorf("source host (-s) is required")
+	}
+	if c.FromPlugin == c.Plugin {
+		return fmt.Errorf("--from and --to must be different plugins")
+	}
+	if (c.PasswordsFile != "" || c.Generate) && c.PlanFile == "" {
+		return fmt.Errorf("output file (-f) is required with --passwords or --generate")
+	}
+	if c.PlanFile != "" && c.PasswordsFile == "" && !c.Generate {
+		return fmt.Errorf("one of --passwords or --generate is required with -f")
+	}
+	if c.Generate && c.SecretsFile == "" {
+		return fmt.Errorf("--secrets-out is required with --generate")
+	}
+	if c.RetainCurrent && c.PasswordsFile == "" {
+		return fmt.Errorf("--retain-current needs current passwords from --passwords")
+	}
+	return nil
+}
+
+func (c *Config) validateRotate() error {
+	if c.SourceHost == "" || c.Account == "" {
+		return fmt.Errorf("source host (-s) and account argument (user@host) are required")
+	}
+	if c.Finalize {
+		if c.SecretsFile != "" || c.Hook != "" {
+			return fmt.Errorf("--finalize does not generate a password; --secret-file and --hook are not used")
+		}
+		return nil
+	}
+	if c.SecretsFile == "" && c.Hook == "" {
+		return fmt.Errorf("one of --secret-file or --hook is required to store the new password")
+	}
 	return nil
 }
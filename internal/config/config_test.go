@@ -110,3 +110,216 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse([]string{"-s", "db1", "-f", "out.sql", "--format", "import"})
+	assert.NoError(t, err)
+	assert.Equal(t, CmdDump, cfg.Command)
+	assert.Equal(t, "db1", cfg.SourceHost)
+	assert.Equal(t, "import", cfg.Format)
+
+	cfg, err = Parse([]string{"-s", "db1", "-f", "out.sql", "--format", "import", "--target-version", "8.4"})
+	assert.NoError(t, err)
+	assert.Equal(t, "8.4", cfg.TargetVersion)
+	assert.NoError(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"-s", "db1", "-f", "out.sql", "--format", "pt-like", "--target-version", "8.4"})
+	assert.NoError(t, err)
+	assert.EqualError(t, cfg.Validate(), "--target-version requires --format=import")
+
+	cfg, err = Parse([]string{"-s", "db1", "-f", "out.sql", "--format", "import", "--target-profile", "rds"})
+	assert.NoError(t, err)
+	assert.Equal(t, "rds", cfg.TargetProfile)
+	assert.NoError(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"-s", "db1", "-f", "out.sql", "--target-profile", "rds"})
+	assert.NoError(t, err)
+	assert.EqualError(t, cfg.Validate(), "--target-profile requires --format=import")
+
+	cfg, err = Parse([]string{"diff", "-s", "db1", "-t", "db2"})
+	assert.NoError(t, err)
+	assert.Equal(t, CmdDiff, cfg.Command)
+	assert.Equal(t, "db2", cfg.TargetHost)
+	assert.Equal(t, "text", cfg.Format)
+	assert.NoError(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"diff", "-s", "db1"})
+	assert.NoError(t, err)
+	assert.Error(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"diff", "--file", "accounts.sql", "-s", "db1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "accounts.sql", cfg.SourceFile)
+	assert.NoError(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"diff", "--file", "accounts.sql", "-s", "db1", "-t", "db2"})
+	assert.NoError(t, err)
+	assert.Error(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"plan", "--file", "accounts.sql", "-t", "db2", "-f", "plan.sql"})
+	assert.NoError(t, err)
+	assert.Equal(t, "plan.sql", cfg.PlanFile)
+	assert.NoError(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"apply", "-t", "db2", "plan.sql"})
+	assert.NoError(t, err)
+	assert.Equal(t, CmdApply, cfg.Command)
+	assert.Equal(t, "plan.sql", cfg.PlanFile)
+	assert.NoError(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"apply", "-t", "db2", "--dry-run", "--continue-on-error", "--skip-binlog", "import.sql"})
+	assert.NoError(t, err)
+	assert.True(t, cfg.DryRun)
+	assert.True(t, cfg.ContinueOnError)
+	assert.True(t, cfg.SkipBinlog)
+	assert.Equal(t, "import.sql", cfg.PlanFile)
+
+	_, err = Parse([]string{"apply", "plan.sql", "--dry-run"})
+	assert.EqualError(t, err, `unexpected argument "--dry-run": flags must come before arguments`)
+	_, err = Parse([]string{"effective", "app@%", "--all-roles"})
+	assert.Error(t, err)
+	_, err = Parse([]string{"audit", "-s", "db1", "extra"})
+	assert.EqualError(t, err, `unexpected argument "extra": flags must come before arguments`)
+
+	cfg, err = Parse([]string{"hash"})
+	assert.NoError(t, err)
+	assert.Equal(t, CmdHash, cfg.Command)
+	assert.Equal(t, "caching_sha2_password", cfg.Plugin)
+	assert.True(t, cfg.Offline())
+
+	cfg, err = Parse([]string{"hash", "--plugin", "mysql_native_password", "--create-user", "app@%"})
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql_native_password", cfg.Plugin)
+	assert.Equal(t, "app@%", cfg.Account)
+
+	cfg, err = Parse([]string{"verify-password", "--file", "dump.sql", "app@%"})
+	assert.NoError(t, err)
+	assert.Equal(t, CmdVerify, cfg.Command)
+	assert.Equal(t, "app@%", cfg.Account)
+	assert.True(t, cfg.Offline())
+	assert.NoError(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"verify-password", "-s", "db1", "app@%"})
+	assert.NoError(t, err)
+	assert.False(t, cfg.Offline())
+	assert.NoError(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"weak-passwords", "--file", "dump.sql", "--wordlist", "words.txt", "--workers", "2", "--reveal"})
+	assert.NoError(t, err)
+	assert.Equal(t, CmdWeak, cfg.Command)
+	assert.Equal(t, "words.txt", cfg.Wordlist)
+	assert.Equal(t, 2, cfg.Workers)
+	assert.True(t, cfg.Reveal)
+	assert.True(t, cfg.Offline())
+	assert.NoError(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"audit", "--file", "dump.sql", "--format", "sarif", "--fail-on", "high"})
+	assert.NoError(t, err)
+	assert.Equal(t, CmdAudit, cfg.Command)
+	assert.Equal(t, "sarif", cfg.Format)
+	assert.Equal(t, "high", cfg.FailOn)
+	assert.True(t, cfg.Offline())
+	assert.NoError(t, cfg.Validate())
+	cfg.FailOn = "urgent"
+	assert.Error(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"policy-check", "--policy", "policy.yaml", "--env", "production", "-s", "db1"})
+	assert.NoError(t, err)
+	assert.Equal(t, CmdPolicy, cfg.Command)
+	assert.Equal(t, "policy.yaml", cfg.PolicyFile)
+	assert.Equal(t, "production", cfg.Env)
+	assert.False(t, cfg.Offline())
+	assert.NoError(t, cfg.Validate())
+	cfg.PolicyFile = ""
+	assert.Error(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"migrate-plugin", "-s", "db1"})
+	assert.NoError(t, err)
+	assert.Equal(t, CmdMigrate, cfg.Command)
+	assert.Equal(t, "mysql_native_password", cfg.FromPlugin)
+	assert.Equal(t, "caching_sha2_password", cfg.Plugin)
+	assert.NoError(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"migrate-plugin", "-s", "db1", "--generate", "-f", "migrate.sql"})
+	assert.NoError(t, err)
+	assert.ErrorContains(t, cfg.Validate(), "--secrets-out")
+	cfg.SecretsFile = "secrets.csv"
+	assert.NoError(t, cfg.Validate())
+	cfg.RetainCurrent = true
+	assert.ErrorContains(t, cfg.Validate(), "--passwords")
+
+	cfg, err = Parse([]string{"rotate", "-s", "db1", "--secret-file", "app.secret", "app@10.%"})
+	assert.NoError(t, err)
+	assert.Equal(t, CmdRotate, cfg.Command)
+	assert.Equal(t, "app@10.%", cfg.Account)
+	assert.Equal(t, "app.secret", cfg.SecretsFile)
+	assert.False(t, cfg.Offline())
+	assert.NoError(t, cfg.Validate())
+	cfg.SecretsFile = ""
+	assert.ErrorContains(t, cfg.Validate(), "--hook")
+
+	cfg, err = Parse([]string{"rotate", "-s", "db1", "--finalize", "app@10.%"})
+	assert.NoError(t, err)
+	assert.True(t, cfg.Finalize)
+	assert.NoError(t, cfg.Validate())
+	cfg.Hook = "vault write secret/app -"
+	assert.Error(t, cfg.Validate())
+
+	cfg, err = Parse([]string{"effective", "--file", "dump.sql", "--all-roles", "app@10.%"})
+	assert.NoError(t, err)
+	assert.Equal(t, CmdEffective, cfg.Command)
+	assert.Equal(t, "app@10.%", cfg.Account)
+	assert.Equal(t, "text", cfg.Format)
+	assert.True(t, cfg.AllRoles)
+	assert.True(t, cfg.Offline())
+	assert.NoError(t, cfg.Validate())
+	cfg.Format = "sarif"
+	assert.EqualError(t, cfg.Validate(), `unsupported effective format "sarif"`)
+
+	cfg, err = Parse([]string{"effective", "-s", "db1"})
+	assert.NoError(t, err)
+	assert.False(t, cfg.Offline())
+	assert.EqualError(t, cfg.Validate(), "account argument (user@host) is required")
+
+	cfg, err = Parse([]string{"who-can", "--on", "payments.cards", "--privilege", "SELECT", "--file", "accounts.json"})
+	assert.NoError(t, err)
+	assert.Equal(t, CmdWhoCan, cfg.Command)
+	assert.Equal(t, "payments.cards", cfg.On)
+	assert.Equal(t, "SELECT", cfg.Privilege)
+	assert.True(t, cfg.Offline())
+	assert.NoError(t, cfg.Validate())
+	cfg.Privilege = ""
+	assert.EqualError(t, cfg.Validate(), "object (--on) and privilege (--privilege) are required")
+
+	cfg, err = Parse([]string{"match", "-s", "db1", "--user", "app", "--from", "10.1.2.3"})
+	assert.NoError(t, err)
+	assert.Equal(t, CmdMatch, cfg.Command)
+	assert.Equal(t, "app", cfg.LoginUser)
+	assert.Equal(t, "10.1.2.3", cfg.ClientHost)
+	assert.False(t, cfg.Offline())
+	assert.NoError(t, cfg.Validate())
+	cfg.ClientHost = ""
+	assert.EqualError(t, cfg.Validate(), "user (--user) and client host (--from) are required")
+
+	cfg, err = Parse([]string{"lint", "--file", "dump.sql", "--schemas", "objects.txt", "--format", "json"})
+	assert.NoError(t, err)
+	assert.Equal(t, CmdLint, cfg.Command)
+	assert.Equal(t, "objects.txt", cfg.SchemasFile)
+	assert.True(t, cfg.Offline())
+	assert.NoError(t, cfg.Validate())
+	cfg.SourceHost = "db1"
+	assert.EqualError(t, cfg.Validate(), "exactly one of source host (-s) or dump file (--file) is required")
+
+	cfg, err = Parse([]string{"apply"})
+	assert.NoError(t, err)
+	assert.Error(t, cfg.Validate())
+
+	_, err = Parse([]string{"bogus"})
+	assert.Error(t, err)
+}
+
+func TestUndoPath(t *testing.T) {
+	assert.Equal(t, "dump.sql.undo.sql", (&Config{DumpFile: "dump.sql"}).UndoPath())
+	assert.Equal(t, "import.sql.undo.sql", (&Config{Command: CmdApply, PlanFile: "import.sql"}).UndoPath())
+	assert.Equal(t, "rollback.sql", (&Config{Command: CmdApply, PlanFile: "import.sql", UndoFile: "rollback.sql"}).UndoPath())
+}
@@ -0,0 +1,319 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/config"
+)
+
+// querier is the subset of *sql.DB and *sql.Conn used to read accounts
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// listUsers returns the user and host of every account to dump, or only those
+// of cfg.OnlyUser when set. MariaDB lists its roles in mysql.user as well,
+// they are left out here and read by listRoles.
+func listUsers(ctx context.Context, q querier, srv *ServerInfo, cfg *config.Config) ([]account.Name, error) {
+	var rows *sql.Rows
+	var err error
+	switch {
+	case srv.Flavor == MariaDB && cfg.OnlyUser != "":
+		rows, err = q.QueryContext(ctx, "SELECT user, host FROM mysql.user WHERE user = ? AND is_role = 'N'", cfg.OnlyUser)
+	case srv.Flavor == MariaDB:
+		rows, err = q.QueryContext(ctx, "SELECT user, host FROM mysql.user WHERE is_role = 'N' AND user NOT IN ('mariadb.sys')")
+	case cfg.OnlyUser != "":
+		rows, err = q.QueryContext(ctx, "SELECT user, host FROM mysql.user WHERE user = ?", cfg.OnlyUser)
+	default:
+		rows, err = q.QueryContext(ctx, "SELECT user, host FROM mysql.user WHERE user NOT IN ('mysql.infoschema', 'mysql.session', 'mysql.sys')")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []account.Name
+	for rows.Next() {
+		var n account.Name
+		if err := rows.Scan(&n.User, &n.Host); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return users, nil
+}
+
+// listRoles returns the roles of a MariaDB server, or only the role named
+// user when set. MariaDB roles have no host; go-pass keeps them under %.
+func listRoles(ctx context.Context, q querier, user string) ([]account.Name, error) {
+	var rows *sql.Rows
+	var err error
+	if user != "" {
+		rows, err = q.QueryContext(ctx, "SELECT user FROM mysql.user WHERE user = ? AND is_role = 'Y'", user)
+	} else {
+		rows, err = q.QueryContext(ctx, "SELECT user FROM mysql.user WHERE is_role = 'Y'")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []account.Name
+	for rows.Next() {
+		n := account.Name{Host: "%"}
+		if err := rows.Scan(&n.User); err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return roles, nil
+}
+
+// LoadAccounts reads the accounts selected by cfg into structured form
+func LoadAccounts(ctx context.Context, db *sql.DB, srv *ServerInfo, cfg *config.Config) ([]account.Account, error) {
+	// print_identified_with_as_hex is a session variable, so every query
+	// below has to run on the same connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	users, err := listUsers(ctx, conn, srv, cfg)
+	if err != nil {
+		return nil, err
+	}
+	var roles []account.Name
+	if srv.Flavor == MariaDB {
+		if roles, err = listRoles(ctx, conn, cfg.OnlyUser); err != nil {
+			return nil, err
+		}
+	}
+
+	return readAccounts(ctx, conn, srv, users, roles)
+}
+
+// SnapshotAccounts reads the given accounts, leaving out those that do not exist
+func SnapshotAccounts(ctx context.Context, db *sql.DB, srv *ServerInfo, names []account.Name) ([]account.Account, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	isRole := make(map[account.Name]bool)
+	if srv.Flavor == MariaDB {
+		roles, err := listRoles(ctx, conn, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range roles {
+			isRole[r] = true
+		}
+	}
+
+	var existing, existingRoles []account.Name
+	for _, n := range names {
+		if isRole[n] {
+			existingRoles = append(existingRoles, n)
+			continue
+		}
+		var count int
+		if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM mysql.user WHERE user = ? AND host = ?", n.User, n.Host).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to look up %s@%s: %w", n.User, n.Host, err)
+		}
+		if count > 0 {
+			existing = append(existing, n)
+		}
+	}
+	if len(existing) == 0 && len(existingRoles) == 0 {
+		return nil, nil
+	}
+	return readAccounts(ctx, conn, srv, existing, existingRoles)
+}
+
+// readAccounts loads the named accounts and MariaDB roles, with hex auth
+// strings enabled on conn where the server supports them
+func readAccounts(ctx context.Context, conn *sql.Conn, srv *ServerInfo, names, roles []account.Name) ([]account.Account, error) {
+	if srv.Has(HexAuthStrings) {
+		if _, err := conn.ExecContext(ctx, "SET print_identified_with_as_hex = 1"); err != nil {
+			return nil, fmt.Errorf("failed to set print_identified_with_as_hex: %w", err)
+		}
+		defer conn.ExecContext(ctx, "SET print_identified_with_as_hex = 0")
+	}
+
+	var attrs map[account.Name]string
+	if srv.Has(UserAttributes) && len(names) > 0 {
+		var err error
+		if attrs, err = userAttributes(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	accounts := make([]account.Account, 0, len(names)+len(roles))
+	for _, n := range roles {
+		a := &account.Account{User: n.User, Host: n.Host, Role: true, Locked: true}
+		if err := loadGrants(ctx, conn, a, account.MariaDB.Grantee(a)); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, *a)
+	}
+	for _, n := range names {
+		a, err := loadAccount(ctx, conn, srv, n)
+		if err != nil {
+			return nil, err
+		}
+		if data, ok := attrs[n]; ok {
+			if err := a.SetAttributes(data); err != nil {
+				return nil, fmt.Errorf("failed to parse user attributes for %s@%s: %w", n.User, n.Host, err)
+			}
+		}
+		accounts = append(accounts, *a)
+	}
+	account.Sort(accounts)
+	return accounts, nil
+}
+
+// userAttributes reads the comment and user attributes of every account from
+// INFORMATION_SCHEMA.USER_ATTRIBUTES, as JSON objects
+func userAttributes(ctx context.Context, q querier) (map[account.Name]string, error) {
+	rows, err := q.QueryContext(ctx, "SELECT USER, HOST, ATTRIBUTE FROM INFORMATION_SCHEMA.USER_ATTRIBUTES WHERE ATTRIBUTE IS NOT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user attributes: %w", err)
+	}
+	defer rows.Close()
+
+	attrs := make(map[account.Name]string)
+	for rows.Next() {
+		var n account.Name
+		var data string
+		if err := rows.Scan(&n.User, &n.Host, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan user attributes: %w", err)
+		}
+		attrs[n] = data
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return attrs, nil
+}
+
+// loadAccount reads SHOW CREATE USER and SHOW GRANTS for a single account
+func loadAccount(ctx context.Context, q querier, srv *ServerInfo, u account.Name) (*account.Account, error) {
+	createStmt, err := showCreateUser(ctx, q, srv, u)
+	if err != nil {
+		return nil, err
+	}
+	a, err := account.ParseCreateUser(createStmt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse create user for %s@%s: %w", u.User, u.Host, err)
+	}
+
+	if err := loadGrants(ctx, q, a, u.ID()); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// showCreateUser returns SHOW CREATE USER for the account. Where the server
+// prints authentication strings as they are stored, the string is replaced
+// with a hex literal read from mysql.user.
+func showCreateUser(ctx context.Context, q querier, srv *ServerInfo, u account.Name) (string, error) {
+	var createStmt string
+	if err := q.QueryRowContext(ctx, "SHOW CREATE USER "+u.ID()).Scan(&createStmt); err != nil {
+		return "", fmt.Errorf("failed to show create user for %s@%s: %w", u.User, u.Host, err)
+	}
+	if srv.Flavor == MariaDB || srv.Has(HexAuthStrings) {
+		return createStmt, nil
+	}
+	return hexAuthString(ctx, q, u, createStmt)
+}
+
+// hexAuthString rewrites the authentication string of a SHOW CREATE USER
+// statement from a server without print_identified_with_as_hex, such as 5.7.
+// There the string is printed as it is stored: binary hashes such as those
+// of sha256_password may hold quotes or bytes that do not survive the
+// connection character set, so the stored bytes are read from mysql.user
+// and encoded in Go.
+func hexAuthString(ctx context.Context, q querier, u account.Name, createStmt string) (string, error) {
+	var raw []byte
+	err := q.QueryRowContext(ctx, "SELECT authentication_string FROM mysql.user WHERE user = ? AND host = ?", u.User, u.Host).Scan(&raw)
+	if err != nil {
+		return "", fmt.Errorf("failed to read authentication string for %s@%s: %w", u.User, u.Host, err)
+	}
+	if len(raw) == 0 {
+		return createStmt, nil
+	}
+	literal := " AS 0x" + strings.ToUpper(hex.EncodeToString(raw))
+	if quoted := " AS '" + string(raw) + "'"; strings.Contains(createStmt, quoted) {
+		return strings.Replace(createStmt, quoted, literal, 1), nil
+	}
+	// the printed string differs from the stored bytes; it still runs from
+	// AS up to REQUIRE, which SHOW CREATE USER always prints next
+	start := strings.Index(createStmt, " AS '")
+	end := strings.LastIndex(createStmt, "' REQUIRE ")
+	if start < 0 || end < start+len(" AS '") {
+		return "", fmt.Errorf("failed to locate the authentication string of %s@%s in SHOW CREATE USER", u.User, u.Host)
+	}
+	return createStmt[:start] + literal + createStmt[end+1:], nil
+}
+
+// loadGrants applies SHOW GRANTS for grantee to the account. MariaDB prints
+// the default role there as a SET DEFAULT ROLE statement.
+func loadGrants(ctx context.Context, q querier, a *account.Account, grantee string) error {
+	grants, err := showGrants(ctx, q, grantee)
+	if err != nil {
+		return err
+	}
+	for _, grant := range grants {
+		if strings.HasPrefix(strings.ToUpper(grant), "SET DEFAULT ROLE") {
+			roles, _, err := account.ParseDefaultRole(grant)
+			if err != nil {
+				return fmt.Errorf("failed to parse default role for %s@%s: %w", a.User, a.Host, err)
+			}
+			a.DefaultRoles = roles
+			continue
+		}
+		gs, err := account.ParseGrant(grant)
+		if err != nil {
+			return fmt.Errorf("failed to parse grant for %s@%s: %w", a.User, a.Host, err)
+		}
+		a.Apply(gs)
+	}
+	return nil
+}
+
+// showGrants returns the statements SHOW GRANTS prints for grantee
+func showGrants(ctx context.Context, q querier, grantee string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, "SHOW GRANTS FOR "+grantee)
+	if err != nil {
+		return nil, fmt.Errorf("failed to show grants for %s: %w", grantee, err)
+	}
+	defer rows.Close()
+
+	var grants []string
+	for rows.Next() {
+		var grant string
+		if err := rows.Scan(&grant); err != nil {
+			return nil, fmt.Errorf("failed to scan grant: %w", err)
+		}
+		grants = append(grants, grant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("grant rows error: %w", err)
+	}
+	return grants, nil
+}
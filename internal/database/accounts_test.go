@@ -0,0 +1,123 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/config"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectUserAttributes expects the user attribute query of a MySQL 8.0.21+
+// server, which returns no attributes
+func expectUserAttributes(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT USER, HOST, ATTRIBUTE FROM INFORMATION_SCHEMA.USER_ATTRIBUTES").
+		WillReturnRows(sqlmock.NewRows([]string{"USER", "HOST", "ATTRIBUTE"}))
+}
+
+func TestLoadAccounts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	cfg := &config.Config{}
+
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE user NOT IN").
+		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}).
+			AddRow("reader", "%").
+			AddRow("app", "10.%"))
+
+	mock.ExpectExec("SET print_identified_with_as_hex = 1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT USER, HOST, ATTRIBUTE FROM INFORMATION_SCHEMA.USER_ATTRIBUTES").
+		WillReturnRows(sqlmock.NewRows([]string{"USER", "HOST", "ATTRIBUTE"}).
+			AddRow("app", "10.%", `{"team": "billing", "comment": "checkout service"}`))
+
+	mock.ExpectQuery("SHOW CREATE USER `reader`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Create User"}).
+			AddRow("CREATE USER `reader`@`%` IDENTIFIED WITH 'caching_sha2_password' REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT LOCK"))
+	mock.ExpectQuery("SHOW GRANTS FOR `reader`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Grants"}).
+			AddRow("GRANT SELECT ON `shop`.* TO `reader`@`%`"))
+
+	mock.ExpectQuery("SHOW CREATE USER `app`@`10.%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Create User"}).
+			AddRow("CREATE USER `app`@`10.%` IDENTIFIED WITH 'caching_sha2_password' AS 0x2441243030352400 DEFAULT ROLE `reader`@`%` REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK"))
+	mock.ExpectQuery("SHOW GRANTS FOR `app`@`10.%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Grants"}).
+			AddRow("GRANT USAGE ON *.* TO `app`@`10.%`").
+			AddRow("GRANT INSERT, UPDATE ON `shop`.`orders` TO `app`@`10.%`").
+			AddRow("GRANT `reader`@`%` TO `app`@`10.%`"))
+
+	mock.ExpectExec("SET print_identified_with_as_hex = 0").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	accounts, err := LoadAccounts(context.Background(), db, mysql8, cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// sorted by user
+	assert.Len(t, accounts, 2)
+	app, reader := accounts[0], accounts[1]
+	assert.Equal(t, "`app`@`10.%`", app.ID())
+	assert.Equal(t, "2441243030352400", app.AuthString)
+	assert.Equal(t, []string{"`reader`@`%`"}, app.DefaultRoles)
+	assert.Len(t, app.Grants, 2)
+	assert.Equal(t, "`shop`.`orders`", app.Grants[1].Level())
+	assert.Equal(t, "`reader`@`%`", app.Roles[0].Role)
+	assert.Equal(t, "checkout service", app.Comment)
+	assert.Equal(t, map[string]any{"team": "billing"}, app.Attributes)
+
+	assert.True(t, reader.Locked)
+	assert.Empty(t, reader.AuthString)
+	assert.Equal(t, "GRANT SELECT ON `shop`.* TO `reader`@`%`", reader.Grants[0].Statement(reader.ID()))
+}
+
+func TestLoadAccounts_ParseError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE user = ?").
+		WithArgs("broken").
+		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}).AddRow("broken", "%"))
+	mock.ExpectExec("SET print_identified_with_as_hex = 1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectUserAttributes(mock)
+	mock.ExpectQuery("SHOW CREATE USER `broken`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Create User"}).AddRow("CREATE USER `broken"))
+	mock.ExpectExec("SET print_identified_with_as_hex = 0").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	_, err = LoadAccounts(context.Background(), db, mysql8, &config.Config{OnlyUser: "broken"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse create user for broken@%")
+}
+
+func TestLoadAccounts_MariaDB(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	expectMariaDB(mock)
+
+	accounts, err := LoadAccounts(context.Background(), db, mariadb106, &config.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Len(t, accounts, 2)
+	app, role := accounts[0], accounts[1]
+	assert.Equal(t, "ed25519", app.Plugin)
+	assert.Equal(t, "ZIgUREUg5PVgQ6LskhXmO+eZLS0nC8be6HPjYWR4YJY", string(app.AuthBytes()))
+	assert.Equal(t, []account.Factor{{Plugin: "unix_socket"}}, app.Alternatives)
+	assert.Equal(t, 20, app.MaxUserConnections)
+	assert.Equal(t, []string{"`app_read`@`%`"}, app.DefaultRoles)
+	assert.Equal(t, []account.RoleGrant{{Role: "`app_read`@`%`"}}, app.Roles)
+	assert.Len(t, app.Grants, 2)
+
+	assert.Equal(t, "`app_read`@`%`", role.ID())
+	assert.True(t, role.Role)
+	assert.Equal(t, "`shop`.*", role.Grants[1].Level())
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// Statement outcomes reported by Apply
+const (
+	StatusApplied = "applied"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// ApplyOptions controls how Apply executes statements
+type ApplyOptions struct {
+	DryRun          bool // report what would run without executing anything
+	ContinueOnError bool // keep going after a failed statement
+	SkipBinlog      bool // disable binary logging for the session (replica-local changes)
+}
+
+// ApplyResult is the outcome of a single statement
+type ApplyResult struct {
+	Statement string
+	Status    string
+	Reason    string
+}
+
+// ApplyReport summarizes an Apply run
+type ApplyReport struct {
+	Results []ApplyResult
+}
+
+// Count returns the number of statements with the given status
+func (r *ApplyReport) Count(status string) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed reports whether any statement failed
+func (r *ApplyReport) Failed() bool {
+	return r.Count(StatusFailed) > 0
+}
+
+// WriteText writes the failed and skipped statements followed by the totals
+func (r *ApplyReport) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for i, res := range r.Results {
+		switch res.Status {
+		case StatusFailed:
+			fmt.Fprintf(&b, "%s statement %d failed: %s\n", red("[!]"), i+1, res.Reason)
+		case StatusSkipped:
+			fmt.Fprintf(&b, "-- statement %d skipped (%s): %s\n", i+1, res.Reason, res.Statement)
+		}
+	}
+	fmt.Fprintf(&b, "Applied: %d, Failed: %d, Skipped: %d\n",
+		r.Count(StatusApplied), r.Count(StatusFailed), r.Count(StatusSkipped))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Apply executes statements one by one on a single connection. Account
+// management statements commit implicitly and are atomic on MySQL 8.0, so
+// each statement is its own transaction: a failure never leaves a statement
+// half applied, and earlier statements stay applied.
+func Apply(ctx context.Context, db *sql.DB, statements []string, opts ApplyOptions) (*ApplyReport, error) {
+	report := &ApplyReport{}
+	if opts.DryRun {
+		for _, stmt := range statements {
+			report.Results = append(report.Results, ApplyResult{Statement: stmt, Status: StatusSkipped, Reason: "dry run"})
+		}
+		return report, nil
+	}
+
+	// session variables only apply to the connection they were set on
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if opts.SkipBinlog {
+		if _, err := conn.ExecContext(ctx, "SET SESSION sql_log_bin = 0"); err != nil {
+			return nil, fmt.Errorf("failed to disable sql_log_bin: %w", err)
+		}
+		defer conn.ExecContext(ctx, "SET SESSION sql_log_bin = 1")
+	}
+
+	stopped := false
+	for i, stmt := range statements {
+		if stopped {
+			report.Results = append(report.Results, ApplyResult{Statement: stmt, Status: StatusSkipped, Reason: "earlier statement failed"})
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			report.Results = append(report.Results, ApplyResult{Statement: stmt, Status: StatusFailed, Reason: err.Error()})
+			stopped = !opts.ContinueOnError
+			continue
+		}
+		report.Results = append(report.Results, ApplyResult{Statement: stmt, Status: StatusApplied})
+		log.Printf("%s Applied statement %d of %d", green("[+]"), i+1, len(statements))
+	}
+	return report, nil
+}
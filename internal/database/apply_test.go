@@ -0,0 +1,87 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+var applyStatements = []string{
+	"CREATE USER IF NOT EXISTS `app`@`%`",
+	"GRANT SELECT ON `shop`.* TO `app`@`%`",
+	"GRANT INSERT ON `shop`.* TO `app`@`%`",
+}
+
+func TestApply(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec("CREATE USER IF NOT EXISTS `app`@`%`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("GRANT SELECT ON `shop`.\\* TO `app`@`%`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("GRANT INSERT ON `shop`.\\* TO `app`@`%`").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	report, err := Apply(context.Background(), db, applyStatements, ApplyOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.False(t, report.Failed())
+	assert.Equal(t, 3, report.Count(StatusApplied))
+}
+
+func TestApply_StopsOnError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec("CREATE USER IF NOT EXISTS `app`@`%`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("GRANT SELECT ON `shop`.\\* TO `app`@`%`").WillReturnError(assert.AnError)
+
+	report, err := Apply(context.Background(), db, applyStatements, ApplyOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.True(t, report.Failed())
+	assert.Equal(t, 1, report.Count(StatusApplied))
+	assert.Equal(t, 1, report.Count(StatusFailed))
+	assert.Equal(t, 1, report.Count(StatusSkipped))
+
+	color.NoColor = true
+	var buf bytes.Buffer
+	assert.NoError(t, report.WriteText(&buf))
+	assert.Contains(t, buf.String(), "[!] statement 2 failed: "+assert.AnError.Error())
+	assert.Contains(t, buf.String(), "-- statement 3 skipped (earlier statement failed)")
+	assert.Contains(t, buf.String(), "Applied: 1, Failed: 1, Skipped: 1")
+}
+
+func TestApply_ContinueOnErrorSkipBinlog(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec("SET SESSION sql_log_bin = 0").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE USER IF NOT EXISTS `app`@`%`").WillReturnError(assert.AnError)
+	mock.ExpectExec("GRANT SELECT ON `shop`.\\* TO `app`@`%`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("GRANT INSERT ON `shop`.\\* TO `app`@`%`").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SET SESSION sql_log_bin = 1").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	report, err := Apply(context.Background(), db, applyStatements, ApplyOptions{ContinueOnError: true, SkipBinlog: true})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 2, report.Count(StatusApplied))
+	assert.Equal(t, 1, report.Count(StatusFailed))
+}
+
+func TestApply_DryRun(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	report, err := Apply(context.Background(), db, applyStatements, ApplyOptions{DryRun: true, SkipBinlog: true})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 3, report.Count(StatusSkipped))
+	assert.False(t, report.Failed())
+}
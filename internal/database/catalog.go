@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/ChaosHour/go-pass/internal/effective"
+)
+
+// objectQueries list the schemas, tables and routines as type, schema and
+// name. Schemas are returned with the name *.
+var objectQueries = []string{
+	"SELECT 'TABLE', SCHEMA_NAME, '*' FROM information_schema.SCHEMATA",
+	"SELECT 'TABLE', TABLE_SCHEMA, TABLE_NAME FROM information_schema.TABLES",
+	"SELECT ROUTINE_TYPE, ROUTINE_SCHEMA, ROUTINE_NAME FROM information_schema.ROUTINES",
+}
+
+// ListObjects lists the schemas, tables and routines of the server, as far
+// as information_schema shows them to the connected user
+func ListObjects(ctx context.Context, db *sql.DB) ([]effective.Object, error) {
+	var objects []effective.Object
+	for _, query := range objectQueries {
+		rows, err := db.QueryContext(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query information_schema: %w", err)
+		}
+		for rows.Next() {
+			var o effective.Object
+			if err := rows.Scan(&o.Type, &o.Schema, &o.Name); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan object: %w", err)
+			}
+			objects = append(objects, o)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("rows error: %w", err)
+		}
+	}
+	return objects, nil
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/effective"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListObjects(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	columns := []string{"TYPE", "SCHEMA", "NAME"}
+	mock.ExpectQuery("FROM information_schema.SCHEMATA").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("TABLE", "shop", "*"))
+	mock.ExpectQuery("FROM information_schema.TABLES").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("TABLE", "shop", "orders"))
+	mock.ExpectQuery("FROM information_schema.ROUTINES").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("PROCEDURE", "shop", "refund"))
+
+	objects, err := ListObjects(context.Background(), db)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []effective.Object{
+		{Type: "TABLE", Schema: "shop", Name: "*"},
+		{Type: "TABLE", Schema: "shop", Name: "orders"},
+		{Type: "PROCEDURE", Schema: "shop", Name: "refund"},
+	}, objects)
+}
+
+func TestListObjects_Error(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("FROM information_schema.SCHEMATA").WillReturnError(errors.New("access denied"))
+	_, err = ListObjects(context.Background(), db)
+	assert.ErrorContains(t, err, "failed to query information_schema")
+}
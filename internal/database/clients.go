@@ -0,0 +1,32 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/ChaosHour/go-pass/internal/migrate"
+)
+
+// ClientConnections lists the client hosts each user connected from since
+// the server started, from performance_schema.accounts
+func ClientConnections(ctx context.Context, db *sql.DB) ([]migrate.ClientConnection, error) {
+	rows, err := db.QueryContext(ctx, "SELECT USER, HOST, CURRENT_CONNECTIONS, TOTAL_CONNECTIONS FROM performance_schema.accounts WHERE USER IS NOT NULL AND HOST IS NOT NULL ORDER BY USER, HOST")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query performance_schema.accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var conns []migrate.ClientConnection
+	for rows.Next() {
+		var c migrate.ClientConnection
+		if err := rows.Scan(&c.User, &c.Host, &c.Current, &c.Total); err != nil {
+			return nil, fmt.Errorf("failed to scan client connection: %w", err)
+		}
+		conns = append(conns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return conns, nil
+}
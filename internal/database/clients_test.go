@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/migrate"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientConnections(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT USER, HOST, CURRENT_CONNECTIONS, TOTAL_CONNECTIONS FROM performance_schema.accounts").
+		WillReturnRows(sqlmock.NewRows([]string{"USER", "HOST", "CURRENT_CONNECTIONS", "TOTAL_CONNECTIONS"}).
+			AddRow("app", "10.0.0.5", 2, 150).
+			AddRow("app", "10.0.0.6", 0, 12))
+
+	conns, err := ClientConnections(context.Background(), db)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []migrate.ClientConnection{
+		{User: "app", Host: "10.0.0.5", Current: 2, Total: 150},
+		{User: "app", Host: "10.0.0.6", Current: 0, Total: 12},
+	}, conns)
+}
+
+func TestClientConnections_Error(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT USER, HOST").WillReturnError(errors.New("performance_schema is disabled"))
+	_, err = ClientConnections(context.Background(), db)
+	assert.ErrorContains(t, err, "performance_schema.accounts")
+}
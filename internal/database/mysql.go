@@ -3,15 +3,22 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/ChaosHour/go-pass/internal/account"
 	"github.com/ChaosHour/go-pass/internal/config"
+	"github.com/ChaosHour/go-pass/internal/plan"
+	"github.com/ChaosHour/go-pass/internal/profile"
+	"github.com/ChaosHour/go-pass/internal/sqlsplit"
+	"github.com/ChaosHour/go-pass/internal/translate"
 	"github.com/fatih/color"
 	_ "github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v3"
 )
 
 var green = color.New(color.FgGreen).SprintFunc()
@@ -34,39 +41,51 @@ func Connect(ctx context.Context, cfg *config.Config) (*sql.DB, error) {
 }
 
 // DumpUserAccounts dumps user accounts to a file
-func DumpUserAccounts(ctx context.Context, db *sql.DB, cfg *config.Config) error {
-	var query string
-	if cfg.OnlyUser != "" {
-		query = "SELECT user, host FROM mysql.user WHERE user = ?"
-	} else {
-		query = "SELECT user, host FROM mysql.user WHERE user NOT IN ('mysql.infoschema', 'mysql.session', 'mysql.sys')"
+func DumpUserAccounts(ctx context.Context, db *sql.DB, srv *ServerInfo, cfg *config.Config) error {
+	if cfg.Format == "json" || cfg.Format == "yaml" {
+		return dumpStructured(ctx, db, srv, cfg)
+	}
+	if cfg.Format == "import" && (cfg.TargetVersion != "" || cfg.TargetProfile != "") {
+		return dumpTranslated(ctx, db, srv, cfg)
 	}
 
-	rows, err := db.QueryContext(ctx, query, cfg.OnlyUser)
+	// print_identified_with_as_hex is a session variable, so every query
+	// below has to run on the same connection
+	conn, err := db.Conn(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to query users: %w", err)
+		return fmt.Errorf("failed to get connection: %w", err)
 	}
-	defer rows.Close()
+	defer conn.Close()
 
-	var users []struct{ user, host string }
-	for rows.Next() {
-		var user, host string
-		if err := rows.Scan(&user, &host); err != nil {
-			return fmt.Errorf("failed to scan user: %w", err)
+	users, err := listUsers(ctx, conn, srv, cfg)
+	if err != nil {
+		return err
+	}
+	var roles []account.Name
+	if srv.Flavor == MariaDB {
+		if roles, err = listRoles(ctx, conn, cfg.OnlyUser); err != nil {
+			return err
 		}
-		users = append(users, struct{ user, host string }{user, host})
 	}
-	if err := rows.Err(); err != nil {
-		return fmt.Errorf("rows error: %w", err)
+	dialect := srv.Dialect()
+
+	// secondary passwords are not part of SHOW CREATE USER, so they are
+	// pointed out in the dump
+	var secondary map[account.Name]bool
+	if (cfg.Format == "pt-like" || cfg.Format == "import") && srv.Has(DualPasswords) {
+		if secondary, err = SecondaryPasswords(ctx, conn); err != nil {
+			log.Println(red("[!]"), "Not checking for secondary passwords:", err)
+		}
 	}
 
-	if cfg.Format == "pt-like" || cfg.Format == "import" {
-		_, err = db.ExecContext(ctx, "SET print_identified_with_as_hex = 1;")
+	hex := srv.Has(HexAuthStrings)
+	if (cfg.Format == "pt-like" || cfg.Format == "import") && hex {
+		_, err = conn.ExecContext(ctx, "SET print_identified_with_as_hex = 1;")
 		if err != nil {
 			return fmt.Errorf("failed to set print_identified_with_as_hex: %w", err)
 		}
 		defer func() {
-			db.ExecContext(ctx, "SET print_identified_with_as_hex = 0;")
+			conn.ExecContext(ctx, "SET print_identified_with_as_hex = 0;")
 		}()
 	}
 
@@ -76,63 +95,106 @@ func DumpUserAccounts(ctx context.Context, db *sql.DB, cfg *config.Config) error
 		outputLines = append(outputLines, fmt.Sprintf("-- Dumped from server %s via TCP/IP, MySQL at %s", cfg.SourceHost, time.Now().Format("2006-01-02 15:04:05")))
 	}
 
+	// MariaDB roles are created before anything is granted to them, including
+	// other roles
+	for _, r := range roles {
+		id := account.QuoteIdent(r.User)
+		switch cfg.Format {
+		case "pt-like":
+			outputLines = append(outputLines, fmt.Sprintf("-- Role '%s'", r.User))
+		case "import":
+			outputLines = append(outputLines, fmt.Sprintf("-- CREATE ROLE IF NOT EXISTS for %s: ", r.User))
+		}
+		if cfg.Format == "pt-like" || cfg.Format == "import" {
+			outputLines = append(outputLines, "CREATE ROLE IF NOT EXISTS "+id+";")
+		}
+	}
+	for _, r := range roles {
+		id := account.QuoteIdent(r.User)
+		switch cfg.Format {
+		case "raw":
+			outputLines = append(outputLines, fmt.Sprintf("SHOW GRANTS FOR %s;", id))
+		case "pt-like", "import":
+			grants, err := showGrants(ctx, conn, id)
+			if err != nil {
+				return err
+			}
+			if cfg.Format == "pt-like" {
+				outputLines = append(outputLines, fmt.Sprintf("-- Grants for role '%s'", r.User))
+			}
+			for _, grant := range grants {
+				outputLines = append(outputLines, grant+";")
+			}
+		}
+	}
+
 	for _, u := range users {
 		switch cfg.Format {
 		case "raw":
-			outputLines = append(outputLines, fmt.Sprintf("SHOW CREATE USER `%s`@`%s`; SHOW GRANTS FOR `%s`@`%s`;", u.user, u.host, u.user, u.host))
+			outputLines = append(outputLines, fmt.Sprintf("SHOW CREATE USER `%s`@`%s`; SHOW GRANTS FOR `%s`@`%s`;", u.User, u.Host, u.User, u.Host))
 		case "pt-like", "import":
-			// Execute SHOW CREATE USER
-			var createStmt string
-			err = db.QueryRowContext(ctx, fmt.Sprintf("SHOW CREATE USER `%s`@`%s`", u.user, u.host)).Scan(&createStmt)
+			createStmt, err := showCreateUser(ctx, conn, srv, u)
 			if err != nil {
-				return fmt.Errorf("failed to show create user for %s@%s: %w", u.user, u.host, err)
+				return err
 			}
 
+			var defaultRoles string
 			switch cfg.Format {
 			case "pt-like":
-				outputLines = append(outputLines, fmt.Sprintf("-- Grants for '%s'@'%s'", u.user, u.host))
-				// Split IDENTIFIED for ALTER
-				if strings.HasPrefix(createStmt, "CREATE USER ") {
-					afterCreate := createStmt[12:] // remove "CREATE USER "
-					idx := strings.Index(afterCreate, " IDENTIFIED ")
-					if idx > 0 {
-						userHost := strings.TrimSpace(afterCreate[:idx])
-						identified := strings.TrimSpace(afterCreate[idx+12:])
-						outputLines = append(outputLines, fmt.Sprintf("CREATE USER IF NOT EXISTS %s;", userHost))
-						outputLines = append(outputLines, fmt.Sprintf("ALTER USER %s IDENTIFIED %s;", userHost, identified))
-					} else {
-						// no IDENTIFIED, just CREATE USER
-						userHost := strings.TrimSpace(afterCreate)
-						outputLines = append(outputLines, fmt.Sprintf("CREATE USER IF NOT EXISTS %s;", userHost))
-					}
+				// ALTER USER takes every clause of CREATE USER except DEFAULT
+				// ROLE, which is set after the roles are granted
+				a, err := account.ParseCreateUser(createStmt)
+				if err != nil {
+					return fmt.Errorf("failed to parse create user for %s@%s: %w", u.User, u.Host, err)
+				}
+				outputLines = append(outputLines, fmt.Sprintf("-- Grants for '%s'@'%s'", u.User, u.Host))
+				if len(a.Factors) > 0 {
+					// created with all factors so that MODIFY n FACTOR below
+					// also works for a new account
+					outputLines = append(outputLines, fmt.Sprintf("CREATE USER IF NOT EXISTS %s %s;", a.ID(), a.AuthClause()))
 				} else {
-					outputLines = append(outputLines, createStmt+";")
+					outputLines = append(outputLines, fmt.Sprintf("CREATE USER IF NOT EXISTS %s;", a.ID()))
+				}
+				outputLines = append(outputLines, dialect.AlterStatement(a)+";")
+				if stmt := a.FactorStatement("MODIFY"); stmt != "" {
+					outputLines = append(outputLines, stmt+";")
+				}
+				if len(a.DefaultRoles) > 0 {
+					defaultRoles = dialect.DefaultRoleStatement(a) + ";"
 				}
 			case "import":
+				if !hex && dialect == account.MySQL {
+					// showCreateUser put in the authentication string as
+					// hex; the statement is rendered again in the quoting
+					// of 8.0. MariaDB prints its authentication strings as
+					// text, which imports as it is.
+					a, err := account.ParseCreateUser(createStmt)
+					if err != nil {
+						return fmt.Errorf("failed to parse create user for %s@%s: %w", u.User, u.Host, err)
+					}
+					createStmt = a.CreateStatement()
+				}
 				createStmt = strings.Replace(createStmt, "CREATE USER", "CREATE USER IF NOT EXISTS", 1)
-				outputLines = append(outputLines, fmt.Sprintf("-- CREATE USER IF NOT EXISTS for %s@%s: ", u.user, u.host))
+				outputLines = append(outputLines, fmt.Sprintf("-- CREATE USER IF NOT EXISTS for %s@%s: ", u.User, u.Host))
 				outputLines = append(outputLines, createStmt+";")
 			}
 
-			// Execute SHOW GRANTS
-			grantRows, err := db.QueryContext(ctx, fmt.Sprintf("SHOW GRANTS FOR `%s`@`%s`", u.user, u.host))
-			if err != nil {
-				return fmt.Errorf("failed to show grants for %s@%s: %w", u.user, u.host, err)
+			if secondary[u] {
+				outputLines = append(outputLines, fmt.Sprintf("-- %s holds a secondary password (RETAIN CURRENT PASSWORD) that is not part of this dump; finish the rotation with ALTER USER %s DISCARD OLD PASSWORD", u.ID(), u.ID()))
 			}
-			defer grantRows.Close()
 
-			for grantRows.Next() {
-				var grant string
-				if err := grantRows.Scan(&grant); err != nil {
-					return fmt.Errorf("failed to scan grant: %w", err)
-				}
+			grants, err := showGrants(ctx, conn, u.ID())
+			if err != nil {
+				return err
+			}
+			for _, grant := range grants {
 				if cfg.Format == "import" {
 					grant = strings.Replace(grant, "CREATE USER IF NOT EXISTS", "CREATE USER", -1)
 				}
 				outputLines = append(outputLines, grant+";")
 			}
-			if err := grantRows.Err(); err != nil {
-				return fmt.Errorf("grant rows error: %w", err)
+			if defaultRoles != "" {
+				outputLines = append(outputLines, defaultRoles)
 			}
 		}
 	}
@@ -156,19 +218,120 @@ func DumpUserAccounts(ctx context.Context, db *sql.DB, cfg *config.Config) error
 	return nil
 }
 
-// RunQuery executes SQL statements from the dump file and prints results
-func RunQuery(ctx context.Context, db *sql.DB, cfg *config.Config) error {
+// dumpStructured writes the parsed accounts as JSON or YAML
+func dumpStructured(ctx context.Context, db *sql.DB, srv *ServerInfo, cfg *config.Config) error {
+	accounts, err := LoadAccounts(ctx, db, srv, cfg)
+	if err != nil {
+		return err
+	}
+	var secondary map[account.Name]bool
+	if srv.Has(DualPasswords) {
+		if secondary, err = SecondaryPasswords(ctx, db); err != nil {
+			log.Println(red("[!]"), "Not checking for secondary passwords:", err)
+		}
+	}
+	for i := range accounts {
+		accounts[i].SecondaryPassword = secondary[account.Name{User: accounts[i].User, Host: accounts[i].Host}]
+	}
+
+	var data []byte
+	if cfg.Format == "json" {
+		data, err = json.MarshalIndent(accounts, "", "  ")
+		data = append(data, '\n')
+	} else {
+		data, err = yaml.Marshal(accounts)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode accounts: %w", err)
+	}
+	if err := os.WriteFile(cfg.DumpFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// dumpTranslated writes the import format for accounts rewritten to what
+// cfg.TargetVersion accepts and adjusted for the managed service of
+// cfg.TargetProfile. The statements are rendered from the parsed accounts,
+// so GRANT ... IDENTIFIED and other syntax of the source server is not
+// carried over.
+func dumpTranslated(ctx context.Context, db *sql.DB, srv *ServerInfo, cfg *config.Config) error {
+	var target translate.Version
+	var prof *profile.Profile
+	var err error
+	if cfg.TargetVersion != "" {
+		if target, err = translate.ParseVersion(cfg.TargetVersion); err != nil {
+			return err
+		}
+	}
+	if cfg.TargetProfile != "" {
+		if prof, err = profile.Load(cfg.TargetProfile); err != nil {
+			return err
+		}
+	}
+	accounts, err := LoadAccounts(ctx, db, srv, cfg)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- Accounts dumped by go-pass from %s", srv)
+	if cfg.TargetVersion != "" {
+		fmt.Fprintf(&b, ", translated for MySQL %s", target)
+	}
+	if prof != nil {
+		fmt.Fprintf(&b, ", adjusted for %s (%s)", prof.Name, prof.Description)
+	}
+	b.WriteString("\n")
+	if cfg.TargetVersion != "" {
+		var warnings []translate.Warning
+		accounts, warnings = translate.Accounts(accounts, target)
+		for _, w := range warnings {
+			log.Println(red("[!]"), w)
+			fmt.Fprintf(&b, "-- warning: %s\n", w)
+		}
+	}
+	if prof != nil {
+		var adjustments []profile.Adjustment
+		accounts, adjustments = prof.Apply(accounts)
+		for _, a := range adjustments {
+			log.Println(red("[!]"), a)
+			fmt.Fprintf(&b, "-- adjusted: %s\n", a)
+		}
+	}
+	// the translated statements are MySQL syntax; a dump that is only
+	// adjusted for a profile keeps the syntax of the source server
+	dialect := srv.Dialect()
+	if cfg.TargetVersion != "" {
+		dialect = account.MySQL
+	}
+	for _, stmt := range plan.Build(accounts, nil, dialect) {
+		b.WriteString(strings.Replace(stmt, "CREATE USER ", "CREATE USER IF NOT EXISTS ", 1) + ";\n")
+	}
+	if err := os.WriteFile(cfg.DumpFile, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// RunQuery executes SQL statements from the dump file and prints results.
+// When the statements modify accounts an undo script is written first.
+func RunQuery(ctx context.Context, db *sql.DB, srv *ServerInfo, cfg *config.Config) error {
 	data, err := os.ReadFile(cfg.DumpFile)
 	if err != nil {
 		return fmt.Errorf("failed to read dump file: %w", err)
 	}
 
-	statements := strings.Split(string(data), ";")
+	statements, err := sqlsplit.Split(string(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse dump file: %w", err)
+	}
+
+	if _, err := WriteUndo(ctx, db, srv, statements, cfg.UndoPath()); err != nil {
+		return err
+	}
+
 	for _, stmt := range statements {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" || strings.HasPrefix(stmt, "--") {
-			continue
-		}
 		rows, err := db.QueryContext(ctx, stmt)
 		if err != nil {
 			return fmt.Errorf("failed to execute statement: %w", err)
@@ -2,9 +2,13 @@ package database
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/ChaosHour/go-pass/internal/account"
 	"github.com/ChaosHour/go-pass/internal/config"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
@@ -37,7 +41,7 @@ func TestDumpUserAccounts_Raw(t *testing.T) {
 			AddRow("GRANT USAGE ON *.* TO `testuser`@`%`"))
 
 	ctx := context.Background()
-	err = DumpUserAccounts(ctx, db, cfg)
+	err = DumpUserAccounts(ctx, db, mysql8, cfg)
 	assert.NoError(t, err)
 
 	// Check file content
@@ -65,6 +69,9 @@ func TestDumpUserAccounts_Import(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}).
 			AddRow("flyway", "%"))
 
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE JSON_CONTAINS_PATH").
+		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}))
+
 	// Mock SET
 	mock.ExpectExec("SET print_identified_with_as_hex = 1").
 		WillReturnResult(sqlmock.NewResult(0, 0))
@@ -84,7 +91,7 @@ func TestDumpUserAccounts_Import(t *testing.T) {
 			AddRow("GRANT APPLICATION_PASSWORD_ADMIN,AUDIT_ABORT_EXEMPT,AUDIT_ADMIN,AUTHENTICATION_POLICY_ADMIN,BACKUP_ADMIN,BINLOG_ADMIN,BINLOG_ENCRYPTION_ADMIN,CLONE_ADMIN,CONNECTION_ADMIN,ENCRYPTION_KEY_ADMIN,FIREWALL_EXEMPT,FLUSH_OPTIMIZER_COSTS,FLUSH_STATUS,FLUSH_TABLES,FLUSH_USER_RESOURCES,GROUP_REPLICATION_ADMIN,GROUP_REPLICATION_STREAM,INNODB_REDO_LOG_ARCHIVE,INNODB_REDO_LOG_ENABLE,PASSWORDLESS_USER_ADMIN,PERSIST_RO_VARIABLES_ADMIN,REPLICATION_APPLIER,REPLICATION_SLAVE_ADMIN,RESOURCE_GROUP_ADMIN,RESOURCE_GROUP_USER,ROLE_ADMIN,SENSITIVE_VARIABLES_OBSERVER,SERVICE_CONNECTION_ADMIN,SESSION_VARIABLES_ADMIN,SET_USER_ID,SHOW_ROUTINE,SYSTEM_USER,SYSTEM_VARIABLES_ADMIN,TABLE_ENCRYPTION_ADMIN,TELEMETRY_LOG_ADMIN,XA_RECOVER_ADMIN ON *.* TO `flyway`@`%`"))
 
 	ctx := context.Background()
-	err = DumpUserAccounts(ctx, db, cfg)
+	err = DumpUserAccounts(ctx, db, mysql8, cfg)
 	assert.NoError(t, err)
 
 	// Check file content
@@ -92,6 +99,7 @@ func TestDumpUserAccounts_Import(t *testing.T) {
 	assert.NoError(t, err)
 	content := string(data)
 	assert.Contains(t, content, "-- CREATE USER IF NOT EXISTS for flyway@%:")
+	assert.NotContains(t, content, "secondary password")
 	assert.Contains(t, content, "CREATE USER IF NOT EXISTS `flyway`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x244124303035240A2B5D1718083E295E5D03126644062C6829654E793531634B6C6C55355452656246575576492F55703576633058307A5856595A4B4B4F51774B6C52556438")
 	assert.Contains(t, content, "GRANT SELECT, INSERT, UPDATE, DELETE, CREATE, DROP, RELOAD, SHUTDOWN, PROCESS, FILE, REFERENCES, INDEX, ALTER, SHOW DATABASES, SUPER, CREATE TEMPORARY TABLES, LOCK TABLES, EXECUTE, REPLICATION SLAVE, REPLICATION CLIENT, CREATE VIEW, SHOW VIEW, CREATE ROUTINE, ALTER ROUTINE, CREATE USER, EVENT, TRIGGER, CREATE TABLESPACE, CREATE ROLE, DROP ROLE ON *.* TO `flyway`@`%`;")
 
@@ -114,6 +122,9 @@ func TestDumpUserAccounts_PtLike(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}).
 			AddRow("flyway", "%"))
 
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE JSON_CONTAINS_PATH").
+		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}).AddRow("flyway", "%"))
+
 	// Mock SET
 	mock.ExpectExec("SET print_identified_with_as_hex = 1").
 		WillReturnResult(sqlmock.NewResult(0, 0))
@@ -133,7 +144,7 @@ func TestDumpUserAccounts_PtLike(t *testing.T) {
 			AddRow("GRANT APPLICATION_PASSWORD_ADMIN,AUDIT_ABORT_EXEMPT,AUDIT_ADMIN,AUTHENTICATION_POLICY_ADMIN,BACKUP_ADMIN,BINLOG_ADMIN,BINLOG_ENCRYPTION_ADMIN,CLONE_ADMIN,CONNECTION_ADMIN,ENCRYPTION_KEY_ADMIN,FIREWALL_EXEMPT,FLUSH_OPTIMIZER_COSTS,FLUSH_STATUS,FLUSH_TABLES,FLUSH_USER_RESOURCES,GROUP_REPLICATION_ADMIN,GROUP_REPLICATION_STREAM,INNODB_REDO_LOG_ARCHIVE,INNODB_REDO_LOG_ENABLE,PASSWORDLESS_USER_ADMIN,PERSIST_RO_VARIABLES_ADMIN,REPLICATION_APPLIER,REPLICATION_SLAVE_ADMIN,RESOURCE_GROUP_ADMIN,RESOURCE_GROUP_USER,ROLE_ADMIN,SENSITIVE_VARIABLES_OBSERVER,SERVICE_CONNECTION_ADMIN,SESSION_VARIABLES_ADMIN,SET_USER_ID,SHOW_ROUTINE,SYSTEM_USER,SYSTEM_VARIABLES_ADMIN,TABLE_ENCRYPTION_ADMIN,TELEMETRY_LOG_ADMIN,XA_RECOVER_ADMIN ON *.* TO `flyway`@`%`"))
 
 	ctx := context.Background()
-	err = DumpUserAccounts(ctx, db, cfg)
+	err = DumpUserAccounts(ctx, db, mysql8, cfg)
 	assert.NoError(t, err)
 
 	// Check file content
@@ -142,6 +153,7 @@ func TestDumpUserAccounts_PtLike(t *testing.T) {
 	content := string(data)
 	assert.Contains(t, content, "-- Grants dumped by go-pass")
 	assert.Contains(t, content, "-- Grants for 'flyway'@'%'")
+	assert.Contains(t, content, "-- `flyway`@`%` holds a secondary password")
 	assert.Contains(t, content, "CREATE USER IF NOT EXISTS `flyway`@`%`;")
 	assert.Contains(t, content, "ALTER USER `flyway`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x244124303035240A2B5D1718083E295E5D03126644062C6829654E793531634B6C6C55355452656246575576492F55703576633058307A5856595A4B4B4F51774B6C52556438")
 	assert.Contains(t, content, "GRANT SELECT, INSERT, UPDATE, DELETE, CREATE, DROP, RELOAD, SHUTDOWN, PROCESS, FILE, REFERENCES, INDEX, ALTER, SHOW DATABASES, SUPER, CREATE TEMPORARY TABLES, LOCK TABLES, EXECUTE, REPLICATION SLAVE, REPLICATION CLIENT, CREATE VIEW, SHOW VIEW, CREATE ROUTINE, ALTER ROUTINE, CREATE USER, EVENT, TRIGGER, CREATE TABLESPACE, CREATE ROLE, DROP ROLE ON *.* TO `flyway`@`%`;")
@@ -177,7 +189,7 @@ func TestDumpUserAccounts_OnlyUser(t *testing.T) {
 			AddRow("GRANT ALL PRIVILEGES ON *.* TO `specificuser`@`localhost` WITH GRANT OPTION"))
 
 	ctx := context.Background()
-	err = DumpUserAccounts(ctx, db, cfg)
+	err = DumpUserAccounts(ctx, db, mysql8, cfg)
 	assert.NoError(t, err)
 
 	// Check file content
@@ -188,3 +200,390 @@ func TestDumpUserAccounts_OnlyUser(t *testing.T) {
 
 	os.Remove(cfg.DumpFile)
 }
+
+func TestDumpUserAccounts_PtLikeDefaultRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	cfg := &config.Config{
+		Format:   "pt-like",
+		DumpFile: t.TempDir() + "/ptlike.sql",
+	}
+
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE user NOT IN").
+		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}).AddRow("app", "10.%"))
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE JSON_CONTAINS_PATH").
+		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}))
+	mock.ExpectExec("SET print_identified_with_as_hex = 1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SHOW CREATE USER `app`@`10.%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Create User"}).
+			AddRow("CREATE USER `app`@`10.%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24412430303524 DEFAULT ROLE `reader`@`%` REQUIRE NONE WITH MAX_USER_CONNECTIONS 50 PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK PASSWORD HISTORY 5 PASSWORD REUSE INTERVAL DEFAULT PASSWORD REQUIRE CURRENT DEFAULT FAILED_LOGIN_ATTEMPTS 3 PASSWORD_LOCK_TIME 2 ATTRIBUTE '{\"comment\": \"orders service\"}'"))
+	mock.ExpectQuery("SHOW GRANTS FOR `app`@`10.%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Grants"}).
+			AddRow("GRANT USAGE ON *.* TO `app`@`10.%`").
+			AddRow("GRANT `reader`@`%` TO `app`@`10.%`"))
+
+	err = DumpUserAccounts(context.Background(), db, mysql8, cfg)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(cfg.DumpFile)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Equal(t, []string{
+		"-- Grants for 'app'@'10.%'",
+		"CREATE USER IF NOT EXISTS `app`@`10.%`;",
+		"ALTER USER `app`@`10.%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24412430303524 REQUIRE NONE WITH MAX_USER_CONNECTIONS 50 PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK PASSWORD HISTORY 5 PASSWORD REUSE INTERVAL DEFAULT PASSWORD REQUIRE CURRENT DEFAULT FAILED_LOGIN_ATTEMPTS 3 PASSWORD_LOCK_TIME 2 COMMENT 'orders service';",
+		"GRANT USAGE ON *.* TO `app`@`10.%`;",
+		"GRANT `reader`@`%` TO `app`@`10.%`;",
+		"ALTER USER `app`@`10.%` DEFAULT ROLE `reader`@`%`;",
+	}, lines[2:])
+}
+
+func TestDumpUserAccounts_JSON(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	cfg := &config.Config{
+		OnlyUser: "app",
+		Format:   "json",
+		DumpFile: t.TempDir() + "/accounts.json",
+	}
+
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE user = ?").
+		WithArgs("app").
+		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}).AddRow("app", "10.%"))
+	mock.ExpectExec("SET print_identified_with_as_hex = 1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectUserAttributes(mock)
+	mock.ExpectQuery("SHOW CREATE USER `app`@`10.%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Create User"}).
+			AddRow("CREATE USER `app`@`10.%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24412430303524 REQUIRE NONE WITH MAX_QUERIES_PER_HOUR 100 PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK FAILED_LOGIN_ATTEMPTS 3 PASSWORD_LOCK_TIME UNBOUNDED ATTRIBUTE '{\"team\": \"payments\"}'"))
+	mock.ExpectQuery("SHOW GRANTS FOR `app`@`10.%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Grants"}).AddRow("GRANT SELECT ON `shop`.* TO `app`@`10.%`"))
+	mock.ExpectExec("SET print_identified_with_as_hex = 0").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE JSON_CONTAINS_PATH").
+		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}).AddRow("app", "10.%"))
+
+	err = DumpUserAccounts(context.Background(), db, mysql8, cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	data, err := os.ReadFile(cfg.DumpFile)
+	assert.NoError(t, err)
+	var accounts []account.Account
+	assert.NoError(t, json.Unmarshal(data, &accounts))
+	assert.Len(t, accounts, 1)
+	assert.Equal(t, 100, accounts[0].MaxQueriesPerHour)
+	assert.Equal(t, 3, accounts[0].FailedLoginAttempts)
+	assert.Equal(t, "UNBOUNDED", accounts[0].PasswordLockTime)
+	assert.Equal(t, map[string]any{"team": "payments"}, accounts[0].Attributes)
+	assert.True(t, accounts[0].SecondaryPassword)
+	assert.Equal(t, "`shop`.*", accounts[0].Grants[0].Level())
+}
+
+func TestDumpUserAccounts_PtLikeFactors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	cfg := &config.Config{
+		Format:   "pt-like",
+		DumpFile: t.TempDir() + "/ptlike.sql",
+	}
+
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE user NOT IN").
+		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}).AddRow("mfa", "%"))
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE JSON_CONTAINS_PATH").
+		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}))
+	mock.ExpectExec("SET print_identified_with_as_hex = 1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SHOW CREATE USER `mfa`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Create User"}).
+			AddRow("CREATE USER `mfa`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24412430303524 AND IDENTIFIED WITH 'authentication_ldap_sasl' AS 0x75696431 AND IDENTIFIED WITH 'authentication_fido' REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK"))
+	mock.ExpectQuery("SHOW GRANTS FOR `mfa`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Grants"}).AddRow("GRANT USAGE ON *.* TO `mfa`@`%`"))
+
+	err = DumpUserAccounts(context.Background(), db, mysql8, cfg)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(cfg.DumpFile)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Equal(t, []string{
+		"-- Grants for 'mfa'@'%'",
+		"CREATE USER IF NOT EXISTS `mfa`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24412430303524 AND IDENTIFIED WITH 'authentication_ldap_sasl' AS 0x75696431 AND IDENTIFIED WITH 'authentication_fido';",
+		"ALTER USER `mfa`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24412430303524 REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK;",
+		"ALTER USER `mfa`@`%` MODIFY 2 FACTOR IDENTIFIED WITH 'authentication_ldap_sasl' AS 0x75696431 MODIFY 3 FACTOR IDENTIFIED WITH 'authentication_fido';",
+		"GRANT USAGE ON *.* TO `mfa`@`%`;",
+	}, lines[2:])
+
+	// the dump replays to the account it was taken from
+	accounts, err := account.ParseSQL(string(data))
+	assert.NoError(t, err)
+	assert.Len(t, accounts[0].Factors, 2)
+}
+
+func TestDumpUserAccounts_ImportWithoutHex(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	cfg := &config.Config{
+		OnlyUser: "legacy",
+		Format:   "import",
+		DumpFile: t.TempDir() + "/import.sql",
+	}
+
+	// neither print_identified_with_as_hex nor User_attributes exist on 5.7
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE user = ?").
+		WithArgs("legacy").
+		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}).AddRow("legacy", "%"))
+	mock.ExpectQuery("SHOW CREATE USER `legacy`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Create User"}).
+			AddRow("CREATE USER 'legacy'@'%' IDENTIFIED WITH 'mysql_native_password' AS '*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19' REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK"))
+	mock.ExpectQuery("SELECT authentication_string FROM mysql.user WHERE user = \\? AND host = \\?").
+		WithArgs("legacy", "%").
+		WillReturnRows(sqlmock.NewRows([]string{"authentication_string"}).AddRow([]byte("*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19")))
+	mock.ExpectQuery("SHOW GRANTS FOR `legacy`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Grants"}).AddRow("GRANT USAGE ON *.* TO 'legacy'@'%'"))
+
+	err = DumpUserAccounts(context.Background(), db, NewServerInfo("5.7.44-log", "MySQL Community Server (GPL)"), cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	data, err := os.ReadFile(cfg.DumpFile)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "CREATE USER IF NOT EXISTS `legacy`@`%` IDENTIFIED WITH 'mysql_native_password' AS 0x2A32343730433043303644454534324644313631384242393930303541444341324543394431453139 REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK;")
+}
+
+func TestDumpUserAccounts_57BinaryAuthString(t *testing.T) {
+	// a sha256_password hash holds a random salt, here with a quote and a
+	// byte that is not valid UTF-8, which SHOW CREATE USER on 5.7 prints as
+	// it is stored
+	stored := []byte("$5$a'b\xff\x01cdefghijklmnopqrst$ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789abcdefg")
+	printed := "CREATE USER 'svc'@'%' IDENTIFIED WITH 'sha256_password' AS '$5$a'b\ufffd\x01cdefghijklmnopqrst$ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789abcdefg' REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK"
+	want := "`svc`@`%` IDENTIFIED WITH 'sha256_password' AS 0x" + strings.ToUpper(hex.EncodeToString(stored)) + " REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK"
+
+	for _, format := range []string{"import", "pt-like"} {
+		t.Run(format, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			cfg := &config.Config{OnlyUser: "svc", Format: format, DumpFile: t.TempDir() + "/dump.sql"}
+			mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE user = ?").
+				WithArgs("svc").
+				WillReturnRows(sqlmock.NewRows([]string{"user", "host"}).AddRow("svc", "%"))
+			mock.ExpectQuery("SHOW CREATE USER `svc`@`%`").
+				WillReturnRows(sqlmock.NewRows([]string{"Create User"}).AddRow(printed))
+			mock.ExpectQuery("SELECT authentication_string FROM mysql.user").
+				WithArgs("svc", "%").
+				WillReturnRows(sqlmock.NewRows([]string{"authentication_string"}).AddRow(stored))
+			mock.ExpectQuery("SHOW GRANTS FOR `svc`@`%`").
+				WillReturnRows(sqlmock.NewRows([]string{"Grants"}).AddRow("GRANT SELECT ON `app`.* TO 'svc'@'%'"))
+
+			err = DumpUserAccounts(context.Background(), db, NewServerInfo("5.7.44-log", "MySQL Community Server (GPL)"), cfg)
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+
+			data, err := os.ReadFile(cfg.DumpFile)
+			assert.NoError(t, err)
+			if format == "import" {
+				assert.Contains(t, string(data), "CREATE USER IF NOT EXISTS "+want+";\n")
+			} else {
+				assert.Contains(t, string(data), "ALTER USER "+want+";\n")
+			}
+
+			// the dump replays to the stored hash
+			accounts, err := account.ParseSQL(string(data))
+			assert.NoError(t, err)
+			assert.Equal(t, stored, accounts[0].AuthBytes())
+		})
+	}
+}
+
+// expectMariaDB mocks a MariaDB 10.6 server with the role app_read and the
+// user app, using the output of SHOW CREATE USER and SHOW GRANTS
+func expectMariaDB(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE is_role = 'N' AND user NOT IN \\('mariadb.sys'\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"User", "Host"}).AddRow("app", "%"))
+	mock.ExpectQuery("SELECT user FROM mysql.user WHERE is_role = 'Y'").
+		WillReturnRows(sqlmock.NewRows([]string{"User"}).AddRow("app_read"))
+	mock.ExpectQuery("SHOW GRANTS FOR `app_read`").
+		WillReturnRows(sqlmock.NewRows([]string{"Grants for app_read"}).
+			AddRow("GRANT USAGE ON *.* TO `app_read`").
+			AddRow("GRANT SELECT ON `shop`.* TO `app_read`"))
+	mock.ExpectQuery("SHOW CREATE USER `app`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"CREATE USER for app@%"}).
+			AddRow("CREATE USER `app`@`%` IDENTIFIED VIA ed25519 USING 'ZIgUREUg5PVgQ6LskhXmO+eZLS0nC8be6HPjYWR4YJY' OR unix_socket REQUIRE SSL WITH MAX_USER_CONNECTIONS 20"))
+	mock.ExpectQuery("SHOW GRANTS FOR `app`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Grants for app@%"}).
+			AddRow("GRANT USAGE ON *.* TO `app`@`%` IDENTIFIED VIA ed25519 USING 'ZIgUREUg5PVgQ6LskhXmO+eZLS0nC8be6HPjYWR4YJY' OR unix_socket REQUIRE SSL WITH MAX_USER_CONNECTIONS 20").
+			AddRow("GRANT INSERT, UPDATE ON `shop`.`orders` TO `app`@`%`").
+			AddRow("GRANT `app_read` TO `app`@`%`").
+			AddRow("SET DEFAULT ROLE `app_read` FOR `app`@`%`"))
+}
+
+func TestDumpUserAccounts_MariaDBImport(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	cfg := &config.Config{Format: "import", DumpFile: t.TempDir() + "/mariadb.sql"}
+	expectMariaDB(mock)
+
+	// no print_identified_with_as_hex and no secondary passwords on MariaDB
+	err = DumpUserAccounts(context.Background(), db, mariadb106, cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	data, err := os.ReadFile(cfg.DumpFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "-- CREATE ROLE IF NOT EXISTS for app_read: \n"+
+		"CREATE ROLE IF NOT EXISTS `app_read`;\n"+
+		"GRANT USAGE ON *.* TO `app_read`;\n"+
+		"GRANT SELECT ON `shop`.* TO `app_read`;\n"+
+		"-- CREATE USER IF NOT EXISTS for app@%: \n"+
+		"CREATE USER IF NOT EXISTS `app`@`%` IDENTIFIED VIA ed25519 USING 'ZIgUREUg5PVgQ6LskhXmO+eZLS0nC8be6HPjYWR4YJY' OR unix_socket REQUIRE SSL WITH MAX_USER_CONNECTIONS 20;\n"+
+		"GRANT USAGE ON *.* TO `app`@`%` IDENTIFIED VIA ed25519 USING 'ZIgUREUg5PVgQ6LskhXmO+eZLS0nC8be6HPjYWR4YJY' OR unix_socket REQUIRE SSL WITH MAX_USER_CONNECTIONS 20;\n"+
+		"GRANT INSERT, UPDATE ON `shop`.`orders` TO `app`@`%`;\n"+
+		"GRANT `app_read` TO `app`@`%`;\n"+
+		"SET DEFAULT ROLE `app_read` FOR `app`@`%`;\n", string(data))
+
+	// the dump replays to the accounts it was taken from
+	accounts, err := account.ParseSQL(string(data))
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 2)
+	assert.Equal(t, []account.Factor{{Plugin: "unix_socket"}}, accounts[0].Alternatives)
+	assert.Equal(t, []string{"`app_read`@`%`"}, accounts[0].DefaultRoles)
+	assert.True(t, accounts[1].Role)
+}
+
+func TestDumpUserAccounts_MariaDBPtLike(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	cfg := &config.Config{Format: "pt-like", DumpFile: t.TempDir() + "/mariadb.sql"}
+	expectMariaDB(mock)
+
+	err = DumpUserAccounts(context.Background(), db, mariadb106, cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	data, err := os.ReadFile(cfg.DumpFile)
+	assert.NoError(t, err)
+	content := string(data)
+	assert.Contains(t, content, "-- Role 'app_read'\nCREATE ROLE IF NOT EXISTS `app_read`;\n-- Grants for role 'app_read'\nGRANT USAGE ON *.* TO `app_read`;\n")
+	assert.Contains(t, content, "CREATE USER IF NOT EXISTS `app`@`%`;\n"+
+		"ALTER USER `app`@`%` IDENTIFIED VIA ed25519 USING 'ZIgUREUg5PVgQ6LskhXmO+eZLS0nC8be6HPjYWR4YJY' OR unix_socket REQUIRE SSL WITH MAX_USER_CONNECTIONS 20 ACCOUNT UNLOCK;\n")
+	assert.Contains(t, content, "GRANT `app_read` TO `app`@`%`;\nSET DEFAULT ROLE `app_read` FOR `app`@`%`;\n")
+	assert.NotContains(t, content, "0x")
+	assert.NotContains(t, content, "DEFAULT ROLE `app_read`@`%`")
+}
+
+func TestDumpUserAccounts_TargetVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	cfg := &config.Config{
+		Format:        "import",
+		TargetVersion: "8.4",
+		DumpFile:      t.TempDir() + "/import.sql",
+	}
+
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE user NOT IN").
+		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}).AddRow("admin", "%").AddRow("app", "10.%"))
+	mock.ExpectQuery("SHOW CREATE USER `admin`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Create User"}).
+			AddRow("CREATE USER 'admin'@'%' IDENTIFIED WITH 'mysql_native_password' AS '*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19' REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK"))
+	mock.ExpectQuery("SELECT authentication_string FROM mysql.user").
+		WithArgs("admin", "%").
+		WillReturnRows(sqlmock.NewRows([]string{"authentication_string"}).AddRow([]byte("*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19")))
+	mock.ExpectQuery("SHOW GRANTS FOR `admin`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Grants"}).
+			AddRow("GRANT PROCESS, SUPER ON *.* TO 'admin'@'%'"))
+	mock.ExpectQuery("SHOW CREATE USER `app`@`10.%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Create User"}).
+			AddRow("CREATE USER 'app'@'10.%' IDENTIFIED WITH 'sha256_password' REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK"))
+	mock.ExpectQuery("SELECT authentication_string FROM mysql.user").
+		WithArgs("app", "10.%").
+		WillReturnRows(sqlmock.NewRows([]string{"authentication_string"}).AddRow([]byte{}))
+	mock.ExpectQuery("SHOW GRANTS FOR `app`@`10.%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Grants"}).
+			AddRow("GRANT USAGE ON *.* TO 'app'@'10.%' IDENTIFIED BY PASSWORD '*6BB4837EB74329105EE4568DDA7DC67ED2CA2AD9'").
+			AddRow("GRANT SELECT ON `shop`.* TO 'app'@'10.%'"))
+
+	err = DumpUserAccounts(context.Background(), db, NewServerInfo("5.7.44-log", "MySQL Community Server (GPL)"), cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	data, err := os.ReadFile(cfg.DumpFile)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Equal(t, "-- Accounts dumped by go-pass from MySQL 5.7.44, translated for MySQL 8.4", lines[0])
+	assert.Contains(t, lines[1], "-- warning: `admin`@`%`: mysql_native_password is disabled by default as of 8.4")
+	assert.Contains(t, lines[2], "-- warning: `admin`@`%`: SUPER on *.* replaced with BINLOG_ADMIN")
+	assert.Contains(t, lines[3], "-- warning: `app`@`10.%`: mysql_native_password is disabled by default as of 8.4")
+	assert.Equal(t, []string{
+		"CREATE USER IF NOT EXISTS `admin`@`%` IDENTIFIED WITH 'mysql_native_password' AS 0x2A32343730433043303644454534324644313631384242393930303541444341324543394431453139 REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK;",
+		// the hash printed by GRANT ... IDENTIFIED BY PASSWORD moves to CREATE USER
+		"CREATE USER IF NOT EXISTS `app`@`10.%` IDENTIFIED WITH 'mysql_native_password' AS 0x2A36424234383337454237343332393130354545343536384444413744433637454432434132414439 REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK;",
+	}, lines[4:6])
+	assert.True(t, strings.HasPrefix(lines[6], "GRANT PROCESS, BINLOG_ADMIN, "), lines[6])
+	assert.NotContains(t, lines[6], "SUPER")
+	assert.NotContains(t, lines[6], "SET_USER_ID")
+	assert.Equal(t, "GRANT SELECT ON `shop`.* TO `app`@`10.%`;", lines[7])
+	assert.Len(t, lines, 8)
+}
+
+func TestDumpUserAccounts_TargetProfile(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	cfg := &config.Config{
+		Format:        "import",
+		TargetProfile: "rds",
+		DumpFile:      t.TempDir() + "/import.sql",
+	}
+
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE user NOT IN").
+		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}).AddRow("admin", "%").AddRow("rdsadmin", "localhost"))
+	mock.ExpectExec("SET print_identified_with_as_hex = 1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectUserAttributes(mock)
+	mock.ExpectQuery("SHOW CREATE USER `admin`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Create User"}).
+			AddRow("CREATE USER `admin`@`%` IDENTIFIED WITH 'caching_sha2_password' REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK"))
+	mock.ExpectQuery("SHOW GRANTS FOR `admin`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Grants"}).
+			AddRow("GRANT PROCESS, FILE, SUPER ON *.* TO `admin`@`%`").
+			AddRow("GRANT ALL PRIVILEGES ON `shop`.* TO `admin`@`%`"))
+	mock.ExpectQuery("SHOW CREATE USER `rdsadmin`@`localhost`").
+		WillReturnRows(sqlmock.NewRows([]string{"Create User"}).
+			AddRow("CREATE USER `rdsadmin`@`localhost` IDENTIFIED WITH 'mysql_native_password' REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT LOCK"))
+	mock.ExpectQuery("SHOW GRANTS FOR `rdsadmin`@`localhost`").
+		WillReturnRows(sqlmock.NewRows([]string{"Grants"}).
+			AddRow("GRANT ALL PRIVILEGES ON *.* TO `rdsadmin`@`localhost` WITH GRANT OPTION"))
+	mock.ExpectExec("SET print_identified_with_as_hex = 0").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = DumpUserAccounts(context.Background(), db, mysql8, cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	data, err := os.ReadFile(cfg.DumpFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "-- Accounts dumped by go-pass from MySQL 8.0.36, adjusted for rds (Amazon RDS for MySQL)\n"+
+		"-- adjusted: `admin`@`%`: FILE, SUPER on *.* not allowed on rds, removed\n"+
+		"-- adjusted: `rdsadmin`@`localhost`: reserved on rds, skipped\n"+
+		"CREATE USER IF NOT EXISTS `admin`@`%` IDENTIFIED WITH 'caching_sha2_password' REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK;\n"+
+		"GRANT PROCESS ON *.* TO `admin`@`%`;\n"+
+		"GRANT ALL PRIVILEGES ON `shop`.* TO `admin`@`%`;\n", string(data))
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+)
+
+// SecondaryPasswords returns the accounts that hold a secondary password kept
+// by ALTER USER ... RETAIN CURRENT PASSWORD
+func SecondaryPasswords(ctx context.Context, q querier) (map[account.Name]bool, error) {
+	rows, err := q.QueryContext(ctx, "SELECT user, host FROM mysql.user WHERE JSON_CONTAINS_PATH(User_attributes, 'one', '$.additional_password')")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query secondary passwords: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[account.Name]bool)
+	for rows.Next() {
+		var n account.Name
+		if err := rows.Scan(&n.User, &n.Host); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		names[n] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return names, nil
+}
+
+// RetainCurrentPassword sets a new password for the account and keeps the
+// current one valid as secondary password, so clients can switch over
+// without downtime
+func RetainCurrentPassword(ctx context.Context, db *sql.DB, n account.Name, password string) error {
+	stmt := "ALTER USER " + n.ID() + " IDENTIFIED BY " + account.QuoteString(password) + " RETAIN CURRENT PASSWORD"
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		// the statement holds the password, so only the account is reported
+		return fmt.Errorf("failed to set new password for %s: %w", n.ID(), err)
+	}
+	return nil
+}
+
+// DiscardOldPassword drops the secondary password of the account
+func DiscardOldPassword(ctx context.Context, db *sql.DB, n account.Name) error {
+	if _, err := db.ExecContext(ctx, "ALTER USER "+n.ID()+" DISCARD OLD PASSWORD"); err != nil {
+		return fmt.Errorf("failed to discard old password for %s: %w", n.ID(), err)
+	}
+	return nil
+}
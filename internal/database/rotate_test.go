@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecondaryPasswords(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT user, host FROM mysql.user WHERE JSON_CONTAINS_PATH").
+		WillReturnRows(sqlmock.NewRows([]string{"user", "host"}).AddRow("app", "10.%"))
+
+	names, err := SecondaryPasswords(context.Background(), db)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, map[account.Name]bool{{User: "app", Host: "10.%"}: true}, names)
+}
+
+func TestRetainCurrentPassword(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	n := account.Name{User: "app", Host: "10.%"}
+	mock.ExpectExec("ALTER USER `app`@`10.%` IDENTIFIED BY 'n3w\\'pw' RETAIN CURRENT PASSWORD").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.NoError(t, RetainCurrentPassword(context.Background(), db, n, "n3w'pw"))
+
+	mock.ExpectExec("ALTER USER `app`@`10.%` IDENTIFIED BY 'secret' RETAIN CURRENT PASSWORD").
+		WillReturnError(errors.New("plugin does not support secondary passwords"))
+	err = RetainCurrentPassword(context.Background(), db, n, "secret")
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDiscardOldPassword(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec("ALTER USER `app`@`10.%` DISCARD OLD PASSWORD").WillReturnResult(sqlmock.NewResult(0, 0))
+	assert.NoError(t, DiscardOldPassword(context.Background(), db, account.Name{User: "app", Host: "10.%"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
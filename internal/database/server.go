@@ -0,0 +1,186 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+)
+
+// Flavor is the MySQL implementation a server runs
+type Flavor string
+
+const (
+	MySQL   Flavor = "MySQL"
+	Percona Flavor = "Percona Server"
+	MariaDB Flavor = "MariaDB"
+	Aurora  Flavor = "Aurora MySQL"
+)
+
+// Capability is an account management feature that depends on the server
+type Capability string
+
+const (
+	HexAuthStrings    Capability = "hex-auth-strings" // print_identified_with_as_hex
+	Roles             Capability = "roles"
+	DynamicPrivileges Capability = "dynamic-privileges"
+	DualPasswords     Capability = "dual-passwords"  // RETAIN CURRENT PASSWORD
+	UserAttributes    Capability = "user-attributes" // COMMENT and ATTRIBUTE
+	MultiFactorAuth   Capability = "multi-factor-auth"
+	PartialRevokes    Capability = "partial-revokes" // partial_revokes = ON
+)
+
+// serverVariables are the variables DetectServer reads. A MySQL server that
+// has print_identified_with_as_hex, activate_all_roles_on_login or
+// authentication_policy has the matching capability, whatever its version.
+var serverVariables = []string{
+	"aurora_version", "innodb_version", "print_identified_with_as_hex",
+	"activate_all_roles_on_login", "authentication_policy", "partial_revokes",
+}
+
+// capabilityVariables holds the variable that shows a capability on MySQL,
+// Percona Server and Aurora
+var capabilityVariables = map[Capability]string{
+	HexAuthStrings:  "print_identified_with_as_hex",
+	Roles:           "activate_all_roles_on_login",
+	MultiFactorAuth: "authentication_policy",
+}
+
+// capabilities holds the first version of each flavor with a capability,
+// for the capabilities no variable shows and for servers whose variables
+// were not read. Flavors without an entry lack it; Aurora uses the MySQL
+// version it is compatible with.
+var capabilities = map[Capability]map[Flavor]string{
+	HexAuthStrings:    {MySQL: "8.0.17", Percona: "8.0.17"},
+	Roles:             {MySQL: "8.0.0", Percona: "8.0.0", MariaDB: "10.0.5"},
+	DynamicPrivileges: {MySQL: "8.0.0", Percona: "8.0.0"},
+	DualPasswords:     {MySQL: "8.0.14", Percona: "8.0.14"},
+	UserAttributes:    {MySQL: "8.0.21", Percona: "8.0.21"},
+	MultiFactorAuth:   {MySQL: "8.0.27", Percona: "8.0.27"},
+}
+
+// ServerInfo describes the server go-pass is connected to
+type ServerInfo struct {
+	Version string // VERSION()
+	Comment string // @@version_comment
+	Flavor  Flavor
+	Major   int
+	Minor   int
+	Patch   int
+	// Variables holds the serverVariables the server has, or nil when they
+	// were not read
+	Variables map[string]string
+}
+
+// DetectServer reads the version and the variables that show what the
+// server supports once after Connect
+func DetectServer(ctx context.Context, q querier) (*ServerInfo, error) {
+	var version, comment string
+	if err := q.QueryRowContext(ctx, "SELECT VERSION(), @@version_comment").Scan(&version, &comment); err != nil {
+		return nil, fmt.Errorf("failed to detect server version: %w", err)
+	}
+	s := NewServerInfo(version, comment)
+
+	query := "SHOW VARIABLES WHERE Variable_name IN ('" + strings.Join(serverVariables, "', '") + "')"
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read server variables: %w", err)
+	}
+	defer rows.Close()
+	s.Variables = map[string]string{}
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan server variable: %w", err)
+		}
+		s.Variables[strings.ToLower(name)] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read server variables: %w", err)
+	}
+
+	if _, ok := s.Variables["aurora_version"]; ok && s.Flavor != MariaDB {
+		s.Flavor = Aurora
+		// VERSION() may be 8.0.mysql_aurora.3.05.2, without the MySQL
+		// patch release; innodb_version has it
+		if v, ok := s.Variables["innodb_version"]; ok && s.Patch == 0 {
+			s.Major, s.Minor, s.Patch = parseVersion(v)
+		}
+	}
+	return s, nil
+}
+
+// NewServerInfo derives the flavor and version number from VERSION() and
+// @@version_comment, without reading any variables
+func NewServerInfo(version, comment string) *ServerInfo {
+	s := &ServerInfo{Version: version, Comment: comment, Flavor: MySQL}
+	number, _, _ := strings.Cut(version, "-")
+	s.Major, s.Minor, s.Patch = parseVersion(number)
+
+	switch {
+	case strings.Contains(version, "MariaDB"):
+		s.Flavor = MariaDB
+	case strings.Contains(version, "mysql_aurora"):
+		// 8.0.mysql_aurora.3.05.2 has no MySQL patch release
+		s.Flavor = Aurora
+	case strings.Contains(strings.ToLower(comment), "percona"):
+		s.Flavor = Percona
+	}
+	return s
+}
+
+func parseVersion(v string) (major, minor, patch int) {
+	parts := strings.SplitN(v, ".", 3)
+	nums := make([]int, 3)
+	for i, p := range parts {
+		nums[i], _ = strconv.Atoi(p)
+	}
+	return nums[0], nums[1], nums[2]
+}
+
+// AtLeast reports whether the server version is at least major.minor.patch
+func (s *ServerInfo) AtLeast(major, minor, patch int) bool {
+	if s.Major != major {
+		return s.Major > major
+	}
+	if s.Minor != minor {
+		return s.Minor > minor
+	}
+	return s.Patch >= patch
+}
+
+// Has reports whether the server supports a capability. Once the variables
+// are read, a capability a variable shows is decided by that variable.
+func (s *ServerInfo) Has(c Capability) bool {
+	if c == PartialRevokes {
+		return strings.EqualFold(s.Variables["partial_revokes"], "ON")
+	}
+	if name, ok := capabilityVariables[c]; ok && s.Variables != nil && s.Flavor != MariaDB {
+		_, ok := s.Variables[name]
+		return ok
+	}
+	flavor := s.Flavor
+	if flavor == Aurora {
+		flavor = MySQL
+	}
+	min, ok := capabilities[c][flavor]
+	if !ok {
+		return false
+	}
+	return s.AtLeast(parseVersion(min))
+}
+
+// Dialect returns the SQL syntax accounts of the server are rendered in
+func (s *ServerInfo) Dialect() account.Dialect {
+	if s.Flavor == MariaDB {
+		return account.MariaDB
+	}
+	return account.MySQL
+}
+
+// String returns the flavor and version for log messages
+func (s *ServerInfo) String() string {
+	return fmt.Sprintf("%s %d.%d.%d", s.Flavor, s.Major, s.Minor, s.Patch)
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// mysql8 is the server most tests run against
+var mysql8 = NewServerInfo("8.0.36", "MySQL Community Server - GPL")
+
+// mariadb106 runs the MariaDB tests
+var mariadb106 = NewServerInfo("10.6.16-MariaDB-log", "MariaDB Server")
+
+func TestDetectServer(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT VERSION\\(\\), @@version_comment").
+		WillReturnRows(sqlmock.NewRows([]string{"VERSION()", "@@version_comment"}).AddRow("8.0.36-28", "Percona Server (GPL), Release 28, Revision 47601f19"))
+	mock.ExpectQuery("SHOW VARIABLES WHERE Variable_name IN \\('aurora_version', 'innodb_version', 'print_identified_with_as_hex', .*\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("activate_all_roles_on_login", "OFF").
+			AddRow("innodb_version", "8.0.36-28").
+			AddRow("partial_revokes", "ON").
+			AddRow("print_identified_with_as_hex", "OFF"))
+
+	srv, err := DetectServer(context.Background(), db)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, Percona, srv.Flavor)
+	assert.Equal(t, "Percona Server 8.0.36", srv.String())
+	assert.True(t, srv.Has(HexAuthStrings))
+	assert.True(t, srv.Has(Roles))
+	assert.True(t, srv.Has(PartialRevokes))
+	// authentication_policy is missing, so the version is not consulted
+	assert.False(t, srv.Has(MultiFactorAuth))
+	assert.True(t, srv.Has(DualPasswords))
+}
+
+func TestDetectServer_Aurora(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT VERSION\\(\\), @@version_comment").
+		WillReturnRows(sqlmock.NewRows([]string{"VERSION()", "@@version_comment"}).AddRow("8.0.mysql_aurora.3.05.2", "Source distribution"))
+	mock.ExpectQuery("SHOW VARIABLES").
+		WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("activate_all_roles_on_login", "OFF").
+			AddRow("aurora_version", "3.05.2").
+			AddRow("innodb_version", "8.0.32").
+			AddRow("partial_revokes", "OFF").
+			AddRow("print_identified_with_as_hex", "OFF"))
+
+	srv, err := DetectServer(context.Background(), db)
+	assert.NoError(t, err)
+	assert.Equal(t, "Aurora MySQL 8.0.32", srv.String())
+	assert.True(t, srv.Has(HexAuthStrings))
+	assert.True(t, srv.Has(UserAttributes))
+	assert.False(t, srv.Has(PartialRevokes))
+
+	// Aurora with a plain MySQL VERSION()
+	mock.ExpectQuery("SELECT VERSION\\(\\), @@version_comment").
+		WillReturnRows(sqlmock.NewRows([]string{"VERSION()", "@@version_comment"}).AddRow("5.7.12", "MySQL Community Server (GPL)"))
+	mock.ExpectQuery("SHOW VARIABLES").
+		WillReturnRows(sqlmock.NewRows([]string{"Variable_name", "Value"}).
+			AddRow("aurora_version", "2.11.2").
+			AddRow("innodb_version", "5.7.12"))
+	srv, err = DetectServer(context.Background(), db)
+	assert.NoError(t, err)
+	assert.Equal(t, Aurora, srv.Flavor)
+	assert.False(t, srv.Has(HexAuthStrings))
+	assert.False(t, srv.Has(Roles))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServerInfo_Capabilities(t *testing.T) {
+	all := []Capability{HexAuthStrings, Roles, DynamicPrivileges, DualPasswords, UserAttributes, MultiFactorAuth}
+	tests := []struct {
+		version string
+		comment string
+		flavor  Flavor
+		has     []Capability
+	}{
+		{"8.4.3", "MySQL Community Server - GPL", MySQL, all},
+		{"8.0.26-log", "MySQL Community Server - GPL", MySQL, []Capability{HexAuthStrings, Roles, DynamicPrivileges, DualPasswords, UserAttributes}},
+		{"8.0.16", "MySQL Community Server - GPL", MySQL, []Capability{Roles, DynamicPrivileges, DualPasswords}},
+		{"5.7.44-log", "MySQL Community Server (GPL)", MySQL, nil},
+		{"8.0.mysql_aurora.3.05.2", "Source distribution", Aurora, []Capability{Roles, DynamicPrivileges}},
+		{"5.7.mysql_aurora.2.11.2", "Source distribution", Aurora, nil},
+		{"10.11.6-MariaDB-log", "MariaDB Server", MariaDB, []Capability{Roles}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			srv := NewServerInfo(tt.version, tt.comment)
+			assert.Equal(t, tt.flavor, srv.Flavor)
+			for _, c := range all {
+				assert.Equal(t, slices.Contains(tt.has, c), srv.Has(c), c)
+			}
+		})
+	}
+}
+
+func TestServerInfo_AtLeast(t *testing.T) {
+	srv := NewServerInfo("8.0.27", "")
+	assert.True(t, srv.AtLeast(8, 0, 27))
+	assert.True(t, srv.AtLeast(5, 7, 44))
+	assert.False(t, srv.AtLeast(8, 0, 28))
+	assert.False(t, srv.AtLeast(8, 4, 0))
+}
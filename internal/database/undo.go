@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/plan"
+)
+
+// WriteUndo snapshots the accounts that statements modify and writes a script
+// to path that restores them. Nothing is written when the statements modify
+// no accounts, in which case it returns false.
+func WriteUndo(ctx context.Context, db *sql.DB, srv *ServerInfo, statements []string, path string) (bool, error) {
+	affected := plan.AffectedAccounts(statements)
+	if len(affected) == 0 {
+		return false, nil
+	}
+
+	snapshot, err := SnapshotAccounts(ctx, db, srv, affected)
+	if err != nil {
+		return false, fmt.Errorf("failed to snapshot accounts for undo: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString("-- Undo script generated by go-pass\n")
+	for _, stmt := range plan.Undo(statements, snapshot, srv.Dialect()) {
+		b.WriteString(stmt + ";\n")
+	}
+	// the script holds authentication strings, keep it private
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return false, fmt.Errorf("failed to write undo file: %w", err)
+	}
+	log.Printf("%s Wrote undo script for %d accounts to %s", green("[+]"), len(affected), path)
+	return true, nil
+}
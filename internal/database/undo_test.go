@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteUndo(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM mysql.user WHERE user = \\? AND host = \\?").
+		WithArgs("app", "%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM mysql.user WHERE user = \\? AND host = \\?").
+		WithArgs("new", "%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("SET print_identified_with_as_hex = 1").WillReturnResult(sqlmock.NewResult(0, 0))
+	expectUserAttributes(mock)
+	mock.ExpectQuery("SHOW CREATE USER `app`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Create User"}).
+			AddRow("CREATE USER `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0xAA REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK"))
+	mock.ExpectQuery("SHOW GRANTS FOR `app`@`%`").
+		WillReturnRows(sqlmock.NewRows([]string{"Grants"}).
+			AddRow("GRANT USAGE ON *.* TO `app`@`%`"))
+	mock.ExpectExec("SET print_identified_with_as_hex = 0").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	path := filepath.Join(t.TempDir(), "import.sql.undo.sql")
+	written, err := WriteUndo(context.Background(), db, mysql8, []string{
+		"ALTER USER `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0xBB",
+		"CREATE USER `new`@`%`",
+	}, path)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "-- Undo script generated by go-pass\n"+
+		"CREATE USER IF NOT EXISTS `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0xAA REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK;\n"+
+		"ALTER USER `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0xAA REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK;\n"+
+		"REVOKE ALL PRIVILEGES, GRANT OPTION FROM `app`@`%`;\n"+
+		"ALTER USER `app`@`%` DEFAULT ROLE NONE;\n"+
+		"DROP USER IF EXISTS `new`@`%`;\n", string(data))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestWriteUndo_ReadOnlyStatements(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	path := filepath.Join(t.TempDir(), "raw.sql.undo.sql")
+	written, err := WriteUndo(context.Background(), db, mysql8, []string{"SHOW CREATE USER `app`@`%`", "SHOW GRANTS FOR `app`@`%`"}, path)
+	assert.NoError(t, err)
+	assert.False(t, written)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
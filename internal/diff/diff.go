@@ -0,0 +1,294 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/fatih/color"
+)
+
+var green = color.New(color.FgGreen).SprintFunc()
+var red = color.New(color.FgRed).SprintFunc()
+var yellow = color.New(color.FgYellow).SprintFunc()
+
+// Kind identifies what differs between two accounts
+type Kind string
+
+const (
+	OnlySource             Kind = "only-in-source"
+	OnlyTarget             Kind = "only-in-target"
+	Plugin                 Kind = "plugin"
+	AuthString             Kind = "auth-string"
+	Factors                Kind = "factors"
+	FactorAuth             Kind = "factor-auth-string"
+	Privileges             Kind = "privileges"
+	PartialRevokes         Kind = "partial-revokes"
+	Roles                  Kind = "roles"
+	DefaultRoles           Kind = "default-roles"
+	Require                Kind = "require"
+	Lock                   Kind = "lock"
+	PasswordExpire         Kind = "password-expire"
+	ResourceLimits         Kind = "resource-limits"
+	PasswordHistory        Kind = "password-history"
+	PasswordReuseInterval  Kind = "password-reuse-interval"
+	PasswordRequireCurrent Kind = "password-require-current"
+	FailedLoginAttempts    Kind = "failed-login-attempts"
+	PasswordLockTime       Kind = "password-lock-time"
+	Comment                Kind = "comment"
+	Attributes             Kind = "attributes"
+)
+
+// Change is a single difference between the source and target account sets.
+// For set-valued kinds SourceOnly and TargetOnly list the members present on
+// one side only; for scalar kinds Source and Target hold both values.
+type Change struct {
+	Account    string   `json:"account"`
+	Kind       Kind     `json:"kind"`
+	Level      string   `json:"level,omitempty"`
+	Source     string   `json:"source,omitempty"`
+	Target     string   `json:"target,omitempty"`
+	SourceOnly []string `json:"source_only,omitempty"`
+	TargetOnly []string `json:"target_only,omitempty"`
+}
+
+// Report holds every change found between two account sets
+type Report struct {
+	Source  string   `json:"source"`
+	Target  string   `json:"target"`
+	Changes []Change `json:"changes"`
+}
+
+// HasDrift reports whether any difference was found
+func (r *Report) HasDrift() bool {
+	return len(r.Changes) > 0
+}
+
+// Compare returns the differences between the source and target accounts
+func Compare(sourceName string, source []account.Account, targetName string, target []account.Account) *Report {
+	r := &Report{Source: sourceName, Target: targetName, Changes: []Change{}}
+
+	targets := make(map[string]*account.Account, len(target))
+	for i := range target {
+		targets[target[i].ID()] = &target[i]
+	}
+	seen := make(map[string]bool, len(source))
+
+	for i := range source {
+		s := &source[i]
+		seen[s.ID()] = true
+		t, ok := targets[s.ID()]
+		if !ok {
+			r.Changes = append(r.Changes, Change{Account: s.ID(), Kind: OnlySource})
+			continue
+		}
+		r.Changes = append(r.Changes, compareAccount(s, t)...)
+	}
+	for i := range target {
+		if !seen[target[i].ID()] {
+			r.Changes = append(r.Changes, Change{Account: target[i].ID(), Kind: OnlyTarget})
+		}
+	}
+
+	sort.SliceStable(r.Changes, func(i, j int) bool {
+		return r.Changes[i].Account < r.Changes[j].Account
+	})
+	return r
+}
+
+func compareAccount(s, t *account.Account) []Change {
+	var changes []Change
+	scalar := func(kind Kind, sv, tv string) {
+		if sv != tv {
+			changes = append(changes, Change{Account: s.ID(), Kind: kind, Source: sv, Target: tv})
+		}
+	}
+	set := func(kind Kind, level string, sv, tv map[string]bool) {
+		sourceOnly, targetOnly := setDiff(sv, tv)
+		if len(sourceOnly) > 0 || len(targetOnly) > 0 {
+			changes = append(changes, Change{Account: s.ID(), Kind: kind, Level: level, SourceOnly: sourceOnly, TargetOnly: targetOnly})
+		}
+	}
+
+	scalar(Plugin, s.Plugin, t.Plugin)
+	if s.Plugin == t.Plugin && s.AuthString != t.AuthString {
+		// never print hashes, only that they differ
+		changes = append(changes, Change{Account: s.ID(), Kind: AuthString})
+	}
+
+	if sf, tf := factorPlugins(s.Factors), factorPlugins(t.Factors); sf != tf {
+		scalar(Factors, sf, tf)
+	} else if !slices.Equal(s.Factors, t.Factors) {
+		changes = append(changes, Change{Account: s.ID(), Kind: FactorAuth})
+	}
+
+	sp, tp := account.PrivilegeSet(s.Grants), account.PrivilegeSet(t.Grants)
+	for _, level := range levels(sp, tp) {
+		set(Privileges, level, sp[level], tp[level])
+	}
+	sr, tr := account.PrivilegeSet(s.Revokes), account.PrivilegeSet(t.Revokes)
+	for _, level := range levels(sr, tr) {
+		set(PartialRevokes, level, sr[level], tr[level])
+	}
+
+	set(Roles, "", roleSet(s.Roles), roleSet(t.Roles))
+	set(DefaultRoles, "", stringSet(s.DefaultRoles), stringSet(t.DefaultRoles))
+	scalar(Require, s.Require, t.Require)
+	scalar(Lock, lockState(s.Locked), lockState(t.Locked))
+	scalar(PasswordExpire, s.PasswordExpire, t.PasswordExpire)
+	scalar(ResourceLimits, s.ResourceClause(), t.ResourceClause())
+	scalar(PasswordHistory, orDefault(s.PasswordHistory), orDefault(t.PasswordHistory))
+	scalar(PasswordReuseInterval, orDefault(s.PasswordReuseInterval), orDefault(t.PasswordReuseInterval))
+	scalar(PasswordRequireCurrent, orDefault(s.PasswordRequireCurrent), orDefault(t.PasswordRequireCurrent))
+	scalar(FailedLoginAttempts, strconv.Itoa(s.FailedLoginAttempts), strconv.Itoa(t.FailedLoginAttempts))
+	scalar(PasswordLockTime, lockTime(s.PasswordLockTime), lockTime(t.PasswordLockTime))
+	scalar(Comment, s.Comment, t.Comment)
+	scalar(Attributes, attributes(s.Attributes), attributes(t.Attributes))
+	return changes
+}
+
+// orDefault treats an unset password option like DEFAULT, which is what
+// SHOW CREATE USER prints for it
+func orDefault(v string) string {
+	if v == "" {
+		return "DEFAULT"
+	}
+	return v
+}
+
+// lockTime treats an unset password lock time like 0, which disables it
+func lockTime(v string) string {
+	if v == "" {
+		return "0"
+	}
+	return v
+}
+
+// attributes renders user attributes as JSON, which sorts the keys
+func attributes(attrs map[string]any) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(attrs)
+	return string(data)
+}
+
+// factorPlugins lists the plugins of the additional factors
+func factorPlugins(factors []account.Factor) string {
+	plugins := make([]string, len(factors))
+	for i, f := range factors {
+		plugins[i] = f.Plugin
+	}
+	return strings.Join(plugins, ", ")
+}
+
+func setDiff(a, b map[string]bool) (onlyA, onlyB []string) {
+	for k := range a {
+		if !b[k] {
+			onlyA = append(onlyA, k)
+		}
+	}
+	for k := range b {
+		if !a[k] {
+			onlyB = append(onlyB, k)
+		}
+	}
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+	return onlyA, onlyB
+}
+
+func levels(a, b map[string]map[string]bool) []string {
+	all := make(map[string]bool)
+	for l := range a {
+		all[l] = true
+	}
+	for l := range b {
+		all[l] = true
+	}
+	return account.SortedKeys(all)
+}
+
+func roleSet(roles []account.RoleGrant) map[string]bool {
+	set := make(map[string]bool, len(roles))
+	for _, r := range roles {
+		key := r.Role
+		if r.AdminOption {
+			key += " WITH ADMIN OPTION"
+		}
+		set[key] = true
+	}
+	return set
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+func lockState(locked bool) string {
+	if locked {
+		return "LOCK"
+	}
+	return "UNLOCK"
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes a human-readable, colored report
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Comparing %s (source) with %s (target)\n", r.Source, r.Target)
+	if !r.HasDrift() {
+		fmt.Fprintln(&b, green("[+]"), "No differences found")
+	}
+	for _, c := range r.Changes {
+		switch c.Kind {
+		case OnlySource:
+			fmt.Fprintf(&b, "%s %s only exists on %s\n", red("-"), c.Account, r.Source)
+		case OnlyTarget:
+			fmt.Fprintf(&b, "%s %s only exists on %s\n", green("+"), c.Account, r.Target)
+		case AuthString:
+			fmt.Fprintf(&b, "%s %s authentication string differs\n", yellow("~"), c.Account)
+		case FactorAuth:
+			fmt.Fprintf(&b, "%s %s authentication string of an additional factor differs\n", yellow("~"), c.Account)
+		case Privileges, PartialRevokes, Roles, DefaultRoles:
+			what := strings.ReplaceAll(string(c.Kind), "-", " ")
+			if c.Level != "" {
+				what += " on " + c.Level
+			}
+			fmt.Fprintf(&b, "%s %s %s differ\n", yellow("~"), c.Account, what)
+			for _, v := range c.SourceOnly {
+				fmt.Fprintf(&b, "    %s %s (only on %s)\n", red("-"), v, r.Source)
+			}
+			for _, v := range c.TargetOnly {
+				fmt.Fprintf(&b, "    %s %s (only on %s)\n", green("+"), v, r.Target)
+			}
+		default:
+			fmt.Fprintf(&b, "%s %s %s differs: %s on %s, %s on %s\n", yellow("~"), c.Account, strings.ReplaceAll(string(c.Kind), "-", " "),
+				orNone(c.Source), r.Source, orNone(c.Target), r.Target)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func grant(schema string, privs ...string) account.Grant {
+	g := account.Grant{Schema: schema, Object: "*"}
+	for _, p := range privs {
+		g.Privileges = append(g.Privileges, account.Privilege{Name: p})
+	}
+	return g
+}
+
+func TestCompare_Identical(t *testing.T) {
+	accounts := []account.Account{{User: "app", Host: "%", Plugin: "caching_sha2_password", AuthString: "AA", Grants: []account.Grant{grant("shop", "SELECT")}}}
+	r := Compare("a", accounts, "b", accounts)
+	assert.False(t, r.HasDrift())
+}
+
+func TestCompare(t *testing.T) {
+	source := []account.Account{
+		{User: "app", Host: "%", Plugin: "caching_sha2_password", AuthString: "AA", PasswordExpire: "DEFAULT",
+			Grants:       []account.Grant{grant("*", "USAGE"), grant("shop", "SELECT", "INSERT")},
+			Roles:        []account.RoleGrant{{Role: "`reader`@`%`"}},
+			DefaultRoles: []string{"`reader`@`%`"}},
+		{User: "old", Host: "localhost"},
+		{User: "legacy", Host: "%", Plugin: "mysql_native_password", AuthString: "BB"},
+	}
+	target := []account.Account{
+		{User: "app", Host: "%", Plugin: "caching_sha2_password", AuthString: "CC", Locked: true, PasswordExpire: "NEVER",
+			Grants: []account.Grant{grant("shop", "SELECT", "DELETE"), grant("logs", "SELECT")}},
+		{User: "new", Host: "%"},
+		{User: "legacy", Host: "%", Plugin: "caching_sha2_password", AuthString: "DD"},
+	}
+
+	r := Compare("a", source, "b", target)
+	assert.True(t, r.HasDrift())
+
+	byKind := make(map[Kind][]Change)
+	for _, c := range r.Changes {
+		byKind[c.Kind] = append(byKind[c.Kind], c)
+	}
+	assert.Equal(t, "`old`@`localhost`", byKind[OnlySource][0].Account)
+	assert.Equal(t, "`new`@`%`", byKind[OnlyTarget][0].Account)
+	assert.Equal(t, Change{Account: "`legacy`@`%`", Kind: Plugin, Source: "mysql_native_password", Target: "caching_sha2_password"}, byKind[Plugin][0])
+	assert.Len(t, byKind[AuthString], 1)
+	assert.Equal(t, "`app`@`%`", byKind[AuthString][0].Account)
+
+	assert.Equal(t, []Change{
+		{Account: "`app`@`%`", Kind: Privileges, Level: "`logs`.*", TargetOnly: []string{"SELECT"}},
+		{Account: "`app`@`%`", Kind: Privileges, Level: "`shop`.*", SourceOnly: []string{"INSERT"}, TargetOnly: []string{"DELETE"}},
+	}, byKind[Privileges])
+	assert.Equal(t, []string{"`reader`@`%`"}, byKind[Roles][0].SourceOnly)
+	assert.Equal(t, []string{"`reader`@`%`"}, byKind[DefaultRoles][0].SourceOnly)
+	assert.Equal(t, "UNLOCK", byKind[Lock][0].Source)
+	assert.Equal(t, "LOCK", byKind[Lock][0].Target)
+	assert.Equal(t, "NEVER", byKind[PasswordExpire][0].Target)
+}
+
+func TestCompare_Factors(t *testing.T) {
+	ldap := account.Factor{Plugin: "authentication_ldap_sasl", AuthString: "01"}
+	fido := account.Factor{Plugin: "authentication_fido"}
+	source := []account.Account{
+		{User: "a", Host: "%", Factors: []account.Factor{ldap, fido}},
+		{User: "b", Host: "%", Factors: []account.Factor{ldap}},
+		{User: "c", Host: "%"},
+	}
+	target := []account.Account{
+		{User: "a", Host: "%", Factors: []account.Factor{ldap}},
+		{User: "b", Host: "%", Factors: []account.Factor{{Plugin: "authentication_ldap_sasl", AuthString: "02"}}},
+		{User: "c", Host: "%"},
+	}
+	assert.Equal(t, []Change{
+		{Account: "`a`@`%`", Kind: Factors, Source: "authentication_ldap_sasl, authentication_fido", Target: "authentication_ldap_sasl"},
+		{Account: "`b`@`%`", Kind: FactorAuth},
+	}, Compare("a", source, "b", target).Changes)
+}
+
+func TestCompare_AccountOptions(t *testing.T) {
+	source := []account.Account{
+		{User: "app", Host: "%", MaxQueriesPerHour: 100, PasswordHistory: "5", PasswordReuseInterval: "DEFAULT",
+			FailedLoginAttempts: 3, PasswordLockTime: "2", Comment: "billing", Attributes: map[string]any{"team": "core"}},
+		{User: "same", Host: "%", PasswordHistory: "DEFAULT", PasswordRequireCurrent: "DEFAULT", PasswordLockTime: "0"},
+	}
+	target := []account.Account{
+		{User: "app", Host: "%", MaxUserConnections: 10, PasswordRequireCurrent: "REQUIRED", PasswordLockTime: "UNBOUNDED",
+			Attributes: map[string]any{"team": "ops"}},
+		{User: "same", Host: "%"},
+	}
+	assert.Equal(t, []Change{
+		{Account: "`app`@`%`", Kind: ResourceLimits, Source: "WITH MAX_QUERIES_PER_HOUR 100", Target: "WITH MAX_USER_CONNECTIONS 10"},
+		{Account: "`app`@`%`", Kind: PasswordHistory, Source: "5", Target: "DEFAULT"},
+		{Account: "`app`@`%`", Kind: PasswordRequireCurrent, Source: "DEFAULT", Target: "REQUIRED"},
+		{Account: "`app`@`%`", Kind: FailedLoginAttempts, Source: "3", Target: "0"},
+		{Account: "`app`@`%`", Kind: PasswordLockTime, Source: "2", Target: "UNBOUNDED"},
+		{Account: "`app`@`%`", Kind: Comment, Source: "billing"},
+		{Account: "`app`@`%`", Kind: Attributes, Source: `{"team":"core"}`, Target: `{"team":"ops"}`},
+	}, Compare("a", source, "b", target).Changes)
+}
+
+func TestReport_Write(t *testing.T) {
+	color.NoColor = true
+	r := Compare("a", []account.Account{{User: "app", Host: "%", Grants: []account.Grant{grant("shop", "SELECT")}}},
+		"b", []account.Account{{User: "app", Host: "%"}, {User: "new", Host: "%"}})
+
+	var text bytes.Buffer
+	assert.NoError(t, r.WriteText(&text))
+	assert.Equal(t, "Comparing a (source) with b (target)\n"+
+		"~ `app`@`%` privileges on `shop`.* differ\n"+
+		"    - SELECT (only on a)\n"+
+		"+ `new`@`%` only exists on b\n", text.String())
+
+	var js bytes.Buffer
+	assert.NoError(t, r.WriteJSON(&js))
+	var decoded Report
+	assert.NoError(t, json.Unmarshal(js.Bytes(), &decoded))
+	assert.Equal(t, r, &decoded)
+}
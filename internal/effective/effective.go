@@ -0,0 +1,443 @@
+// Package effective computes what an account can actually do: the union of
+// its own grants and those of its active roles, less its partial revokes
+package effective
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+)
+
+// Direct is the source of privileges granted to the account itself
+const Direct = "direct"
+
+// schemaPrivileges are the privileges that can be granted on a schema; a
+// global grant of one of them covers every schema
+var schemaPrivileges = []string{
+	"SELECT", "INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "REFERENCES", "INDEX", "ALTER",
+	"CREATE TEMPORARY TABLES", "LOCK TABLES", "EXECUTE", "CREATE VIEW", "SHOW VIEW",
+	"CREATE ROUTINE", "ALTER ROUTINE", "EVENT", "TRIGGER",
+}
+
+// tablePrivileges are the privileges that can be granted on a table
+var tablePrivileges = []string{
+	"SELECT", "INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "REFERENCES", "INDEX", "ALTER",
+	"CREATE VIEW", "SHOW VIEW", "TRIGGER",
+}
+
+// routinePrivileges are the privileges that can be granted on a routine
+var routinePrivileges = []string{"EXECUTE", "ALTER ROUTINE"}
+
+// Object is a level privileges apply to. Schema is "*" for the global level
+// and Name is "*" for a whole schema; Type is TABLE, FUNCTION or PROCEDURE.
+type Object struct {
+	Type   string
+	Schema string
+	Name   string
+}
+
+// ObjectOf returns the object a grant is made on
+func ObjectOf(g account.Grant) Object {
+	o := Object{Type: g.ObjectType, Schema: g.Schema, Name: g.Object}
+	if o.Type == "" {
+		o.Type = "TABLE"
+	}
+	return o
+}
+
+// String renders the object the way GRANT does
+func (o Object) String() string {
+	g := account.Grant{ObjectType: o.Type, Schema: o.Schema, Object: o.Name}
+	if o.Type == "TABLE" {
+		g.ObjectType = ""
+	}
+	return g.Level()
+}
+
+// Global reports whether the object is *.*
+func (o Object) Global() bool {
+	return o.Schema == "*" && o.Type != "PROXY"
+}
+
+// applicable returns the privileges that exist on the object, or nil for
+// the global level, where every privilege exists
+func (o Object) applicable() []string {
+	switch {
+	case o.Global():
+		return nil
+	case o.Type == "FUNCTION", o.Type == "PROCEDURE":
+		return routinePrivileges
+	case o.Name == "*":
+		return schemaPrivileges
+	}
+	return tablePrivileges
+}
+
+// MatchSchema reports whether a schema name matches the schema of a grant,
+// which may use the LIKE wildcards _ and % unless they are escaped with \
+func MatchSchema(pattern, schema string) bool {
+	if pattern == schema {
+		return true
+	}
+	if !strings.ContainsAny(pattern, "_%") {
+		return false
+	}
+	return account.Like(pattern, schema)
+}
+
+// Privilege is a privilege held on an object and where it comes from
+type Privilege struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns,omitempty"`
+	Via     []string `json:"via"`
+}
+
+// String renders the privilege with its column list, if any
+func (p Privilege) String() string {
+	return account.Privilege{Name: p.Name, Columns: p.Columns}.String()
+}
+
+// Revoke is a privilege a partial revoke takes away on a schema
+type Revoke struct {
+	Name string `json:"name"`
+	By   string `json:"by"`
+}
+
+// Entry lists the privileges held on one object
+type Entry struct {
+	Object     string      `json:"object"`
+	Privileges []Privilege `json:"privileges"`
+	Revoked    []Revoke    `json:"revoked,omitempty"`
+}
+
+// Role is a role whose privileges an account holds
+type Role struct {
+	Role string `json:"role"`
+	// Via is the role it is granted to, or Direct for the account itself
+	Via string `json:"via"`
+}
+
+// Result is the privilege matrix of an account
+type Result struct {
+	Account string   `json:"account"`
+	Roles   []Role   `json:"roles"`
+	Missing []string `json:"missing_roles,omitempty"`
+	Entries []Entry  `json:"objects"`
+}
+
+// Principal is the account or one of its active roles
+type Principal struct {
+	Account *account.Account
+	Via     string
+}
+
+// Source returns Direct for the account itself, or the ID of the role
+func (p Principal) Source() string {
+	if p.Via == "" {
+		return Direct
+	}
+	return p.Account.ID()
+}
+
+// Resolver looks up the roles of accounts
+type Resolver struct {
+	byID map[string]*account.Account
+}
+
+// NewResolver indexes the accounts, which must include the roles that are
+// granted to them
+func NewResolver(accounts []account.Account) *Resolver {
+	r := &Resolver{byID: make(map[string]*account.Account, len(accounts))}
+	for i := range accounts {
+		r.byID[accounts[i].ID()] = &accounts[i]
+	}
+	return r
+}
+
+// Lookup returns the account with the quoted ID, or nil
+func (r *Resolver) Lookup(id string) *account.Account {
+	return r.byID[id]
+}
+
+// Principals returns the account followed by its active roles and the roles
+// granted to those, and the IDs of roles that are not among the accounts.
+// The default roles are active unless allRoles is set, as with
+// activate_all_roles_on_login.
+func (r *Resolver) Principals(a *account.Account, allRoles bool) ([]Principal, []string) {
+	principals := []Principal{{Account: a}}
+	var missing []string
+	seen := map[string]bool{a.ID(): true}
+
+	var active []string
+	if allRoles {
+		for _, rg := range a.Roles {
+			active = append(active, rg.Role)
+		}
+	} else {
+		active = a.DefaultRoles
+	}
+	type pending struct{ role, via string }
+	queue := make([]pending, 0, len(active))
+	for _, role := range active {
+		queue = append(queue, pending{role, Direct})
+	}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if seen[p.role] {
+			continue
+		}
+		seen[p.role] = true
+		role := r.byID[p.role]
+		if role == nil {
+			missing = append(missing, p.role)
+			continue
+		}
+		principals = append(principals, Principal{Account: role, Via: p.via})
+		// roles granted to a role are always active with it
+		for _, rg := range role.Roles {
+			queue = append(queue, pending{rg.Role, p.role})
+		}
+	}
+	return principals, missing
+}
+
+// Resolve computes the privilege matrix of the account with the given name
+func (r *Resolver) Resolve(name account.Name, allRoles bool) (*Result, error) {
+	a := r.byID[name.ID()]
+	if a == nil {
+		return nil, fmt.Errorf("account %s not found", name.ID())
+	}
+	principals, missing := r.Principals(a, allRoles)
+	res := &Result{Account: a.ID(), Roles: []Role{}, Missing: missing, Entries: []Entry{}}
+	for _, p := range principals[1:] {
+		res.Roles = append(res.Roles, Role{Role: p.Account.ID(), Via: p.Via})
+	}
+
+	for _, o := range Objects(principals) {
+		privs, revoked := On(principals, o)
+		if len(privs) == 0 && len(revoked) == 0 {
+			continue
+		}
+		res.Entries = append(res.Entries, Entry{Object: o.String(), Privileges: privs, Revoked: revoked})
+	}
+	return res, nil
+}
+
+// Objects returns every object the principals hold grants or partial
+// revokes on, starting with *.*
+func Objects(principals []Principal) []Object {
+	seen := map[Object]bool{}
+	objects := []Object{{Type: "TABLE", Schema: "*", Name: "*"}}
+	seen[objects[0]] = true
+	add := func(g account.Grant) {
+		o := ObjectOf(g)
+		if !seen[o] {
+			seen[o] = true
+			objects = append(objects, o)
+		}
+	}
+	for _, p := range principals {
+		for _, g := range p.Account.Grants {
+			add(g)
+		}
+		for _, g := range p.Account.Revokes {
+			add(g)
+		}
+	}
+	sort.SliceStable(objects[1:], func(i, j int) bool {
+		a, b := objects[1+i], objects[1+j]
+		if a.Schema != b.Schema {
+			return a.Schema < b.Schema
+		}
+		if (a.Name == "*") != (b.Name == "*") {
+			return a.Name == "*"
+		}
+		if a.Type != b.Type {
+			return a.Type > b.Type
+		}
+		return a.Name < b.Name
+	})
+	return objects
+}
+
+// covers reports whether a grant applies to the object: global grants
+// apply everywhere, schema grants to the schemas they match and the objects
+// in them
+func covers(g account.Grant, o Object) bool {
+	switch {
+	case g.ObjectType == "PROXY" || o.Type == "PROXY":
+		return g.ObjectType == o.Type && g.Object == o.Name
+	case g.Schema == "*":
+		return true
+	case o.Schema == "*":
+		return false
+	case g.Object == "*":
+		return MatchSchema(g.Schema, o.Schema)
+	}
+	return ObjectOf(g) == o
+}
+
+// On returns the privileges the principals hold on the object, and the
+// privileges partial revokes take away there
+func On(principals []Principal, o Object) ([]Privilege, []Revoke) {
+	var privs []Privilege
+	var revoked []Revoke
+	add := func(name string, columns []string, via string) {
+		i := slices.IndexFunc(privs, func(p Privilege) bool { return p.Name == name && slices.Equal(p.Columns, columns) })
+		if i < 0 {
+			privs = append(privs, Privilege{Name: name, Columns: columns})
+			i = len(privs) - 1
+		}
+		if !slices.Contains(privs[i].Via, via) {
+			privs[i].Via = append(privs[i].Via, via)
+		}
+	}
+
+	for _, p := range principals {
+		via := p.Source()
+		restricted := restrictions(p.Account, o)
+		for _, g := range p.Account.Grants {
+			if !covers(g, o) {
+				continue
+			}
+			global := g.Schema == "*" && g.ObjectType != "PROXY"
+			names := make([]account.Privilege, len(g.Privileges))
+			copy(names, g.Privileges)
+			if g.GrantOption {
+				names = append(names, account.Privilege{Name: "GRANT OPTION"})
+			}
+			for _, priv := range names {
+				if len(priv.Columns) > 0 {
+					// column privileges only show on their table
+					if ObjectOf(g) == o {
+						add(priv.Name, priv.Columns, via)
+					}
+					continue
+				}
+				for _, name := range expand(priv.Name, o) {
+					if global && restricted[name] {
+						if !slices.ContainsFunc(revoked, func(r Revoke) bool { return r.Name == name && r.By == via }) {
+							revoked = append(revoked, Revoke{Name: name, By: via})
+						}
+						continue
+					}
+					add(name, nil, via)
+				}
+			}
+		}
+	}
+
+	// a privilege on the whole object makes the column privileges redundant
+	privs = slices.DeleteFunc(privs, func(p Privilege) bool {
+		return len(p.Columns) > 0 && slices.ContainsFunc(privs, func(q Privilege) bool { return q.Name == p.Name && len(q.Columns) == 0 })
+	})
+	// a privilege that is revoked for one principal may be granted on the
+	// schema, or globally by another
+	revoked = slices.DeleteFunc(revoked, func(r Revoke) bool {
+		return slices.ContainsFunc(privs, func(p Privilege) bool { return p.Name == r.Name && len(p.Columns) == 0 })
+	})
+	sort.SliceStable(privs, func(i, j int) bool { return privs[i].String() < privs[j].String() })
+	return privs, revoked
+}
+
+// restrictions returns the privileges the partial revokes of the account
+// take away on the schema of the object
+func restrictions(a *account.Account, o Object) map[string]bool {
+	if o.Schema == "*" {
+		return nil
+	}
+	restricted := map[string]bool{}
+	for _, r := range a.Revokes {
+		if r.Schema != o.Schema {
+			continue
+		}
+		for _, p := range r.Privileges {
+			for _, name := range expand(p.Name, o) {
+				restricted[name] = true
+			}
+		}
+		if r.GrantOption {
+			restricted["GRANT OPTION"] = true
+		}
+	}
+	return restricted
+}
+
+// expand returns the privileges a granted privilege amounts to on the
+// object. ALL PRIVILEGES becomes every privilege of the object's level, and
+// privileges that do not exist there are left out.
+func expand(name string, o Object) []string {
+	applicable := o.applicable()
+	switch {
+	case name == "USAGE":
+		return nil
+	case applicable == nil, name == "GRANT OPTION":
+		return []string{name}
+	case name == "ALL PRIVILEGES":
+		return applicable
+	case slices.Contains(applicable, name):
+		return []string{name}
+	}
+	return nil
+}
+
+// WriteJSON writes the result as indented JSON
+func (res *Result) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+// WriteText writes the result as a table with one row per privilege
+func (res *Result) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Effective privileges of %s\n", res.Account)
+	if len(res.Roles) == 0 {
+		fmt.Fprintln(&b, "Active roles: none")
+	}
+	for _, r := range res.Roles {
+		if r.Via == Direct {
+			fmt.Fprintf(&b, "Active role: %s\n", r.Role)
+		} else {
+			fmt.Fprintf(&b, "Active role: %s (granted to %s)\n", r.Role, r.Via)
+		}
+	}
+	for _, m := range res.Missing {
+		fmt.Fprintf(&b, "Missing role: %s (not found, its privileges are not included)\n", m)
+	}
+	if len(res.Entries) == 0 {
+		fmt.Fprintln(&b, "No privileges (USAGE only)")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "OBJECT\tPRIVILEGE\tVIA")
+	for _, e := range res.Entries {
+		object := e.Object
+		for _, p := range e.Privileges {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", object, p, strings.Join(p.Via, ", "))
+			object = ""
+		}
+		for _, r := range e.Revoked {
+			by := "partial revoke"
+			if r.By != Direct {
+				by += " of " + r.By
+			}
+			fmt.Fprintf(tw, "%s\t-%s\t%s\n", object, r.Name, by)
+			object = ""
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,155 @@
+package effective
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/stretchr/testify/assert"
+)
+
+const accountsSQL = `
+CREATE ROLE 'reader', 'base';
+GRANT SELECT ON shop.* TO 'reader'@'%';
+` + "GRANT SELECT, INSERT ON `pay\\_%`.* TO 'reader'@'%';" + `
+GRANT 'base' TO 'reader';
+GRANT EXECUTE ON PROCEDURE shop.refund TO 'base'@'%';
+GRANT PROCESS ON *.* TO 'base'@'%';
+CREATE ROLE 'writer';
+GRANT INSERT, UPDATE ON shop.* TO 'writer'@'%';
+CREATE USER 'app'@'10.%' DEFAULT ROLE 'reader';
+GRANT 'reader', 'writer' TO 'app'@'10.%';
+GRANT UPDATE (status), SELECT (id, status) ON shop.orders TO 'app'@'10.%';
+GRANT SELECT ON shop.orders TO 'app'@'10.%';
+CREATE USER 'dba'@'localhost';
+GRANT ALL PRIVILEGES ON *.* TO 'dba'@'localhost' WITH GRANT OPTION;
+REVOKE INSERT, UPDATE, DELETE ON mysql.* FROM 'dba'@'localhost';
+GRANT 'missing' TO 'dba'@'localhost';
+SET DEFAULT ROLE 'missing' TO 'dba'@'localhost';
+`
+
+func resolve(t *testing.T, user string, allRoles bool) *Result {
+	t.Helper()
+	accounts, err := account.ParseSQL(accountsSQL)
+	assert.NoError(t, err)
+	name, err := account.ParseName(user)
+	assert.NoError(t, err)
+	res, err := NewResolver(accounts).Resolve(name, allRoles)
+	assert.NoError(t, err)
+	return res
+}
+
+// rows flattens the result to object, privilege and sources
+func rows(res *Result) [][]string {
+	var out [][]string
+	for _, e := range res.Entries {
+		for _, p := range e.Privileges {
+			row := []string{e.Object, p.String()}
+			out = append(out, append(row, p.Via...))
+		}
+		for _, r := range e.Revoked {
+			out = append(out, []string{e.Object, "-" + r.Name, r.By})
+		}
+	}
+	return out
+}
+
+func TestResolve_DefaultRoles(t *testing.T) {
+	res := resolve(t, "app@10.%", false)
+	assert.Equal(t, []Role{{Role: "`reader`@`%`", Via: Direct}, {Role: "`base`@`%`", Via: "`reader`@`%`"}}, res.Roles)
+	assert.Equal(t, [][]string{
+		{"*.*", "PROCESS", "`base`@`%`"},
+		{"`pay\\_%`.*", "INSERT", "`reader`@`%`"},
+		{"`pay\\_%`.*", "SELECT", "`reader`@`%`"},
+		{"`shop`.*", "SELECT", "`reader`@`%`"},
+		// the table privilege makes SELECT (id, status) redundant
+		{"`shop`.`orders`", "SELECT", Direct, "`reader`@`%`"},
+		{"`shop`.`orders`", "UPDATE (`status`)", Direct},
+		{"PROCEDURE `shop`.`refund`", "EXECUTE", "`base`@`%`"},
+	}, rows(res))
+}
+
+func TestResolve_AllRoles(t *testing.T) {
+	res := resolve(t, "app@10.%", true)
+	assert.Len(t, res.Roles, 3)
+	assert.Contains(t, rows(res), []string{"`shop`.`orders`", "UPDATE", "`writer`@`%`"})
+	assert.Contains(t, rows(res), []string{"`shop`.*", "INSERT", "`writer`@`%`"})
+	assert.NotContains(t, rows(res), []string{"`shop`.`orders`", "UPDATE (`status`)", Direct})
+}
+
+func TestResolve_PartialRevokes(t *testing.T) {
+	res := resolve(t, "dba@localhost", false)
+	assert.Empty(t, res.Roles)
+	assert.Equal(t, []string{"`missing`@`%`"}, res.Missing)
+
+	assert.Equal(t, "*.*", res.Entries[0].Object)
+	assert.Equal(t, []Privilege{{Name: "ALL PRIVILEGES", Via: []string{Direct}}, {Name: "GRANT OPTION", Via: []string{Direct}}}, res.Entries[0].Privileges)
+
+	mysql := res.Entries[1]
+	assert.Equal(t, "`mysql`.*", mysql.Object)
+	assert.Equal(t, []Revoke{{Name: "INSERT", By: Direct}, {Name: "UPDATE", By: Direct}, {Name: "DELETE", By: Direct}}, mysql.Revoked)
+	var names []string
+	for _, p := range mysql.Privileges {
+		names = append(names, p.Name)
+	}
+	assert.Contains(t, names, "SELECT")
+	assert.Contains(t, names, "GRANT OPTION")
+	assert.NotContains(t, names, "INSERT")
+	assert.NotContains(t, names, "ALL PRIVILEGES")
+}
+
+func TestResolve_NotFound(t *testing.T) {
+	accounts, err := account.ParseSQL(accountsSQL)
+	assert.NoError(t, err)
+	_, err = NewResolver(accounts).Resolve(account.Name{User: "nobody", Host: "%"}, false)
+	assert.EqualError(t, err, "account `nobody`@`%` not found")
+}
+
+func TestMatchSchema(t *testing.T) {
+	for _, c := range []struct {
+		pattern, schema string
+		match           bool
+	}{
+		{"shop", "shop", true},
+		{"shop", "shop2", false},
+		{"pay\\_%", "pay_eu", true},
+		{"pay\\_%", "payroll", false},
+		{"pay_%", "payroll", true},
+		{"app_", "app1", true},
+		{"app_", "app", false},
+		{"%", "anything", true},
+	} {
+		assert.Equal(t, c.match, MatchSchema(c.pattern, c.schema), "%s %s", c.pattern, c.schema)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, resolve(t, "app@10.%", false).WriteText(&b))
+	assert.Equal(t, "Effective privileges of `app`@`10.%`\n"+
+		"Active role: `reader`@`%`\n"+
+		"Active role: `base`@`%` (granted to `reader`@`%`)\n"+
+		"OBJECT                     PRIVILEGE          VIA\n"+
+		"*.*                        PROCESS            `base`@`%`\n"+
+		"`pay\\_%`.*                 INSERT             `reader`@`%`\n"+
+		"                           SELECT             `reader`@`%`\n"+
+		"`shop`.*                   SELECT             `reader`@`%`\n"+
+		"`shop`.`orders`            SELECT             direct, `reader`@`%`\n"+
+		"                           UPDATE (`status`)  direct\n"+
+		"PROCEDURE `shop`.`refund`  EXECUTE            `base`@`%`\n", b.String())
+
+	b.Reset()
+	assert.NoError(t, resolve(t, "dba@localhost", false).WriteText(&b))
+	assert.Contains(t, b.String(), "Missing role: `missing`@`%` (not found, its privileges are not included)\n")
+	assert.Contains(t, b.String(), "-INSERT                  partial revoke\n")
+}
+
+func TestWriteJSON(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, resolve(t, "app@10.%", false).WriteJSON(&b))
+	var decoded map[string]any
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &decoded))
+	assert.Equal(t, "`app`@`10.%`", decoded["account"])
+	assert.Len(t, decoded["objects"], 5)
+}
@@ -0,0 +1,237 @@
+package effective
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+)
+
+// ParseObject parses an object as given on the command line: schema,
+// schema.table, schema.* or *.*, optionally prefixed with PROCEDURE or
+// FUNCTION. Names may be quoted with backticks.
+func ParseObject(s string) (Object, error) {
+	o := Object{Type: "TABLE"}
+	s = strings.TrimSpace(s)
+	if typ, rest, ok := strings.Cut(s, " "); ok {
+		switch t := strings.ToUpper(typ); t {
+		case "TABLE", "PROCEDURE", "FUNCTION":
+			o.Type, s = t, strings.TrimSpace(rest)
+		}
+	}
+
+	schema, rest, err := objectPart(s)
+	if err != nil {
+		return Object{}, err
+	}
+	o.Schema, o.Name = schema, "*"
+	if rest != "" {
+		if rest[0] != '.' {
+			return Object{}, fmt.Errorf("invalid object %q: expected schema.name", s)
+		}
+		if o.Name, rest, err = objectPart(rest[1:]); err != nil {
+			return Object{}, err
+		}
+		if rest != "" {
+			return Object{}, fmt.Errorf("invalid object %q: expected schema.name", s)
+		}
+	}
+	switch {
+	case o.Schema == "" || o.Name == "":
+		return Object{}, fmt.Errorf("invalid object %q: expected schema.name", s)
+	case o.Schema == "*" && o.Name != "*":
+		return Object{}, fmt.Errorf("invalid object %q: a table needs a schema", s)
+	case o.Type != "TABLE" && o.Name == "*":
+		return Object{}, fmt.Errorf("invalid object %q: a routine needs a name", s)
+	}
+	return o, nil
+}
+
+// objectPart returns the leading, optionally quoted, name of s and the rest
+func objectPart(s string) (string, string, error) {
+	if !strings.HasPrefix(s, "`") {
+		i := strings.IndexByte(s, '.')
+		if i < 0 {
+			return s, "", nil
+		}
+		return s[:i], s[i:], nil
+	}
+	var name strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] != '`' {
+			name.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '`' {
+			name.WriteByte('`')
+			i++
+			continue
+		}
+		return name.String(), s[i+1:], nil
+	}
+	return "", "", fmt.Errorf("unterminated quoted name in %q", s)
+}
+
+// Source is a grant that gives a privilege on an object
+type Source struct {
+	Level string `json:"grant"`
+	// Via is Direct or the role holding the grant
+	Via string `json:"via"`
+}
+
+// Access is an account that holds a privilege on an object
+type Access struct {
+	Account string `json:"account"`
+	// Role is set for accounts that are granted to others
+	Role   bool `json:"role,omitempty"`
+	Locked bool `json:"locked,omitempty"`
+	// Columns limits the access to these columns of a table
+	Columns []string `json:"columns,omitempty"`
+	Sources []Source `json:"sources"`
+}
+
+// Holders lists the accounts that hold a privilege on one object
+type Holders struct {
+	Object    string   `json:"object"`
+	Privilege string   `json:"privilege"`
+	Accounts  []Access `json:"accounts"`
+}
+
+// WhoCan returns every account that holds the privilege on the object,
+// through its own grants or through any role granted to it, since a role
+// that is not active by default can still be activated with SET ROLE
+func WhoCan(accounts []account.Account, o Object, privilege string) *Holders {
+	privilege = strings.ToUpper(privilege)
+	if privilege == "ALL" {
+		privilege = "ALL PRIVILEGES"
+	}
+	r := NewResolver(accounts)
+	granted := map[string]bool{}
+	for _, a := range accounts {
+		for _, rg := range a.Roles {
+			granted[rg.Role] = true
+		}
+	}
+
+	h := &Holders{Object: o.String(), Privilege: privilege, Accounts: []Access{}}
+	for i := range accounts {
+		a := &accounts[i]
+		principals, _ := r.Principals(a, true)
+		access := Access{Account: a.ID(), Role: a.Role || granted[a.ID()], Locked: a.Locked}
+		full := false
+		for _, p := range principals {
+			restricted := restrictions(p.Account, o)
+			for _, g := range p.Account.Grants {
+				if !covers(g, o) {
+					continue
+				}
+				columns, ok := provides(g, o, privilege)
+				if !ok || (g.Schema == "*" && restricted[privilege]) {
+					continue
+				}
+				if columns == nil {
+					full = true
+				}
+				for _, c := range columns {
+					if !slices.Contains(access.Columns, c) {
+						access.Columns = append(access.Columns, c)
+					}
+				}
+				access.Sources = append(access.Sources, Source{Level: g.Level(), Via: p.Source()})
+			}
+		}
+		if len(access.Sources) == 0 {
+			continue
+		}
+		if full {
+			access.Columns = nil
+		}
+		h.Accounts = append(h.Accounts, access)
+	}
+	return h
+}
+
+// Provides reports whether a grant gives the privilege on the object it is
+// made on. ALL PRIVILEGES provides every privilege of its level.
+func Provides(g account.Grant, privilege string) bool {
+	_, ok := provides(g, ObjectOf(g), privilege)
+	return ok
+}
+
+// provides reports whether a grant that covers the object gives the
+// privilege there, and the columns it is limited to
+func provides(g account.Grant, o Object, privilege string) ([]string, bool) {
+	if privilege == "GRANT OPTION" {
+		return nil, g.GrantOption
+	}
+	for _, p := range g.Privileges {
+		if len(p.Columns) > 0 {
+			if p.Name == privilege && ObjectOf(g) == o {
+				return p.Columns, true
+			}
+			continue
+		}
+		// ALL PRIVILEGES on *.* includes the privileges that only exist
+		// globally
+		if o.Global() && p.Name == "ALL PRIVILEGES" && privilege != "PROXY" {
+			return nil, true
+		}
+		if slices.Contains(expand(p.Name, o), privilege) {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+// WriteJSON writes the holders as indented JSON
+func (h *Holders) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(h)
+}
+
+// WriteText writes one row per grant that gives the privilege
+func (h *Holders) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Accounts with %s on %s\n", h.Privilege, h.Object)
+	if len(h.Accounts) == 0 {
+		fmt.Fprintln(&b, "None")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tGRANT\tVIA")
+	for _, a := range h.Accounts {
+		name := a.Account
+		var notes []string
+		if a.Role {
+			notes = append(notes, "role")
+		}
+		if a.Locked {
+			notes = append(notes, "locked")
+		}
+		if len(a.Columns) > 0 {
+			cols := make([]string, len(a.Columns))
+			for i, c := range a.Columns {
+				cols[i] = account.QuoteIdent(c)
+			}
+			notes = append(notes, "columns "+strings.Join(cols, ", "))
+		}
+		if len(notes) > 0 {
+			name += " (" + strings.Join(notes, ", ") + ")"
+		}
+		for _, s := range a.Sources {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", name, s.Level, s.Via)
+			name = ""
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
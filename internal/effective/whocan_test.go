@@ -0,0 +1,120 @@
+package effective
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/stretchr/testify/assert"
+)
+
+const paymentsSQL = `
+CREATE ROLE 'pay_reader';
+` + "GRANT SELECT ON `pay\\_%`.* TO 'pay_reader'@'%';" + `
+CREATE USER 'api'@'10.%';
+GRANT 'pay_reader' TO 'api'@'10.%';
+CREATE USER 'dba'@'localhost';
+GRANT ALL PRIVILEGES ON *.* TO 'dba'@'localhost';
+CREATE USER 'restricted'@'%';
+GRANT SELECT, INSERT ON *.* TO 'restricted'@'%';
+REVOKE SELECT ON pay_eu.* FROM 'restricted'@'%';
+CREATE USER 'support'@'%' ACCOUNT LOCK;
+GRANT SELECT (id, last4) ON pay_eu.cards TO 'support'@'%';
+CREATE USER 'payroll'@'%';
+` + "GRANT SELECT ON `pay%`.* TO 'payroll'@'%';" + `
+CREATE USER 'owner'@'%';
+GRANT SELECT, UPDATE ON pay_eu.cards TO 'owner'@'%' WITH GRANT OPTION;
+`
+
+func whoCan(t *testing.T, on, privilege string) *Holders {
+	t.Helper()
+	accounts, err := account.ParseSQL(paymentsSQL)
+	assert.NoError(t, err)
+	o, err := ParseObject(on)
+	assert.NoError(t, err)
+	return WhoCan(accounts, o, privilege)
+}
+
+func TestParseObject(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want Object
+	}{
+		{"payments.cards", Object{Type: "TABLE", Schema: "payments", Name: "cards"}},
+		{"payments", Object{Type: "TABLE", Schema: "payments", Name: "*"}},
+		{"payments.*", Object{Type: "TABLE", Schema: "payments", Name: "*"}},
+		{"*.*", Object{Type: "TABLE", Schema: "*", Name: "*"}},
+		{"`pay.eu`.`cards`", Object{Type: "TABLE", Schema: "pay.eu", Name: "cards"}},
+		{"procedure shop.refund", Object{Type: "PROCEDURE", Schema: "shop", Name: "refund"}},
+	} {
+		o, err := ParseObject(c.in)
+		assert.NoError(t, err, c.in)
+		assert.Equal(t, c.want, o, c.in)
+	}
+
+	for _, in := range []string{"", "*.cards", "a.b.c", "`payments", "PROCEDURE shop"} {
+		_, err := ParseObject(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestWhoCan(t *testing.T) {
+	h := whoCan(t, "pay_eu.cards", "select")
+	assert.Equal(t, "`pay_eu`.`cards`", h.Object)
+	assert.Equal(t, "SELECT", h.Privilege)
+	assert.Equal(t, []Access{
+		{Account: "`api`@`10.%`", Sources: []Source{{Level: "`pay\\_%`.*", Via: "`pay_reader`@`%`"}}},
+		{Account: "`dba`@`localhost`", Sources: []Source{{Level: "*.*", Via: Direct}}},
+		{Account: "`owner`@`%`", Sources: []Source{{Level: "`pay_eu`.`cards`", Via: Direct}}},
+		{Account: "`pay_reader`@`%`", Role: true, Locked: true, Sources: []Source{{Level: "`pay\\_%`.*", Via: Direct}}},
+		{Account: "`payroll`@`%`", Sources: []Source{{Level: "`pay%`.*", Via: Direct}}},
+		{Account: "`support`@`%`", Locked: true, Columns: []string{"id", "last4"}, Sources: []Source{{Level: "`pay_eu`.`cards`", Via: Direct}}},
+	}, h.Accounts)
+
+	// the partial revoke only covers pay_eu, and the escaped _ of pay\_%
+	// does not match payroll
+	h = whoCan(t, "payroll.*", "SELECT")
+	var names []string
+	for _, a := range h.Accounts {
+		names = append(names, a.Account)
+	}
+	assert.Equal(t, []string{"`dba`@`localhost`", "`payroll`@`%`", "`restricted`@`%`"}, names)
+
+	// global privileges
+	h = whoCan(t, "*.*", "PROCESS")
+	assert.Len(t, h.Accounts, 1)
+	assert.Equal(t, "`dba`@`localhost`", h.Accounts[0].Account)
+
+	h = whoCan(t, "pay_eu.cards", "GRANT OPTION")
+	assert.Len(t, h.Accounts, 1)
+	assert.Equal(t, "`owner`@`%`", h.Accounts[0].Account)
+}
+
+func TestHolders_WriteText(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, whoCan(t, "pay_eu.cards", "SELECT").WriteText(&b))
+	assert.Equal(t, "Accounts with SELECT on `pay_eu`.`cards`\n"+
+		"ACCOUNT                                        GRANT             VIA\n"+
+		"`api`@`10.%`                                   `pay\\_%`.*        `pay_reader`@`%`\n"+
+		"`dba`@`localhost`                              *.*               direct\n"+
+		"`owner`@`%`                                    `pay_eu`.`cards`  direct\n"+
+		"`pay_reader`@`%` (role, locked)                `pay\\_%`.*        direct\n"+
+		"`payroll`@`%`                                  `pay%`.*          direct\n"+
+		"`support`@`%` (locked, columns `id`, `last4`)  `pay_eu`.`cards`  direct\n", b.String())
+
+	b.Reset()
+	assert.NoError(t, whoCan(t, "shop.orders", "CREATE ROUTINE").WriteText(&b))
+	// CREATE ROUTINE does not exist on tables, not even through ALL PRIVILEGES
+	assert.Equal(t, "Accounts with CREATE ROUTINE on `shop`.`orders`\nNone\n", b.String())
+}
+
+func TestProvides(t *testing.T) {
+	global := account.Grant{Schema: "*", Object: "*", Privileges: []account.Privilege{{Name: "ALL PRIVILEGES"}}}
+	schema := account.Grant{Schema: "app", Object: "*", Privileges: []account.Privilege{{Name: "ALL PRIVILEGES"}}}
+	assert.True(t, Provides(global, "SUPER"))
+	assert.True(t, Provides(global, "DROP"))
+	assert.False(t, Provides(global, "PROXY"))
+	assert.True(t, Provides(schema, "DROP"))
+	assert.False(t, Provides(schema, "SUPER"))
+	assert.False(t, Provides(schema, "GRANT OPTION"))
+}
@@ -0,0 +1,106 @@
+package lint
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/effective"
+)
+
+// Catalog is the set of schemas, tables and routines that exist on a server
+type Catalog struct {
+	schemas []string
+	objects map[effective.Object]bool
+}
+
+// NewCatalog indexes the objects. Schemas are given with the name *, and
+// the schema of a table or routine is added with it.
+func NewCatalog(objects []effective.Object) *Catalog {
+	c := &Catalog{objects: map[effective.Object]bool{}}
+	for _, o := range objects {
+		c.add(o)
+	}
+	sort.Strings(c.schemas)
+	return c
+}
+
+func (c *Catalog) add(o effective.Object) {
+	schema := effective.Object{Type: "TABLE", Schema: o.Schema, Name: "*"}
+	if !c.objects[schema] {
+		c.objects[schema] = true
+		c.schemas = append(c.schemas, o.Schema)
+	}
+	c.objects[key(o)] = true
+}
+
+// key normalizes routine names, which are not case sensitive
+func key(o effective.Object) effective.Object {
+	if o.Type == "PROCEDURE" || o.Type == "FUNCTION" {
+		o.Name = strings.ToLower(o.Name)
+	}
+	return o
+}
+
+// ParseCatalog reads one object per line in the form who-can takes: schema,
+// schema.table, PROCEDURE schema.name or FUNCTION schema.name. Blank lines
+// and lines starting with # are skipped.
+func ParseCatalog(data []byte) (*Catalog, error) {
+	var objects []effective.Object
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		o, err := effective.ParseObject(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if o.Schema == "*" {
+			return nil, fmt.Errorf("line %d: *.* is not an object", n)
+		}
+		objects = append(objects, o)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema list: %w", err)
+	}
+	return NewCatalog(objects), nil
+}
+
+// Schemas returns the schemas matching the schema of a grant
+func (c *Catalog) Schemas(pattern string) []string {
+	var out []string
+	for _, s := range c.schemas {
+		if effective.MatchSchema(pattern, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// matchingBoth returns the quoted schemas that match both patterns, or
+// nil for a nil catalog
+func (c *Catalog) matchingBoth(a, b string) []string {
+	if c == nil {
+		return nil
+	}
+	var out []string
+	for _, s := range c.Schemas(a) {
+		if effective.MatchSchema(b, s) {
+			out = append(out, account.QuoteIdent(s))
+		}
+	}
+	return out
+}
+
+// Has reports whether the object exists
+func (c *Catalog) Has(o effective.Object) bool {
+	if o.Name == "*" {
+		return len(c.Schemas(o.Schema)) > 0
+	}
+	return c.objects[key(o)]
+}
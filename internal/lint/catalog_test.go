@@ -0,0 +1,22 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/effective"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCatalog(t *testing.T) {
+	c, err := ParseCatalog([]byte(catalogList))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pay_eu", "pay_us"}, c.Schemas("pay%"))
+	assert.True(t, c.Has(effective.Object{Type: "PROCEDURE", Schema: "shop", Name: "refund"}))
+	assert.True(t, c.Has(effective.Object{Type: "TABLE", Schema: "pay\\_%", Name: "*"}))
+	assert.False(t, c.Has(effective.Object{Type: "TABLE", Schema: "shop", Name: "gone"}))
+
+	_, err = ParseCatalog([]byte("shop\n*.*\n"))
+	assert.EqualError(t, err, "line 2: *.* is not an object")
+	_, err = ParseCatalog([]byte("a.b.c\n"))
+	assert.ErrorContains(t, err, "line 1: invalid object")
+}
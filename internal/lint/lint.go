@@ -0,0 +1,400 @@
+// Package lint finds grants that do nothing or not what they seem to:
+// grants covered by a wider one, overlapping wildcard schema grants, grants
+// on objects that no longer exist, unused roles and accounts without
+// privileges. Each finding comes with the REVOKE that cleans it up.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/effective"
+)
+
+// Rule IDs findings are reported under
+const (
+	RedundantGrant      = "redundant-grant"
+	OverlappingWildcard = "overlapping-wildcard"
+	MissingObject       = "missing-object"
+	UnusedRole          = "unused-role"
+	UsageOnly           = "usage-only"
+)
+
+// Finding is a single problem with the suggested REVOKE that fixes it
+type Finding struct {
+	Rule       string `json:"rule"`
+	Account    string `json:"account"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+	// Optional is a DROP USER or DROP ROLE to run after checking that the
+	// account is not used; the text output writes it as a comment
+	Optional string `json:"optional,omitempty"`
+}
+
+// Report holds the findings for one host or dump file
+type Report struct {
+	Source string `json:"source"`
+	// ObjectsChecked is false when no schema list was available and grants
+	// on missing objects were not looked for
+	ObjectsChecked bool      `json:"objects_checked"`
+	Findings       []Finding `json:"findings"`
+}
+
+// Run lints the accounts. The catalog lists the objects that exist; when
+// it is nil, grants on missing objects are not reported.
+func Run(source string, accounts []account.Account, catalog *Catalog) *Report {
+	r := &Report{Source: source, ObjectsChecked: catalog != nil, Findings: []Finding{}}
+	granted := map[string]bool{}
+	for _, a := range accounts {
+		for _, rg := range a.Roles {
+			granted[rg.Role] = true
+		}
+		for _, role := range a.DefaultRoles {
+			granted[role] = true
+		}
+	}
+
+	for i := range accounts {
+		a := &accounts[i]
+		r.Findings = append(r.Findings, redundantGrants(a)...)
+		r.Findings = append(r.Findings, overlappingWildcards(a, catalog)...)
+		if catalog != nil {
+			r.Findings = append(r.Findings, missingObjects(a, catalog)...)
+		}
+		if f := unusedRole(a, granted); f != nil {
+			r.Findings = append(r.Findings, *f)
+		} else if f := usageOnly(a, granted); f != nil {
+			r.Findings = append(r.Findings, *f)
+		}
+	}
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		a, b := r.Findings[i], r.Findings[j]
+		if a.Account != b.Account {
+			return a.Account < b.Account
+		}
+		return a.Rule < b.Rule
+	})
+	return r
+}
+
+// grantee renders the account the way GRANT and REVOKE take it
+func grantee(a *account.Account) string {
+	if a.Role {
+		return account.MariaDB.Grantee(a)
+	}
+	return a.ID()
+}
+
+// revoke renders the REVOKE statement for some privileges of a grant
+func revoke(a *account.Account, g account.Grant, privs []account.Privilege) string {
+	r := account.Grant{ObjectType: g.ObjectType, Schema: g.Schema, Object: g.Object, Privileges: privs}
+	return r.RevokeStatement(grantee(a)) + ";"
+}
+
+// allPrivileges returns the privileges of a grant, with GRANT OPTION
+func allPrivileges(g account.Grant) []account.Privilege {
+	privs := slices.Clone(g.Privileges)
+	if g.GrantOption {
+		privs = append(privs, account.Privilege{Name: "GRANT OPTION"})
+	}
+	return privs
+}
+
+// held returns the privileges the grants give on the object, less the
+// partial revokes of the account
+func held(a *account.Account, grants []account.Grant, o effective.Object) map[string]bool {
+	holder := account.Account{User: a.User, Host: a.Host, Grants: grants, Revokes: a.Revokes}
+	privs, _ := effective.On([]effective.Principal{{Account: &holder}}, o)
+	set := map[string]bool{}
+	for _, p := range privs {
+		if len(p.Columns) == 0 {
+			set[p.Name] = true
+		}
+	}
+	return set
+}
+
+// amounts returns what a single privilege of a grant amounts to on its
+// object, such as the schema privileges for ALL PRIVILEGES on a schema
+func amounts(p account.Privilege, o effective.Object) []string {
+	if len(p.Columns) > 0 {
+		return []string{p.Name}
+	}
+	g := account.Grant{ObjectType: o.Type, Schema: o.Schema, Object: o.Name, Privileges: []account.Privilege{{Name: p.Name}}}
+	if p.Name == "GRANT OPTION" {
+		g.Privileges, g.GrantOption = nil, true
+	}
+	return account.SortedKeys(held(&account.Account{}, []account.Grant{g}, o))
+}
+
+func subset(names []string, set map[string]bool) bool {
+	for _, n := range names {
+		if !set[n] {
+			return false
+		}
+	}
+	return len(names) > 0
+}
+
+// redundantGrants reports privileges of schema, table and routine grants
+// that a global grant, or a schema grant for tables and routines, already
+// gives the account
+func redundantGrants(a *account.Account) []Finding {
+	var out []Finding
+	for i, g := range a.Grants {
+		if g.ObjectType == "PROXY" || g.Schema == "*" {
+			continue
+		}
+		o := effective.ObjectOf(g)
+		var wider []account.Grant
+		for j, w := range a.Grants {
+			switch {
+			case j == i || w.ObjectType == "PROXY":
+			case w.Schema == "*", g.Object != "*" && w.Object == "*" && effective.MatchSchema(w.Schema, g.Schema):
+				wider = append(wider, w)
+			}
+		}
+		if len(wider) == 0 {
+			continue
+		}
+		have := held(a, wider, o)
+
+		var covered []account.Privilege
+		for _, p := range allPrivileges(g) {
+			if p.Name == "USAGE" {
+				continue
+			}
+			if subset(amounts(p, o), have) {
+				covered = append(covered, p)
+			}
+		}
+		if len(covered) == 0 {
+			continue
+		}
+		var levels []string
+		for _, w := range wider {
+			if slices.ContainsFunc(covered, func(p account.Privilege) bool {
+				return slices.ContainsFunc(amounts(p, o), func(n string) bool { return held(a, []account.Grant{w}, o)[n] })
+			}) {
+				levels = append(levels, w.Level())
+			}
+		}
+		names := make([]string, len(covered))
+		for k, p := range covered {
+			names[k] = p.String()
+		}
+		out = append(out, Finding{
+			Rule:       RedundantGrant,
+			Account:    a.ID(),
+			Message:    fmt.Sprintf("%s on %s already granted on %s", strings.Join(names, ", "), g.Level(), strings.Join(levels, ", ")),
+			Suggestion: revoke(a, g, covered),
+		})
+	}
+	return out
+}
+
+// subsetOf reports whether every name in a is in b
+func subsetOf(a, b []string) bool {
+	for _, s := range a {
+		if !slices.Contains(b, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// wildcard reports whether a schema name has an unescaped _ or %
+func wildcard(schema string) bool {
+	for i := 0; i < len(schema); i++ {
+		switch schema[i] {
+		case '\\':
+			i++
+		case '_', '%':
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether every schema the narrow pattern matches is
+// matched by the wide one. Only a literal name, or a wide pattern made of a
+// literal prefix and %, can be decided.
+func contains(wide, narrow string) bool {
+	if !wildcard(narrow) {
+		return effective.MatchSchema(wide, account.LiteralPrefix(narrow))
+	}
+	prefix, ok := strings.CutSuffix(wide, "%")
+	return ok && !wildcard(prefix) && !strings.Contains(prefix, `\`) && strings.HasPrefix(account.LiteralPrefix(narrow), prefix)
+}
+
+// overlappingWildcards reports pairs of schema grants of which one has a
+// wildcard and both match the same schemas. The server applies the first
+// schema grant that matches a schema, not the union of all of them, so the
+// privileges of the wider grant do not apply where the narrower one matches.
+func overlappingWildcards(a *account.Account, catalog *Catalog) []Finding {
+	var schemaGrants []account.Grant
+	for _, g := range a.Grants {
+		if g.Schema != "*" && g.Object == "*" && (g.ObjectType == "" || g.ObjectType == "TABLE") {
+			schemaGrants = append(schemaGrants, g)
+		}
+	}
+
+	var out []Finding
+	for i, g := range schemaGrants {
+		for _, h := range schemaGrants[i+1:] {
+			if !wildcard(g.Schema) && !wildcard(h.Schema) {
+				continue
+			}
+			var gs, hs []string
+			if catalog != nil {
+				gs, hs = catalog.Schemas(g.Schema), catalog.Schemas(h.Schema)
+			}
+			var wide, narrow account.Grant
+			switch {
+			case contains(g.Schema, h.Schema), !contains(h.Schema, g.Schema) && len(hs) > 0 && subsetOf(hs, gs):
+				wide, narrow = g, h
+			case contains(h.Schema, g.Schema), len(gs) > 0 && subsetOf(gs, hs):
+				wide, narrow = h, g
+			default:
+				continue
+			}
+
+			var where string
+			if both := catalog.matchingBoth(wide.Schema, narrow.Schema); len(both) > 0 {
+				where = " (" + strings.Join(both, ", ") + ")"
+			}
+
+			o := effective.ObjectOf(narrow)
+			have := held(a, []account.Grant{wide}, effective.ObjectOf(wide))
+			var missing []string
+			for _, p := range allPrivileges(narrow) {
+				if p.Name != "USAGE" && !subset(amounts(p, o), have) {
+					missing = append(missing, p.String())
+				}
+			}
+			msg := fmt.Sprintf("%s overlaps %s%s: for a schema both match, the server uses only one of the two grants", narrow.Level(), wide.Level(), where)
+			if len(missing) > 0 {
+				msg += fmt.Sprintf("; %s does not grant %s", wide.Level(), strings.Join(missing, ", "))
+			}
+			out = append(out, Finding{
+				Rule:       OverlappingWildcard,
+				Account:    a.ID(),
+				Message:    msg,
+				Suggestion: revoke(a, narrow, allPrivileges(narrow)),
+			})
+		}
+	}
+	return out
+}
+
+// missingObjects reports grants on schemas, tables and routines that are not
+// in the catalog
+func missingObjects(a *account.Account, catalog *Catalog) []Finding {
+	var out []Finding
+	for _, g := range a.Grants {
+		if g.ObjectType == "PROXY" || g.Schema == "*" {
+			continue
+		}
+		o := effective.ObjectOf(g)
+		if catalog.Has(o) {
+			continue
+		}
+		var msg string
+		switch {
+		case o.Name != "*":
+			msg = o.String() + " does not exist"
+		case wildcard(g.Schema):
+			msg = g.Level() + " matches no existing schema"
+		default:
+			msg = "schema " + account.QuoteIdent(account.LiteralPrefix(g.Schema)) + " does not exist"
+		}
+		out = append(out, Finding{
+			Rule:       MissingObject,
+			Account:    a.ID(),
+			Message:    msg,
+			Suggestion: revoke(a, g, allPrivileges(g)),
+		})
+	}
+	return out
+}
+
+// isRole reports whether the account looks like a role: a MariaDB role, or
+// a locked account without a password, which is how MySQL creates roles
+func isRole(a *account.Account) bool {
+	return a.Role || (a.Locked && a.AuthString == "" && len(a.Factors) == 0)
+}
+
+// unusedRole reports roles no account is granted
+func unusedRole(a *account.Account, granted map[string]bool) *Finding {
+	if !isRole(a) || granted[a.ID()] {
+		return nil
+	}
+	msg := "role is not granted to any account"
+	if !a.Role {
+		msg = "locked account without a password that is not granted to any account"
+	}
+	return &Finding{
+		Rule:       UnusedRole,
+		Account:    a.ID(),
+		Message:    msg,
+		Suggestion: revokeAll(a),
+		Optional:   "DROP ROLE " + grantee(a) + ";",
+	}
+}
+
+// usageOnly reports accounts that can log in but hold no privileges, no
+// proxy grants and no roles
+func usageOnly(a *account.Account, granted map[string]bool) *Finding {
+	if isRole(a) || granted[a.ID()] || len(a.Roles) > 0 {
+		return nil
+	}
+	for _, set := range account.PrivilegeSet(a.Grants) {
+		if len(set) > 0 {
+			return nil
+		}
+	}
+	return &Finding{
+		Rule:       UsageOnly,
+		Account:    a.ID(),
+		Message:    "holds only USAGE: it can log in but has no privileges or roles",
+		Suggestion: revokeAll(a),
+		Optional:   "DROP USER " + a.ID() + ";",
+	}
+}
+
+// revokeAll renders the REVOKE that takes every privilege from the account
+func revokeAll(a *account.Account) string {
+	return "REVOKE ALL PRIVILEGES, GRANT OPTION FROM " + grantee(a) + ";"
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes each finding followed by its suggested statement and,
+// commented out, the optional one
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Linting %s\n", r.Source)
+	if !r.ObjectsChecked {
+		fmt.Fprintln(&b, "No schema list, grants on missing objects are not checked")
+	}
+	for _, f := range r.Findings {
+		fmt.Fprintf(&b, "[%s] %s: %s\n", f.Rule, f.Account, f.Message)
+		fmt.Fprintf(&b, "  %s\n", f.Suggestion)
+		if f.Optional != "" {
+			fmt.Fprintf(&b, "  -- optional: %s\n", f.Optional)
+		}
+	}
+	fmt.Fprintf(&b, "Findings: %d\n", len(r.Findings))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,147 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/stretchr/testify/assert"
+)
+
+const accountsSQL = `
+CREATE USER 'app'@'%' IDENTIFIED WITH 'caching_sha2_password' AS '0x24';
+GRANT SELECT, INSERT ON *.* TO 'app'@'%';
+GRANT SELECT, UPDATE ON shop.* TO 'app'@'%';
+GRANT SELECT, DELETE ON shop.orders TO 'app'@'%';
+GRANT EXECUTE ON PROCEDURE shop.refund TO 'app'@'%';
+GRANT SELECT ON legacy.* TO 'app'@'%';
+CREATE USER 'report'@'10.%' IDENTIFIED WITH 'caching_sha2_password' AS '0x24';
+` + "GRANT SELECT ON `pay%`.* TO 'report'@'10.%';" + `
+` + "GRANT SELECT, INSERT ON `pay\\_eu`.* TO 'report'@'10.%';" + `
+` + "GRANT SELECT ON `%eu`.* TO 'report'@'10.%';" + `
+GRANT SELECT (id) ON shop.gone TO 'report'@'10.%';
+CREATE ROLE 'reader'@'%', 'unused'@'%';
+GRANT SELECT ON shop.* TO 'reader'@'%';
+GRANT 'reader'@'%' TO 'report'@'10.%';
+CREATE ROLE 'editor';
+CREATE USER 'idle'@'%' IDENTIFIED WITH 'caching_sha2_password' AS '0x24';
+CREATE USER 'dba'@'localhost' IDENTIFIED WITH 'caching_sha2_password' AS '0x24';
+GRANT ALL PRIVILEGES ON *.* TO 'dba'@'localhost' WITH GRANT OPTION;
+REVOKE INSERT ON mysql.* FROM 'dba'@'localhost';
+GRANT INSERT, SELECT ON mysql.* TO 'dba'@'localhost';
+`
+
+const catalogList = `
+# schemas, tables and routines
+shop
+shop.orders
+PROCEDURE shop.Refund
+pay_eu
+pay_us
+mysql
+`
+
+func lint(t *testing.T, withCatalog bool) *Report {
+	t.Helper()
+	accounts, err := account.ParseSQL(accountsSQL)
+	assert.NoError(t, err)
+	var c *Catalog
+	if withCatalog {
+		c, err = ParseCatalog([]byte(catalogList))
+		assert.NoError(t, err)
+	}
+	return Run("db1", accounts, c)
+}
+
+// findings returns the findings of one rule as account, message and
+// suggestion
+func findings(r *Report, rule string) [][3]string {
+	var out [][3]string
+	for _, f := range r.Findings {
+		if f.Rule == rule {
+			out = append(out, [3]string{f.Account, f.Message, f.Suggestion})
+		}
+	}
+	return out
+}
+
+func TestRun_RedundantGrant(t *testing.T) {
+	assert.Equal(t, [][3]string{
+		{"`app`@`%`", "SELECT on `shop`.* already granted on *.*", "REVOKE SELECT ON `shop`.* FROM `app`@`%`;"},
+		{"`app`@`%`", "SELECT on `shop`.`orders` already granted on *.*, `shop`.*", "REVOKE SELECT ON `shop`.`orders` FROM `app`@`%`;"},
+		{"`app`@`%`", "SELECT on `legacy`.* already granted on *.*", "REVOKE SELECT ON `legacy`.* FROM `app`@`%`;"},
+		// the partial revoke keeps INSERT on mysql from being redundant
+		{"`dba`@`localhost`", "SELECT on `mysql`.* already granted on *.*", "REVOKE SELECT ON `mysql`.* FROM `dba`@`localhost`;"},
+	}, findings(lint(t, false), RedundantGrant))
+}
+
+func TestRun_OverlappingWildcard(t *testing.T) {
+	msg := "for a schema both match, the server uses only one of the two grants"
+	// without a schema list only patterns that contain each other are found
+	assert.Equal(t, [][3]string{
+		{"`report`@`10.%`", "`pay\\_eu`.* overlaps `pay%`.*: " + msg + "; `pay%`.* does not grant INSERT", "REVOKE SELECT, INSERT ON `pay\\_eu`.* FROM `report`@`10.%`;"},
+		{"`report`@`10.%`", "`pay\\_eu`.* overlaps `%eu`.*: " + msg + "; `%eu`.* does not grant INSERT", "REVOKE SELECT, INSERT ON `pay\\_eu`.* FROM `report`@`10.%`;"},
+	}, findings(lint(t, false), OverlappingWildcard))
+
+	assert.Equal(t, [][3]string{
+		{"`report`@`10.%`", "`pay\\_eu`.* overlaps `pay%`.* (`pay_eu`): " + msg + "; `pay%`.* does not grant INSERT", "REVOKE SELECT, INSERT ON `pay\\_eu`.* FROM `report`@`10.%`;"},
+		{"`report`@`10.%`", "`%eu`.* overlaps `pay%`.* (`pay_eu`): " + msg, "REVOKE SELECT ON `%eu`.* FROM `report`@`10.%`;"},
+		{"`report`@`10.%`", "`pay\\_eu`.* overlaps `%eu`.* (`pay_eu`): " + msg + "; `%eu`.* does not grant INSERT", "REVOKE SELECT, INSERT ON `pay\\_eu`.* FROM `report`@`10.%`;"},
+	}, findings(lint(t, true), OverlappingWildcard))
+}
+
+func TestRun_MissingObject(t *testing.T) {
+	assert.Empty(t, findings(lint(t, false), MissingObject))
+	assert.Equal(t, [][3]string{
+		{"`app`@`%`", "schema `legacy` does not exist", "REVOKE SELECT ON `legacy`.* FROM `app`@`%`;"},
+		{"`report`@`10.%`", "`shop`.`gone` does not exist", "REVOKE SELECT (`id`) ON `shop`.`gone` FROM `report`@`10.%`;"},
+	}, findings(lint(t, true), MissingObject))
+}
+
+func TestRun_Accounts(t *testing.T) {
+	r := lint(t, false)
+	assert.Equal(t, [][3]string{
+		{"`editor`@`%`", "role is not granted to any account", "REVOKE ALL PRIVILEGES, GRANT OPTION FROM `editor`;"},
+		{"`unused`@`%`", "locked account without a password that is not granted to any account", "REVOKE ALL PRIVILEGES, GRANT OPTION FROM `unused`@`%`;"},
+	}, findings(r, UnusedRole))
+	assert.Equal(t, [][3]string{
+		{"`idle`@`%`", "holds only USAGE: it can log in but has no privileges or roles", "REVOKE ALL PRIVILEGES, GRANT OPTION FROM `idle`@`%`;"},
+	}, findings(r, UsageOnly))
+
+	var optional []string
+	for _, f := range r.Findings {
+		if f.Optional != "" {
+			optional = append(optional, f.Optional)
+		}
+	}
+	assert.Equal(t, []string{"DROP ROLE `editor`;", "DROP USER `idle`@`%`;", "DROP ROLE `unused`@`%`;"}, optional)
+}
+
+func TestReport_WriteText(t *testing.T) {
+	accounts, err := account.ParseSQL("CREATE USER 'app'@'%' IDENTIFIED WITH 'caching_sha2_password' AS '0x24';\n" +
+		"GRANT SELECT ON *.* TO 'app'@'%';\nGRANT SELECT, INSERT ON shop.* TO 'app'@'%';\n")
+	assert.NoError(t, err)
+	var b bytes.Buffer
+	assert.NoError(t, Run("dump.sql", accounts, nil).WriteText(&b))
+	assert.Equal(t, "Linting dump.sql\n"+
+		"No schema list, grants on missing objects are not checked\n"+
+		"[redundant-grant] `app`@`%`: SELECT on `shop`.* already granted on *.*\n"+
+		"  REVOKE SELECT ON `shop`.* FROM `app`@`%`;\n"+
+		"Findings: 1\n", b.String())
+
+	b.Reset()
+	accounts, err = account.ParseSQL("CREATE USER 'idle'@'%' IDENTIFIED WITH 'caching_sha2_password' AS '0x24';\n")
+	assert.NoError(t, err)
+	assert.NoError(t, Run("dump.sql", accounts, nil).WriteText(&b))
+	assert.Contains(t, b.String(), "[usage-only] `idle`@`%`: holds only USAGE: it can log in but has no privileges or roles\n"+
+		"  REVOKE ALL PRIVILEGES, GRANT OPTION FROM `idle`@`%`;\n"+
+		"  -- optional: DROP USER `idle`@`%`;\n")
+
+	b.Reset()
+	assert.NoError(t, lint(t, true).WriteJSON(&b))
+	var decoded map[string]any
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &decoded))
+	assert.Equal(t, true, decoded["objects_checked"])
+	assert.Len(t, decoded["findings"], 12)
+}
@@ -0,0 +1,333 @@
+// Package login simulates how the server picks the account row a client
+// connects as. Rows are checked from the most to the least specific host,
+// as in MySQL 8.0.23 and later, and the first row whose user and host match
+// the client is used, whatever its password or lock state.
+package login
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+)
+
+// Candidate is an account row the server checks for the user
+type Candidate struct {
+	Account     string `json:"account"`
+	Specificity string `json:"specificity"`
+	Matches     bool   `json:"matches"`
+	Selected    bool   `json:"selected,omitempty"`
+	Note        string `json:"note,omitempty"`
+}
+
+// Shadow is an account row that a row sorted before it keeps from being used
+type Shadow struct {
+	Account string `json:"account"`
+	By      string `json:"by"`
+	Reason  string `json:"reason"`
+}
+
+// Result is the outcome of a simulated login
+type Result struct {
+	User string `json:"user"`
+	From string `json:"from"`
+	// Selected is empty when no row matches and the login is refused
+	Selected   string      `json:"selected,omitempty"`
+	Candidates []Candidate `json:"candidates"`
+	Shadowed   []Shadow    `json:"shadowed"`
+}
+
+// row is an account row with its parsed host
+type row struct {
+	a *account.Account
+	// net and mask are set for IPv4 hosts with a netmask or prefix length
+	net, mask uint32
+	masked    bool
+	sort      int
+}
+
+func newRow(a *account.Account) row {
+	r := row{a: a, sort: hostSort(a.Host)<<8 | userSort(a.User)}
+	if addr, mask, ok := strings.Cut(a.Host, "/"); ok {
+		ip, err := netip.ParseAddr(addr)
+		if err == nil && ip.Is4() {
+			if m, ok := parseMask(mask); ok {
+				r.net, r.mask, r.masked = ipv4(ip)&m, m, true
+			}
+		}
+	}
+	return r
+}
+
+// hostSort ranks a host like the server does: a host without wildcards
+// ranks highest, then the later the first wildcard the higher, and an
+// empty host lowest
+func hostSort(host string) int {
+	if host == "" {
+		return 0
+	}
+	for i := 0; i < len(host); i++ {
+		switch host[i] {
+		case '\\':
+			i++
+		case '%', '_':
+			return min(i+1, 127)
+		}
+	}
+	return 128
+}
+
+func userSort(user string) int {
+	if user == "" {
+		return 0
+	}
+	return 128
+}
+
+// parseMask parses a dotted netmask or a prefix length
+func parseMask(s string) (uint32, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 0 || n > 32 {
+			return 0, false
+		}
+		return ^uint32(0) << (32 - n), true
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil || !ip.Is4() {
+		return 0, false
+	}
+	return ipv4(ip), true
+}
+
+func ipv4(ip netip.Addr) uint32 {
+	b := ip.As4()
+	return binary.BigEndian.Uint32(b[:])
+}
+
+// before reports whether the server checks a before b. Hosts are checked
+// by their rank; among hosts without wildcards, a literal host name or IP
+// comes before an IP with a netmask, and the widest mask comes last.
+func before(a, b row) bool {
+	if ah, bh := a.sort>>8, b.sort>>8; ah != bh {
+		return ah > bh
+	}
+	if a.masked != b.masked {
+		return b.masked
+	}
+	if a.masked && a.mask != b.mask {
+		return a.mask > b.mask
+	}
+	return a.sort > b.sort
+}
+
+// tied reports whether the server may check a and b in either order
+func tied(a, b row) bool {
+	return !before(a, b) && !before(b, a)
+}
+
+// matchesHost reports whether a client at from matches the row's host.
+// Host names are compared as given; from is not resolved.
+func (r row) matchesHost(from string) bool {
+	if r.masked {
+		ip, err := netip.ParseAddr(from)
+		return err == nil && ip.Is4() && ipv4(ip)&r.mask == r.net
+	}
+	// an empty host matches any client, like %
+	return r.a.Host == "" || account.Like(strings.ToLower(r.a.Host), strings.ToLower(from))
+}
+
+// octetPattern returns the network of a host such as 10.1.% that matches
+// whole octets
+func octetPattern(host string) (net, mask uint32, ok bool) {
+	prefix, found := strings.CutSuffix(host, ".%")
+	if !found || strings.ContainsAny(prefix, "%_\\") {
+		return 0, 0, false
+	}
+	octets := strings.Split(prefix, ".")
+	if len(octets) > 3 {
+		return 0, 0, false
+	}
+	for i, o := range octets {
+		n, err := strconv.Atoi(o)
+		if err != nil || n < 0 || n > 255 {
+			return 0, 0, false
+		}
+		net |= uint32(n) << (24 - 8*i)
+	}
+	return net, ^uint32(0) << (32 - 8*len(octets)), true
+}
+
+// coversHost reports whether every client matching b's host also matches
+// a's. Only the cases that can be decided from the patterns alone are
+// recognized.
+func coversHost(a, b row) bool {
+	ah, bh := strings.ToLower(a.a.Host), strings.ToLower(b.a.Host)
+	switch {
+	case ah == "" || ah == "%" || ah == bh:
+		return true
+	case a.masked:
+		switch {
+		case b.masked:
+			return b.mask&a.mask == a.mask && b.net&a.mask == a.net
+		case hostSort(bh) == 128:
+			ip, err := netip.ParseAddr(bh)
+			return err == nil && ip.Is4() && ipv4(ip)&a.mask == a.net
+		}
+		net, mask, ok := octetPattern(bh)
+		return ok && mask&a.mask == a.mask && net&a.mask == a.net
+	case b.masked || hostSort(ah) == 128:
+		return false
+	case hostSort(bh) == 128:
+		return account.Like(ah, bh)
+	}
+	// a is a literal prefix followed by a single %
+	prefix, ok := strings.CutSuffix(ah, "%")
+	return ok && !strings.ContainsAny(prefix, "%_\\") && strings.HasPrefix(account.LiteralPrefix(bh), prefix)
+}
+
+// specificity describes how a row ranks in the order the server checks rows
+func (r row) specificity() string {
+	var s string
+	switch h := r.sort >> 8; {
+	case r.masked:
+		s = fmt.Sprintf("IP with netmask /%d", bits.OnesCount32(r.mask))
+	case h == 128:
+		s = "exact host"
+	case r.a.Host == "":
+		s = "any host (blank)"
+	case r.a.Host == "%":
+		s = "any host"
+	case h == 1:
+		s = "leading wildcard"
+	default:
+		s = fmt.Sprintf("wildcard after %d characters", h-1)
+	}
+	if r.a.User == "" {
+		s += ", any user"
+	}
+	return s
+}
+
+// Match simulates a login by user from the client host or address
+func Match(accounts []account.Account, user, from string) *Result {
+	var rows []row
+	for i := range accounts {
+		a := &accounts[i]
+		// MariaDB roles are not accounts one can log in as
+		if a.Role || (a.User != user && a.User != "") {
+			continue
+		}
+		rows = append(rows, newRow(a))
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return before(rows[i], rows[j]) })
+
+	res := &Result{User: user, From: from, Candidates: []Candidate{}, Shadowed: []Shadow{}}
+	var selected *row
+	for i, r := range rows {
+		c := Candidate{Account: r.a.ID(), Specificity: r.specificity(), Matches: r.matchesHost(from)}
+		switch {
+		case c.Matches && selected == nil:
+			selected = &rows[i]
+			c.Selected = true
+			res.Selected = c.Account
+			c.Note = selectedNote(r, user)
+		case c.Matches:
+			c.Note = "matches, but " + selected.a.ID() + " is checked first"
+		case isIP(from) && strings.ContainsFunc(r.a.Host, unicode.IsLetter):
+			c.Note = "host name, matches only if " + from + " resolves to it (not checked)"
+		default:
+			c.Note = "host does not match"
+		}
+		res.Candidates = append(res.Candidates, c)
+	}
+
+	for i, r := range rows {
+		if r.a.User != user {
+			continue
+		}
+		for _, h := range rows[:i] {
+			if h.a.User != user && h.a.User != "" {
+				continue
+			}
+			if tied(h, r) {
+				if coversHost(h, r) || coversHost(r, h) {
+					res.Shadowed = append(res.Shadowed, Shadow{Account: r.a.ID(), By: h.a.ID(),
+						Reason: "ranks the same as " + h.a.ID() + ", which one the server checks first is not defined"})
+					break
+				}
+				continue
+			}
+			if coversHost(h, r) {
+				res.Shadowed = append(res.Shadowed, Shadow{Account: r.a.ID(), By: h.a.ID(),
+					Reason: "can never be matched: " + h.a.ID() + " is checked first and matches every host it does"})
+				break
+			}
+		}
+	}
+	return res
+}
+
+// selectedNote explains what happens with the row the server picks
+func selectedNote(r row, user string) string {
+	var notes []string
+	if r.a.User != user {
+		notes = append(notes, "anonymous account, the session runs as "+r.a.ID()+" with its password and privileges")
+	}
+	if r.a.Locked {
+		notes = append(notes, "locked, the login is refused")
+	}
+	if len(notes) == 0 {
+		return "selected"
+	}
+	return "selected: " + strings.Join(notes, "; ")
+}
+
+func isIP(s string) bool {
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
+
+// WriteJSON writes the result as indented JSON
+func (res *Result) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+// WriteText writes the candidates in the order the server checks them,
+// followed by the selected and shadowed rows
+func (res *Result) WriteText(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Login as %s from %s\n", account.QuoteString(res.User), res.From)
+	if len(res.Candidates) == 0 {
+		fmt.Fprintln(&b, "No account rows for this user")
+	} else {
+		tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "#\tACCOUNT\tSPECIFICITY\tRESULT")
+		for i, c := range res.Candidates {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", i+1, c.Account, c.Specificity, c.Note)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if res.Selected != "" {
+		fmt.Fprintf(&b, "Selected: %s\n", res.Selected)
+	} else {
+		fmt.Fprintln(&b, "Selected: none, access denied")
+	}
+	for _, s := range res.Shadowed {
+		fmt.Fprintf(&b, "Shadowed: %s %s\n", s.Account, s.Reason)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,172 @@
+package login
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/stretchr/testify/assert"
+)
+
+const accountsSQL = `
+CREATE USER 'app'@'%';
+CREATE USER 'app'@'10.%';
+CREATE USER 'app'@'10.1.%';
+CREATE USER 'app'@'10.1.2.%';
+CREATE USER 'app'@'10.1.2.0/255.255.255.0';
+CREATE USER 'app'@'db-%.example.com';
+CREATE USER 'app'@'%.example.com';
+CREATE USER ''@'localhost';
+CREATE USER 'app'@'localhost' ACCOUNT LOCK;
+CREATE USER 'other'@'%';
+`
+
+func match(t *testing.T, sql, user, from string) *Result {
+	t.Helper()
+	accounts, err := account.ParseSQL(sql)
+	assert.NoError(t, err)
+	return Match(accounts, user, from)
+}
+
+// order returns the candidate accounts in the order they are checked
+func order(res *Result) []string {
+	var out []string
+	for _, c := range res.Candidates {
+		out = append(out, c.Account)
+	}
+	return out
+}
+
+func TestMatch_Order(t *testing.T) {
+	res := match(t, accountsSQL, "app", "10.1.2.3")
+	assert.Equal(t, []string{
+		"`app`@`localhost`",
+		"``@`localhost`",
+		"`app`@`10.1.2.0/255.255.255.0`",
+		"`app`@`10.1.2.%`",
+		"`app`@`10.1.%`",
+		"`app`@`10.%`",
+		"`app`@`db-%.example.com`",
+		"`app`@`%`",
+		"`app`@`%.example.com`",
+	}, order(res))
+	assert.Equal(t, "`app`@`10.1.2.0/255.255.255.0`", res.Selected)
+
+	var matching []string
+	for _, c := range res.Candidates {
+		if c.Matches {
+			matching = append(matching, c.Account)
+		}
+	}
+	assert.Equal(t, []string{"`app`@`10.1.2.0/255.255.255.0`", "`app`@`10.1.2.%`", "`app`@`10.1.%`", "`app`@`10.%`", "`app`@`%`"}, matching)
+	assert.Equal(t, "exact host, any user", res.Candidates[1].Specificity)
+	assert.Equal(t, "IP with netmask /24", res.Candidates[2].Specificity)
+	assert.Equal(t, "wildcard after 7 characters", res.Candidates[3].Specificity)
+	assert.Equal(t, "leading wildcard", res.Candidates[8].Specificity)
+	assert.Equal(t, "matches, but `app`@`10.1.2.0/255.255.255.0` is checked first", res.Candidates[4].Note)
+	assert.Equal(t, "host name, matches only if 10.1.2.3 resolves to it (not checked)", res.Candidates[6].Note)
+
+	res = match(t, accountsSQL, "app", "10.9.0.1")
+	assert.Equal(t, "`app`@`10.%`", res.Selected)
+
+	res = match(t, accountsSQL, "app", "DB-1.example.com")
+	assert.Equal(t, "`app`@`db-%.example.com`", res.Selected)
+}
+
+func TestMatch_LiteralBeforeNetmask(t *testing.T) {
+	res := match(t, "CREATE USER 'app'@'10.0.0.0/255.255.255.0';\nCREATE USER 'app'@'10.0.0.5';\nCREATE USER 'app'@'10.0.0.0/255.255.0.0';\n", "app", "10.0.0.5")
+	assert.Equal(t, []string{"`app`@`10.0.0.5`", "`app`@`10.0.0.0/255.255.255.0`", "`app`@`10.0.0.0/255.255.0.0`"}, order(res))
+	assert.Equal(t, "`app`@`10.0.0.5`", res.Selected)
+	assert.Empty(t, res.Shadowed)
+
+	res = match(t, "CREATE USER 'app'@'10.0.0.0/255.255.255.0';\nCREATE USER 'app'@'10.0.0.5';\n", "app", "10.0.0.9")
+	assert.Equal(t, "`app`@`10.0.0.0/255.255.255.0`", res.Selected)
+}
+
+func TestMatch_Shadowed(t *testing.T) {
+	res := match(t, accountsSQL, "app", "10.1.2.3")
+	assert.Equal(t, []Shadow{
+		{
+			Account: "`app`@`10.1.2.%`",
+			By:      "`app`@`10.1.2.0/255.255.255.0`",
+			Reason:  "can never be matched: `app`@`10.1.2.0/255.255.255.0` is checked first and matches every host it does",
+		},
+		{
+			Account: "`app`@`%.example.com`",
+			By:      "`app`@`%`",
+			Reason:  "ranks the same as `app`@`%`, which one the server checks first is not defined",
+		},
+	}, res.Shadowed)
+
+	// an anonymous account shadows user rows with a less specific host
+	res = match(t, "CREATE USER ''@'10.0.0.0/255.0.0.0';\nCREATE USER 'app'@'10.%';\nCREATE USER 'app'@'192.168.%';\n", "app", "10.1.2.3")
+	assert.Equal(t, "``@`10.0.0.0/255.0.0.0`", res.Selected)
+	assert.Equal(t, []Shadow{{
+		Account: "`app`@`10.%`",
+		By:      "``@`10.0.0.0/255.0.0.0`",
+		Reason:  "can never be matched: ``@`10.0.0.0/255.0.0.0` is checked first and matches every host it does",
+	}}, res.Shadowed)
+}
+
+func TestMatch_Notes(t *testing.T) {
+	res := match(t, accountsSQL, "app", "localhost")
+	assert.Equal(t, "`app`@`localhost`", res.Selected)
+	assert.Equal(t, "selected: locked, the login is refused", res.Candidates[0].Note)
+
+	res = match(t, accountsSQL, "web", "localhost")
+	assert.Equal(t, "``@`localhost`", res.Selected)
+	assert.Equal(t, "selected: anonymous account, the session runs as ``@`localhost` with its password and privileges", res.Candidates[0].Note)
+
+	res = match(t, accountsSQL, "web", "10.0.0.1")
+	assert.Empty(t, res.Selected)
+}
+
+func TestCoversHost(t *testing.T) {
+	row := func(host string) row { return newRow(&account.Account{User: "app", Host: host}) }
+	for _, c := range []struct {
+		a, b   string
+		covers bool
+	}{
+		{"%", "10.%", true},
+		{"10.%", "10.1.%", true},
+		{"10.1.%", "10.%", false},
+		{"10.%", "10.1.2.3", true},
+		{"10.0.0.0/8", "10.1.2.3", true},
+		{"10.0.0.0/8", "10.1.%", true},
+		{"10.0.0.0/16", "10.1.%", false},
+		{"10.1.0.0/255.255.0.0", "10.1.2.0/24", true},
+		{"10.1.2.0/24", "10.1.0.0/16", false},
+		{"%.example.com", "db1.example.com", true},
+		{"%.example.com", "db%.example.com", false},
+	} {
+		assert.Equal(t, c.covers, coversHost(row(c.a), row(c.b)), "%s %s", c.a, c.b)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var b bytes.Buffer
+	res := match(t, "CREATE USER 'app'@'%';\nCREATE USER 'app'@'10.%';\nCREATE USER 'app'@'10.0.0.0/8';\n", "app", "10.1.2.3")
+	assert.NoError(t, res.WriteText(&b))
+	assert.Equal(t, "Login as 'app' from 10.1.2.3\n"+
+		"#  ACCOUNT             SPECIFICITY                  RESULT\n"+
+		"1  `app`@`10.0.0.0/8`  IP with netmask /8           selected\n"+
+		"2  `app`@`10.%`        wildcard after 3 characters  matches, but `app`@`10.0.0.0/8` is checked first\n"+
+		"3  `app`@`%`           any host                     matches, but `app`@`10.0.0.0/8` is checked first\n"+
+		"Selected: `app`@`10.0.0.0/8`\n"+
+		"Shadowed: `app`@`10.%` can never be matched: `app`@`10.0.0.0/8` is checked first and matches every host it does\n", b.String())
+
+	b.Reset()
+	assert.NoError(t, match(t, "CREATE USER 'app'@'10.%';\n", "app", "192.168.0.1").WriteText(&b))
+	assert.Contains(t, b.String(), "Selected: none, access denied\n")
+}
+
+func TestWriteJSON(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, match(t, accountsSQL, "app", "10.1.2.3").WriteJSON(&b))
+	var decoded map[string]any
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &decoded))
+	assert.Equal(t, "`app`@`10.1.2.0/255.255.255.0`", decoded["selected"])
+	assert.Len(t, decoded["candidates"], 9)
+	assert.Len(t, decoded["shadowed"], 2)
+}
@@ -0,0 +1,236 @@
+// Package migrate plans moving accounts from one authentication plugin to
+// another, typically off mysql_native_password
+package migrate
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/passhash"
+)
+
+// passwordLength is the length of generated passwords
+const passwordLength = 24
+
+// Credential is a password for an account. CurrentPassword is only needed
+// to keep the current password valid during a RETAIN CURRENT PASSWORD
+// migration.
+type Credential struct {
+	User            string
+	Host            string
+	Password        string
+	CurrentPassword string
+}
+
+// ClientConnection is a user and client host pair the server has seen
+type ClientConnection struct {
+	User    string
+	Host    string
+	Current int64
+	Total   int64
+}
+
+// Options controls how Build creates statements
+type Options struct {
+	To            string // plugin to migrate to
+	Generate      bool   // generate passwords for accounts without one
+	RetainCurrent bool   // keep the current password valid as secondary password
+}
+
+// Select returns the unlocked accounts using plugin. Locked accounts and
+// roles cannot log in and need no migration.
+func Select(accounts []account.Account, plugin string) []account.Account {
+	var out []account.Account
+	for _, a := range accounts {
+		if a.Plugin == plugin && !a.Locked {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// ReadCredentials reads a CSV file with a header row naming the user, host,
+// password and current_password columns. Only user is required; host
+// defaults to %.
+func ReadCredentials(r io.Reader) (map[string]Credential, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	cols := make(map[string]int)
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := cols["user"]; !ok {
+		return nil, fmt.Errorf("CSV header has no user column")
+	}
+	field := func(rec []string, name string) string {
+		if i, ok := cols[name]; ok && i < len(rec) {
+			return rec[i]
+		}
+		return ""
+	}
+
+	creds := make(map[string]Credential)
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return creds, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		c := Credential{
+			User:            field(rec, "user"),
+			Host:            field(rec, "host"),
+			Password:        field(rec, "password"),
+			CurrentPassword: field(rec, "current_password"),
+		}
+		if c.Host == "" {
+			c.Host = "%"
+		}
+		creds[account.Quote(c.User, c.Host)] = c
+	}
+}
+
+// WriteCredentials writes user, host and password as CSV
+func WriteCredentials(w io.Writer, creds []Credential) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"user", "host", "password"})
+	for _, c := range creds {
+		cw.Write([]string{c.User, c.Host, c.Password})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Build returns the ALTER USER statements that move accounts to opts.To and
+// the passwords it generated. With RetainCurrent every account is first
+// switched to the new plugin with its current password, which clients keep
+// using, and then given the new password with RETAIN CURRENT PASSWORD; MySQL
+// refuses to retain a password in the statement that changes the plugin.
+func Build(accounts []account.Account, creds map[string]Credential, opts Options) ([]string, []Credential, error) {
+	var stmts []string
+	var generated []Credential
+	var missing, noCurrent, wrongCurrent []string
+	for _, a := range accounts {
+		c := creds[a.ID()]
+		password := c.Password
+		if password == "" && opts.Generate {
+			var err error
+			if password, err = passhash.NewPassword(passwordLength); err != nil {
+				return nil, nil, err
+			}
+			generated = append(generated, Credential{User: a.User, Host: a.Host, Password: password})
+		}
+		if password == "" {
+			missing = append(missing, a.ID())
+			continue
+		}
+
+		if !opts.RetainCurrent {
+			stmts = append(stmts, alterPlugin(a.ID(), opts.To, password))
+			continue
+		}
+		if c.CurrentPassword == "" {
+			noCurrent = append(noCurrent, a.ID())
+			continue
+		}
+		// a wrong current password would lock the clients out
+		ok, err := passhash.Verify(a.Plugin, a.AuthBytes(), c.CurrentPassword)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to verify the current password of %s: %w", a.ID(), err)
+		}
+		if !ok {
+			wrongCurrent = append(wrongCurrent, a.ID())
+			continue
+		}
+		stmts = append(stmts,
+			alterPlugin(a.ID(), opts.To, c.CurrentPassword),
+			"ALTER USER "+a.ID()+" IDENTIFIED BY "+account.QuoteString(password)+" RETAIN CURRENT PASSWORD")
+	}
+
+	switch {
+	case len(missing) > 0:
+		return nil, nil, fmt.Errorf("no password for %s", strings.Join(missing, ", "))
+	case len(noCurrent) > 0:
+		return nil, nil, fmt.Errorf("no current_password for %s", strings.Join(noCurrent, ", "))
+	case len(wrongCurrent) > 0:
+		return nil, nil, fmt.Errorf("current_password does not match for %s", strings.Join(wrongCurrent, ", "))
+	}
+	return stmts, generated, nil
+}
+
+func alterPlugin(id, plugin, password string) string {
+	return "ALTER USER " + id + " IDENTIFIED WITH " + account.QuoteString(plugin) + " BY " + account.QuoteString(password)
+}
+
+// WriteInventory lists the accounts grouped by authentication plugin
+func WriteInventory(w io.Writer, accounts []account.Account) error {
+	byPlugin := make(map[string][]string)
+	for _, a := range accounts {
+		plugin := a.Plugin
+		if plugin == "" {
+			plugin = "unknown"
+		}
+		id := a.ID()
+		if a.Locked {
+			id += " (locked)"
+		}
+		byPlugin[plugin] = append(byPlugin[plugin], id)
+	}
+	plugins := make([]string, 0, len(byPlugin))
+	for p := range byPlugin {
+		plugins = append(plugins, p)
+	}
+	sort.Strings(plugins)
+
+	var b strings.Builder
+	fmt.Fprintln(&b, "Accounts by plugin:")
+	for _, p := range plugins {
+		fmt.Fprintf(&b, "  %s (%d)\n", p, len(byPlugin[p]))
+		for _, id := range byPlugin[p] {
+			fmt.Fprintf(&b, "    %s\n", id)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteClients reports the client hosts each account was used from, so the
+// owners of those clients can check their connectors support the new plugin
+func WriteClients(w io.Writer, plugin string, accounts []account.Account, conns []ClientConnection) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Clients of %s accounts:\n", plugin)
+	for _, a := range accounts {
+		var matched []ClientConnection
+		for _, c := range conns {
+			if c.User == a.User && hostMatches(a.Host, c.Host) {
+				matched = append(matched, c)
+			}
+		}
+		if len(matched) == 0 {
+			fmt.Fprintf(&b, "  %s: no connections since the server started\n", a.ID())
+			continue
+		}
+		fmt.Fprintf(&b, "  %s\n", a.ID())
+		for _, c := range matched {
+			fmt.Fprintf(&b, "    %s: %d current, %d total connections\n", c.Host, c.Current, c.Total)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// hostMatches reports whether a client host matches an account host pattern
+// using LIKE semantics. Host names are not case sensitive and an empty
+// pattern matches any host.
+func hostMatches(pattern, host string) bool {
+	return pattern == "" || account.Like(strings.ToLower(pattern), strings.ToLower(host))
+}
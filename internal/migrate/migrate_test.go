@@ -0,0 +1,136 @@
+package migrate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/stretchr/testify/assert"
+)
+
+const accountsSQL = `
+CREATE USER 'app'@'10.%' IDENTIFIED WITH 'mysql_native_password' AS '*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19';
+CREATE USER 'legacy'@'localhost' IDENTIFIED WITH 'mysql_native_password' AS '*81F5E21E35407D884A6CD4A731AEBFB6AF209E1B';
+CREATE USER 'modern'@'%' IDENTIFIED WITH 'caching_sha2_password' AS 0x24;
+CREATE USER 'role'@'%' IDENTIFIED WITH 'mysql_native_password' ACCOUNT LOCK;
+`
+
+func parseAccounts(t *testing.T) []account.Account {
+	t.Helper()
+	accounts, err := account.ParseSQL(accountsSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return accounts
+}
+
+func TestSelect(t *testing.T) {
+	var ids []string
+	for _, a := range Select(parseAccounts(t), "mysql_native_password") {
+		ids = append(ids, a.ID())
+	}
+	assert.Equal(t, []string{"`app`@`10.%`", "`legacy`@`localhost`"}, ids)
+}
+
+func TestReadCredentials(t *testing.T) {
+	creds, err := ReadCredentials(strings.NewReader("User,Host,Password,current_password\napp,10.%,n3w,password\nlegacy,,s3cret\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, Credential{User: "app", Host: "10.%", Password: "n3w", CurrentPassword: "password"}, creds["`app`@`10.%`"])
+	assert.Equal(t, "s3cret", creds["`legacy`@`%`"].Password)
+
+	_, err = ReadCredentials(strings.NewReader("name,password\napp,x\n"))
+	assert.ErrorContains(t, err, "no user column")
+}
+
+func TestBuild(t *testing.T) {
+	selected := Select(parseAccounts(t), "mysql_native_password")
+	creds := map[string]Credential{
+		"`app`@`10.%`":         {Password: "n3w'pw"},
+		"`legacy`@`localhost`": {Password: "s3cret"},
+	}
+	stmts, generated, err := Build(selected, creds, Options{To: "caching_sha2_password"})
+	assert.NoError(t, err)
+	assert.Empty(t, generated)
+	assert.Equal(t, []string{
+		"ALTER USER `app`@`10.%` IDENTIFIED WITH 'caching_sha2_password' BY 'n3w\\'pw'",
+		"ALTER USER `legacy`@`localhost` IDENTIFIED WITH 'caching_sha2_password' BY 's3cret'",
+	}, stmts)
+
+	_, _, err = Build(selected, map[string]Credential{}, Options{To: "caching_sha2_password"})
+	assert.EqualError(t, err, "no password for `app`@`10.%`, `legacy`@`localhost`")
+}
+
+func TestBuild_Generate(t *testing.T) {
+	selected := Select(parseAccounts(t), "mysql_native_password")
+	creds := map[string]Credential{"`app`@`10.%`": {Password: "given"}}
+	stmts, generated, err := Build(selected, creds, Options{To: "caching_sha2_password", Generate: true})
+	assert.NoError(t, err)
+	assert.Len(t, stmts, 2)
+	if assert.Len(t, generated, 1) {
+		assert.Equal(t, "legacy", generated[0].User)
+		assert.Len(t, generated[0].Password, passwordLength)
+		assert.Contains(t, stmts[1], account.QuoteString(generated[0].Password))
+	}
+}
+
+func TestBuild_RetainCurrent(t *testing.T) {
+	selected := Select(parseAccounts(t), "mysql_native_password")[:1]
+	opts := Options{To: "caching_sha2_password", RetainCurrent: true}
+
+	stmts, _, err := Build(selected, map[string]Credential{"`app`@`10.%`": {Password: "n3w", CurrentPassword: "password"}}, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER USER `app`@`10.%` IDENTIFIED WITH 'caching_sha2_password' BY 'password'",
+		"ALTER USER `app`@`10.%` IDENTIFIED BY 'n3w' RETAIN CURRENT PASSWORD",
+	}, stmts)
+
+	_, _, err = Build(selected, map[string]Credential{"`app`@`10.%`": {Password: "n3w"}}, opts)
+	assert.ErrorContains(t, err, "no current_password")
+	_, _, err = Build(selected, map[string]Credential{"`app`@`10.%`": {Password: "n3w", CurrentPassword: "wrong"}}, opts)
+	assert.ErrorContains(t, err, "current_password does not match")
+}
+
+func TestWriteCredentials(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCredentials(&buf, []Credential{{User: "app", Host: "10.%", Password: "a,b"}}))
+	assert.Equal(t, "user,host,password\napp,10.%,\"a,b\"\n", buf.String())
+}
+
+func TestWriteInventory(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteInventory(&buf, parseAccounts(t)))
+	assert.Equal(t, "Accounts by plugin:\n"+
+		"  caching_sha2_password (1)\n"+
+		"    `modern`@`%`\n"+
+		"  mysql_native_password (3)\n"+
+		"    `app`@`10.%`\n"+
+		"    `legacy`@`localhost`\n"+
+		"    `role`@`%` (locked)\n", buf.String())
+}
+
+func TestWriteClients(t *testing.T) {
+	selected := Select(parseAccounts(t), "mysql_native_password")
+	conns := []ClientConnection{
+		{User: "app", Host: "10.0.0.5", Current: 2, Total: 150},
+		{User: "app", Host: "192.168.1.9", Current: 0, Total: 3},
+		{User: "modern", Host: "10.0.0.7", Current: 1, Total: 1},
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, WriteClients(&buf, "mysql_native_password", selected, conns))
+	assert.Equal(t, "Clients of mysql_native_password accounts:\n"+
+		"  `app`@`10.%`\n"+
+		"    10.0.0.5: 2 current, 150 total connections\n"+
+		"  `legacy`@`localhost`: no connections since the server started\n", buf.String())
+}
+
+func TestHostMatches(t *testing.T) {
+	assert.True(t, hostMatches("%", "anything"))
+	assert.True(t, hostMatches("10.0.0._", "10.0.0.5"))
+	assert.False(t, hostMatches("10.0.0._", "10.0.0.55"))
+	assert.True(t, hostMatches("LocalHost", "localhost"))
+	assert.False(t, hostMatches("10.%", "110.0.0.1"))
+	assert.True(t, hostMatches("", "anything"))
+	assert.True(t, hostMatches(`db\_1.example.com`, "DB_1.example.com"))
+	assert.False(t, hostMatches(`db\_1.example.com`, "dbx1.example.com"))
+}
@@ -0,0 +1,165 @@
+// Package passhash generates MySQL authentication strings offline, so
+// accounts can be created with pre-hashed passwords without a server
+package passhash
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Plugins supported by Generate
+const (
+	CachingSHA2Password = "caching_sha2_password"
+	SHA256Password      = "sha256_password"
+	NativePassword      = "mysql_native_password"
+)
+
+// Plugins lists the plugins Generate can hash passwords for
+var Plugins = []string{CachingSHA2Password, SHA256Password, NativePassword}
+
+const (
+	// saltLength is the salt size MySQL uses for its SHA-256 based plugins
+	saltLength = 20
+	// cachingSHA2Rounds is the default caching_sha2_password_digest_rounds
+	cachingSHA2Rounds = 5000
+	// sha256Rounds is the fixed round count of sha256_password
+	sha256Rounds = 5000
+)
+
+// generators hash a password with a fresh salt for each supported plugin
+var generators = map[string]func(password string) (string, error){
+	CachingSHA2Password: func(password string) (string, error) {
+		salt, err := NewSalt()
+		if err != nil {
+			return "", err
+		}
+		return CachingSHA2(password, salt), nil
+	},
+	SHA256Password: func(password string) (string, error) {
+		salt, err := NewSalt()
+		if err != nil {
+			return "", err
+		}
+		return SHA256(password, salt), nil
+	},
+	NativePassword: func(password string) (string, error) {
+		return Native(password), nil
+	},
+}
+
+// Generate hashes password for plugin with a random salt
+func Generate(plugin, password string) (string, error) {
+	generate, ok := generators[plugin]
+	if !ok {
+		return "", fmt.Errorf("unsupported plugin %q (supported: %s)", plugin, strings.Join(Plugins, ", "))
+	}
+	if password == "" {
+		return "", fmt.Errorf("empty password")
+	}
+	return generate(password)
+}
+
+// NewSalt returns a random salt the way MySQL generates one: 7-bit bytes
+// with NUL and '$' bumped to the next value, so the salt never contains the
+// field separator of the stored hash
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	for i := range salt {
+		salt[i] &= 0x7f
+		if salt[i] == 0 || salt[i] == '$' {
+			salt[i]++
+		}
+	}
+	return salt, nil
+}
+
+// CachingSHA2 returns the caching_sha2_password authentication string
+// $A$<rounds/1000 in hex>$<salt><digest> for password and salt
+func CachingSHA2(password string, salt []byte) string {
+	return fmt.Sprintf("$A$%03X$%s%s", cachingSHA2Rounds/1000, salt,
+		SHA256Crypt([]byte(password), salt, cachingSHA2Rounds))
+}
+
+// SHA256 returns the sha256_password authentication string $5$<salt>$<digest>
+// for password and salt
+func SHA256(password string, salt []byte) string {
+	return fmt.Sprintf("$5$%s$%s", salt, SHA256Crypt([]byte(password), salt, sha256Rounds))
+}
+
+// Native returns the mysql_native_password authentication string, the same
+// value the removed PASSWORD() function returned: '*' followed by the upper
+// case hex of SHA1(SHA1(password))
+func Native(password string) string {
+	first := sha1.Sum([]byte(password))
+	second := sha1.Sum(first[:])
+	return "*" + strings.ToUpper(hex.EncodeToString(second[:]))
+}
+
+// Verify reports whether password matches the authentication string auth
+// stored for plugin. Nothing is sent to a server; the stored hash is
+// recomputed with its own salt and rounds.
+func Verify(plugin string, auth []byte, password string) (bool, error) {
+	if _, ok := generators[plugin]; !ok {
+		return false, fmt.Errorf("unsupported plugin %q (supported: %s)", plugin, strings.Join(Plugins, ", "))
+	}
+	if len(auth) == 0 {
+		// an empty authentication string means an empty password
+		return password == "", nil
+	}
+	var want, got []byte
+	switch plugin {
+	case CachingSHA2Password:
+		// $A$<rounds/1000 in 3 hex digits>$<20 byte salt><43 byte digest>
+		if len(auth) != 7+saltLength+43 || string(auth[:3]) != "$A$" || auth[6] != '$' {
+			return false, fmt.Errorf("malformed %s authentication string", plugin)
+		}
+		rounds, err := strconv.ParseUint(string(auth[3:6]), 16, 16)
+		if err != nil || rounds == 0 {
+			return false, fmt.Errorf("malformed %s rounds %q", plugin, auth[3:6])
+		}
+		salt := auth[7 : 7+saltLength]
+		want = auth[7+saltLength:]
+		got = []byte(SHA256Crypt([]byte(password), salt, int(rounds)*1000))
+	case SHA256Password:
+		// $5$<20 byte salt>$<43 byte digest>
+		if len(auth) != 3+saltLength+1+43 || string(auth[:3]) != "$5$" || auth[3+saltLength] != '$' {
+			return false, fmt.Errorf("malformed %s authentication string", plugin)
+		}
+		salt := auth[3 : 3+saltLength]
+		want = auth[4+saltLength:]
+		got = []byte(SHA256Crypt([]byte(password), salt, sha256Rounds))
+	case NativePassword:
+		if len(auth) != 41 || auth[0] != '*' {
+			return false, fmt.Errorf("malformed %s authentication string", plugin)
+		}
+		want = bytes.ToUpper(auth)
+		got = []byte(Native(password))
+	}
+	return subtle.ConstantTimeCompare(want, got) == 1, nil
+}
+
+// passwordAlphabet avoids quotes, backslashes and whitespace so generated
+// passwords survive SQL, shell and CSV quoting unchanged
+const passwordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789-_.:+=^"
+
+// NewPassword returns a random password of length characters
+func NewPassword(length int) (string, error) {
+	buf := make([]byte, length)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	// the alphabet has 64 characters, so masking keeps the distribution uniform
+	for i := range buf {
+		buf[i] = passwordAlphabet[buf[i]&0x3f]
+	}
+	return string(buf), nil
+}
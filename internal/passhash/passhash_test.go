@@ -0,0 +1,168 @@
+package passhash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSHA256Crypt(t *testing.T) {
+	tests := []struct {
+		password, salt string
+		rounds         int
+		want           string
+	}{
+		// openssl passwd -5 -salt saltstring 'Hello world!'
+		{"Hello world!", "saltstring", 5000, "5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5"},
+		// reference vector from the SHA-crypt specification
+		{"Hello world!", "saltstringsaltst", 10000, "3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA"},
+		// 20 byte salt as used by MySQL, which crypt(3) would truncate
+		{"password", "ABCDEFGHIJKLMNOPQRST", 5000, "vl9UGLS34qbqXfZtQ37dCF3HlKnANRTJubCs9p/NZ3C"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.salt, func(t *testing.T) {
+			assert.Equal(t, tt.want, SHA256Crypt([]byte(tt.password), []byte(tt.salt), tt.rounds))
+		})
+	}
+}
+
+func TestCachingSHA2(t *testing.T) {
+	got := CachingSHA2("password", []byte("ABCDEFGHIJKLMNOPQRST"))
+	assert.Equal(t, "$A$005$ABCDEFGHIJKLMNOPQRSTvl9UGLS34qbqXfZtQ37dCF3HlKnANRTJubCs9p/NZ3C", got)
+	assert.Len(t, got, 70)
+}
+
+// hashcatSalt and hashcatDigest are the salt and digest of hashcat's
+// example for MySQL $A$ hashes (mode 7401), whose password is hashcat. The
+// salt holds bytes above 0x7F, as salts read back from a server can.
+var (
+	hashcatSalt   = "\xf9\xcc\x98\xce\x08\x89\x29\x24\xf5\x0a\x21\x3b\x6b\xc5\x71\xa2\xc1\x17\x78\xc5"
+	hashcatDigest = "bTy95Y99eAME1dwEkHOA1ndHGBWz.1bxSSRkuTXFGV/"
+)
+
+func TestCachingSHA2_KnownHash(t *testing.T) {
+	// $mysql$A$005*F9CC98CE08892924F50A213B6BC571A2C11778C5*6254793935...
+	auth := "$A$005$" + hashcatSalt + hashcatDigest
+	assert.Equal(t, auth, CachingSHA2("hashcat", []byte(hashcatSalt)))
+	ok, err := Verify(CachingSHA2Password, []byte(auth), "hashcat")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestSHA256(t *testing.T) {
+	got := SHA256("password", []byte("ABCDEFGHIJKLMNOPQRST"))
+	assert.Equal(t, "$5$ABCDEFGHIJKLMNOPQRST$vl9UGLS34qbqXfZtQ37dCF3HlKnANRTJubCs9p/NZ3C", got)
+}
+
+func TestSHA256_KnownHash(t *testing.T) {
+	// sha256_password stores the same 5000 round SHA-crypt digest as
+	// caching_sha2_password at its default rounds, framed as $5$<salt>$
+	auth := "$5$" + hashcatSalt + "$" + hashcatDigest
+	assert.Equal(t, auth, SHA256("hashcat", []byte(hashcatSalt)))
+	ok, err := Verify(SHA256Password, []byte(auth), "hashcat")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestNative(t *testing.T) {
+	// PASSWORD('password') and PASSWORD('root') on MySQL 5.7
+	assert.Equal(t, "*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19", Native("password"))
+	assert.Equal(t, "*81F5E21E35407D884A6CD4A731AEBFB6AF209E1B", Native("root"))
+}
+
+func TestNewSalt(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		salt, err := NewSalt()
+		assert.NoError(t, err)
+		assert.Len(t, salt, 20)
+		for _, c := range salt {
+			assert.True(t, c > 0 && c < 0x80 && c != '$', "invalid salt byte %#x", c)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	got, err := Generate(CachingSHA2Password, "secret")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(got, "$A$005$"))
+	assert.Len(t, got, 70)
+
+	got, err = Generate(SHA256Password, "secret")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(got, "$5$"))
+	assert.Len(t, got, 67)
+	assert.Equal(t, byte('$'), got[23])
+
+	got, err = Generate(NativePassword, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19", got)
+
+	_, err = Generate(CachingSHA2Password, "")
+	assert.Error(t, err)
+	_, err = Generate("unknown", "secret")
+	assert.ErrorContains(t, err, "unsupported plugin")
+}
+
+func TestVerify(t *testing.T) {
+	salt := []byte("ABCDEFGHIJKLMNOPQRST")
+	tests := []struct {
+		plugin string
+		auth   string
+	}{
+		{CachingSHA2Password, CachingSHA2("password", salt)},
+		{SHA256Password, SHA256("password", salt)},
+		{NativePassword, Native("password")},
+		{NativePassword, strings.ToLower(Native("password"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.plugin, func(t *testing.T) {
+			ok, err := Verify(tt.plugin, []byte(tt.auth), "password")
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = Verify(tt.plugin, []byte(tt.auth), "Password")
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestVerify_Rounds(t *testing.T) {
+	// caching_sha2_password_digest_rounds = 10000 is stored as $A$00A$
+	salt := []byte("ABCDEFGHIJKLMNOPQRST")
+	auth := "$A$00A$" + string(salt) + SHA256Crypt([]byte("password"), salt, 10000)
+	ok, err := Verify(CachingSHA2Password, []byte(auth), "password")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestVerify_EmptyAndMalformed(t *testing.T) {
+	ok, err := Verify(CachingSHA2Password, nil, "")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = Verify(CachingSHA2Password, nil, "password")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = Verify(CachingSHA2Password, []byte("$A$005$short"), "password")
+	assert.Error(t, err)
+	_, err = Verify(NativePassword, []byte("2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19"), "password")
+	assert.Error(t, err)
+	_, err = Verify("auth_socket", nil, "")
+	assert.ErrorContains(t, err, "unsupported plugin")
+	_, err = Verify("auth_socket", []byte("x"), "password")
+	assert.ErrorContains(t, err, "unsupported plugin")
+}
+
+func TestNewPassword(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		pw, err := NewPassword(24)
+		assert.NoError(t, err)
+		assert.Len(t, pw, 24)
+		assert.False(t, strings.ContainsAny(pw, "'\"\\` \t\n"))
+		assert.False(t, seen[pw])
+		seen[pw] = true
+	}
+}
@@ -0,0 +1,98 @@
+package passhash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+)
+
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// SHA256Crypt returns the 43 character digest part of a SHA-256 crypt hash
+// (the $5$ scheme). Unlike crypt(3) the salt is not truncated to 16 bytes,
+// which is what MySQL relies on for its 20 byte salts.
+func SHA256Crypt(password, salt []byte, rounds int) string {
+	sum := func(parts ...[]byte) []byte {
+		h := sha256.New()
+		for _, p := range parts {
+			h.Write(p)
+		}
+		return h.Sum(nil)
+	}
+	// repeatFill writes digest repeatedly to w until n bytes were written
+	repeatFill := func(w io.Writer, digest []byte, n int) {
+		for ; n > len(digest); n -= len(digest) {
+			w.Write(digest)
+		}
+		w.Write(digest[:n])
+	}
+	sequence := func(digest []byte, n int) []byte {
+		var buf bytes.Buffer
+		repeatFill(&buf, digest, n)
+		return buf.Bytes()
+	}
+
+	b := sum(password, salt, password)
+
+	h := sha256.New()
+	h.Write(password)
+	h.Write(salt)
+	repeatFill(h, b, len(password))
+	for n := len(password); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(password)
+		}
+	}
+	a := h.Sum(nil)
+
+	dp := sha256.New()
+	for range password {
+		dp.Write(password)
+	}
+	p := sequence(dp.Sum(nil), len(password))
+
+	ds := sha256.New()
+	for i := 0; i < 16+int(a[0]); i++ {
+		ds.Write(salt)
+	}
+	s := sequence(ds.Sum(nil), len(salt))
+
+	c := a
+	for i := 0; i < rounds; i++ {
+		h := sha256.New()
+		if i&1 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(c)
+		}
+		if i%3 != 0 {
+			h.Write(s)
+		}
+		if i%7 != 0 {
+			h.Write(p)
+		}
+		if i&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(p)
+		}
+		c = h.Sum(nil)
+	}
+
+	out := make([]byte, 0, 43)
+	encode := func(b2, b1, b0 byte, n int) {
+		w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+		for ; n > 0; n-- {
+			out = append(out, cryptAlphabet[w&0x3f])
+			w >>= 6
+		}
+	}
+	for i := 0; i < 10; i++ {
+		// bytes are taken in the order 0,10,20 21,1,11 12,22,2 ...
+		encode(c[(i*21)%30], c[(i*21+10)%30], c[(i*21+20)%30], 4)
+	}
+	encode(0, c[31], c[30], 3)
+	return string(out)
+}
@@ -0,0 +1,385 @@
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/diff"
+	"github.com/ChaosHour/go-pass/internal/sqlsplit"
+)
+
+const header = "-- go-pass plan"
+
+// Plan is an ordered list of statements that makes a target server's accounts
+// match a source, together with the state of the target it was computed from
+type Plan struct {
+	Target         string
+	OnlyUser       string
+	TargetChecksum string
+	Statements     []string
+}
+
+// Build computes the minimal statements that turn target into source,
+// rendered in the dialect of the target server. Statements are ordered so
+// that accounts exist before anything is granted to them and roles are
+// granted before they are made default.
+func Build(source, target []account.Account, d account.Dialect) []string {
+	sources := make(map[string]*account.Account, len(source))
+	for i := range source {
+		sources[source[i].ID()] = &source[i]
+	}
+	targets := make(map[string]*account.Account, len(target))
+	for i := range target {
+		targets[target[i].ID()] = &target[i]
+	}
+
+	var creates, alters, revokes, grants, roleGrants, defaults, drops []string
+	report := diff.Compare("source", source, "target", target)
+	for _, c := range report.Changes {
+		s := sources[c.Account]
+		a := s
+		if a == nil {
+			a = targets[c.Account]
+		}
+		grantee := d.Grantee(a)
+		// MariaDB roles have no authentication or account options
+		role := d == account.MariaDB && a.Role
+		switch c.Kind {
+		case diff.OnlySource:
+			creates = append(creates, d.CreateStatement(s))
+			for _, g := range s.Grants {
+				if !usageOnly(g) {
+					grants = append(grants, g.Statement(grantee))
+				}
+			}
+			for _, g := range s.Revokes {
+				grants = append(grants, g.RevokeStatement(grantee))
+			}
+			for _, r := range s.Roles {
+				roleGrants = append(roleGrants, d.RoleGrantStatement(r, grantee))
+			}
+			if len(s.DefaultRoles) > 0 && !role {
+				defaults = append(defaults, d.DefaultRoleStatement(s))
+			}
+		case diff.OnlyTarget:
+			drops = append(drops, d.DropStatement(a))
+		case diff.Plugin, diff.AuthString:
+			if role {
+				continue
+			}
+			if c.Kind == diff.Plugin && s.Plugin == "" {
+				// the source does not know the plugin, e.g. parsed from a file
+				continue
+			}
+			alters = append(alters, "ALTER USER "+c.Account+" "+d.IdentifiedClause(s))
+		case diff.Factors, diff.FactorAuth:
+			alters = append(alters, factorStatements(s, targets[c.Account])...)
+		case diff.Require:
+			if role {
+				continue
+			}
+			require := s.Require
+			if require == "" {
+				require = "NONE"
+			}
+			alters = append(alters, "ALTER USER "+c.Account+" REQUIRE "+require)
+		case diff.PasswordExpire:
+			if s.PasswordExpire != "" && !role {
+				alters = append(alters, "ALTER USER "+c.Account+" "+s.ExpireClause())
+			}
+		case diff.Lock:
+			if !role {
+				alters = append(alters, "ALTER USER "+c.Account+" "+s.LockClause())
+			}
+		case diff.ResourceLimits:
+			if !role {
+				alters = append(alters, "ALTER USER "+c.Account+" "+resourceClause(s, targets[c.Account]))
+			}
+		case diff.PasswordHistory:
+			alters = append(alters, "ALTER USER "+c.Account+" PASSWORD HISTORY "+c.Source)
+		case diff.PasswordReuseInterval:
+			interval := c.Source
+			if interval != "DEFAULT" {
+				interval += " DAY"
+			}
+			alters = append(alters, "ALTER USER "+c.Account+" PASSWORD REUSE INTERVAL "+interval)
+		case diff.PasswordRequireCurrent:
+			alters = append(alters, "ALTER USER "+c.Account+" PASSWORD REQUIRE CURRENT "+c.Source)
+		case diff.FailedLoginAttempts:
+			alters = append(alters, "ALTER USER "+c.Account+" FAILED_LOGIN_ATTEMPTS "+c.Source)
+		case diff.PasswordLockTime:
+			alters = append(alters, "ALTER USER "+c.Account+" PASSWORD_LOCK_TIME "+c.Source)
+		case diff.Comment:
+			if s.Comment == "" {
+				// the comment is stored as an attribute, removing it clears it
+				alters = append(alters, "ALTER USER "+c.Account+` ATTRIBUTE '{"comment": null}'`)
+			} else {
+				alters = append(alters, "ALTER USER "+c.Account+" COMMENT "+account.QuoteString(s.Comment))
+			}
+		case diff.Attributes:
+			alters = append(alters, "ALTER USER "+c.Account+" "+attributeClause(s, targets[c.Account]))
+		case diff.Privileges:
+			if len(c.TargetOnly) > 0 {
+				revokes = append(revokes, "REVOKE "+strings.Join(c.TargetOnly, ", ")+" ON "+c.Level+" FROM "+grantee)
+			}
+			if len(c.SourceOnly) > 0 {
+				grants = append(grants, grantStatement(c.SourceOnly, c.Level, grantee))
+			}
+		case diff.PartialRevokes:
+			// a partial revoke is removed by granting the privilege again
+			if len(c.TargetOnly) > 0 {
+				grants = append(grants, "GRANT "+strings.Join(c.TargetOnly, ", ")+" ON "+c.Level+" TO "+grantee)
+			}
+			if len(c.SourceOnly) > 0 {
+				grants = append(grants, "REVOKE "+strings.Join(c.SourceOnly, ", ")+" ON "+c.Level+" FROM "+grantee)
+			}
+		case diff.Roles:
+			for _, r := range c.TargetOnly {
+				revokes = append(revokes, "REVOKE "+d.RoleName(strings.TrimSuffix(r, " WITH ADMIN OPTION"))+" FROM "+grantee)
+			}
+			for _, r := range c.SourceOnly {
+				name := strings.TrimSuffix(r, " WITH ADMIN OPTION")
+				roleGrants = append(roleGrants, d.RoleGrantStatement(account.RoleGrant{Role: name, AdminOption: name != r}, grantee))
+			}
+		case diff.DefaultRoles:
+			if stmt := d.DefaultRoleStatement(s); stmt != "" {
+				defaults = append(defaults, stmt)
+			}
+		}
+	}
+
+	var stmts []string
+	for _, group := range [][]string{creates, alters, revokes, grants, roleGrants, defaults, drops} {
+		stmts = append(stmts, group...)
+	}
+	return stmts
+}
+
+// factorStatements modifies, adds and drops the additional factors of the
+// target so that they match the source. ALTER USER takes several options of
+// one kind but does not mix them.
+func factorStatements(s, t *account.Account) []string {
+	var modify, add, drop []string
+	for i := 0; i < len(s.Factors) || i < len(t.Factors); i++ {
+		n := i + 2
+		switch {
+		case i >= len(t.Factors):
+			add = append(add, fmt.Sprintf("ADD %d FACTOR %s", n, s.Factors[i].Clause()))
+		case i >= len(s.Factors):
+			drop = append(drop, fmt.Sprintf("DROP %d FACTOR", n))
+		case s.Factors[i] != t.Factors[i]:
+			modify = append(modify, fmt.Sprintf("MODIFY %d FACTOR %s", n, s.Factors[i].Clause()))
+		}
+	}
+	var stmts []string
+	for _, opts := range [][]string{modify, add, drop} {
+		if len(opts) > 0 {
+			stmts = append(stmts, "ALTER USER "+s.ID()+" "+strings.Join(opts, " "))
+		}
+	}
+	return stmts
+}
+
+// resourceClause renders a WITH clause that sets every resource limit to the
+// value of the source, so limits only the target has are reset to 0
+func resourceClause(s, t *account.Account) string {
+	limits := []string{
+		"MAX_QUERIES_PER_HOUR " + strconv.Itoa(s.MaxQueriesPerHour),
+		"MAX_UPDATES_PER_HOUR " + strconv.Itoa(s.MaxUpdatesPerHour),
+		"MAX_CONNECTIONS_PER_HOUR " + strconv.Itoa(s.MaxConnectionsPerHour),
+		"MAX_USER_CONNECTIONS " + strconv.Itoa(s.MaxUserConnections),
+	}
+	if s.MaxStatementTime != "" || t.MaxStatementTime != "" {
+		limit := s.MaxStatementTime
+		if limit == "" {
+			limit = "0"
+		}
+		limits = append(limits, "MAX_STATEMENT_TIME "+limit)
+	}
+	return "WITH " + strings.Join(limits, " ")
+}
+
+// attributeClause renders an ATTRIBUTE clause that sets the user attributes
+// of the source. ATTRIBUTE merges into the existing attributes, so the ones
+// only the target has are removed by setting them to null.
+func attributeClause(s, t *account.Account) string {
+	attrs := make(map[string]any, len(s.Attributes)+len(t.Attributes))
+	for k := range t.Attributes {
+		attrs[k] = nil
+	}
+	for k, v := range s.Attributes {
+		attrs[k] = v
+	}
+	// json.Marshal sorts map keys, which keeps the statement stable
+	data, _ := json.Marshal(attrs)
+	return "ATTRIBUTE " + account.QuoteString(string(data))
+}
+
+func usageOnly(g account.Grant) bool {
+	return len(g.Privileges) == 1 && g.Privileges[0].Name == "USAGE" && !g.GrantOption
+}
+
+// grantStatement renders a GRANT for privilege keys from diff, turning the
+// GRANT OPTION key into WITH GRANT OPTION
+func grantStatement(privs []string, level, grantee string) string {
+	var names []string
+	grantOption := false
+	for _, p := range privs {
+		if p == "GRANT OPTION" {
+			grantOption = true
+			continue
+		}
+		names = append(names, p)
+	}
+	if len(names) == 0 {
+		names = []string{"USAGE"}
+	}
+	stmt := "GRANT " + strings.Join(names, ", ") + " ON " + level + " TO " + grantee
+	if grantOption {
+		stmt += " WITH GRANT OPTION"
+	}
+	return stmt
+}
+
+// Fingerprint returns a checksum of the state of a set of accounts. Grants are
+// compared as privilege sets so the order SHOW GRANTS prints them in does not
+// matter.
+func Fingerprint(accounts []account.Account) string {
+	type canonical struct {
+		ID           string
+		Plugin       string
+		AuthString   string
+		Factors      []account.Factor `json:",omitempty"`
+		Require      string
+		Expire       string
+		Locked       bool
+		Limits       string
+		Password     []string
+		Comment      string
+		Attributes   map[string]any
+		DefaultRoles []string
+		Roles        []string
+		Grants       map[string][]string
+		Revokes      map[string][]string
+	}
+	flatten := func(grants []account.Grant) map[string][]string {
+		out := make(map[string][]string)
+		for level, privs := range account.PrivilegeSet(grants) {
+			out[level] = account.SortedKeys(privs)
+		}
+		return out
+	}
+
+	sorted := append([]account.Account(nil), accounts...)
+	account.Sort(sorted)
+	var state []canonical
+	for _, a := range sorted {
+		roles := make(map[string]bool)
+		for _, r := range a.Roles {
+			roles[fmt.Sprintf("%s %t", r.Role, r.AdminOption)] = true
+		}
+		defaults := make(map[string]bool)
+		for _, r := range a.DefaultRoles {
+			defaults[r] = true
+		}
+		state = append(state, canonical{
+			ID: a.ID(), Plugin: a.Plugin, AuthString: a.AuthString, Factors: a.Factors, Require: a.Require,
+			Expire: a.PasswordExpire, Locked: a.Locked, Limits: a.ResourceClause(), Password: a.PasswordClauses(),
+			Comment: a.Comment, Attributes: a.Attributes,
+			DefaultRoles: account.SortedKeys(defaults), Roles: account.SortedKeys(roles),
+			Grants: flatten(a.Grants), Revokes: flatten(a.Revokes),
+		})
+	}
+	// json.Marshal sorts map keys, which keeps the encoding stable
+	data, _ := json.Marshal(state)
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Checksum returns the checksum of the plan header and statements, so that
+// neither the target nor the statements can be changed unnoticed
+func (p *Plan) Checksum() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "target: %s\nonly-user: %s\ntarget-checksum: %s\n", p.Target, p.OnlyUser, p.TargetChecksum)
+	for _, s := range p.Statements {
+		io.WriteString(h, s+"\n")
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Write writes the plan with a header recording the target, the target
+// checksum and the checksum of the statements
+func (p *Plan) Write(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintln(&b, header)
+	fmt.Fprintf(&b, "-- target: %s\n", p.Target)
+	if p.OnlyUser != "" {
+		fmt.Fprintf(&b, "-- only-user: %s\n", p.OnlyUser)
+	}
+	fmt.Fprintf(&b, "-- target-checksum: %s\n", p.TargetChecksum)
+	fmt.Fprintf(&b, "-- checksum: %s\n", p.Checksum())
+	for _, s := range p.Statements {
+		fmt.Fprintln(&b, s+";")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// IsPlan reports whether data looks like a plan file written by Write
+func IsPlan(data string) bool {
+	return strings.HasPrefix(data, header+"\n")
+}
+
+// Read parses a plan written by Write and verifies that neither its header
+// nor its statements were modified
+func Read(data string) (*Plan, error) {
+	lines := strings.Split(data, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != header {
+		return nil, fmt.Errorf("not a go-pass plan file")
+	}
+
+	// the header is the leading block of comment lines, comments further
+	// down are not parsed
+	p := &Plan{}
+	var checksum string
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "-- ") {
+			break
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "-- "), ": ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "target":
+			p.Target = value
+		case "only-user":
+			p.OnlyUser = value
+		case "target-checksum":
+			p.TargetChecksum = value
+		case "checksum":
+			checksum = value
+		}
+	}
+
+	statements, err := sqlsplit.Split(data)
+	if err != nil {
+		return nil, err
+	}
+	p.Statements = statements
+
+	if checksum == "" || p.TargetChecksum == "" {
+		return nil, fmt.Errorf("plan file is missing its checksums")
+	}
+	if checksum != p.Checksum() {
+		return nil, fmt.Errorf("plan checksum mismatch: the plan was modified after it was created")
+	}
+	return p, nil
+}
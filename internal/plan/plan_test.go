@@ -0,0 +1,204 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func grant(schema string, grantOption bool, privs ...string) account.Grant {
+	g := account.Grant{Schema: schema, Object: "*", GrantOption: grantOption}
+	for _, p := range privs {
+		g.Privileges = append(g.Privileges, account.Privilege{Name: p})
+	}
+	return g
+}
+
+func TestBuild(t *testing.T) {
+	source := []account.Account{
+		{User: "app", Host: "%", Plugin: "caching_sha2_password", AuthString: "AA", Require: "SSL",
+			Grants:       []account.Grant{grant("*", false, "USAGE"), grant("shop", true, "SELECT", "INSERT")},
+			Roles:        []account.RoleGrant{{Role: "`reader`@`%`", AdminOption: true}},
+			DefaultRoles: []string{"`reader`@`%`"}},
+		{User: "reader", Host: "%", Plugin: "caching_sha2_password", Locked: true, PasswordExpire: "DEFAULT",
+			Grants: []account.Grant{grant("shop", false, "SELECT")}},
+	}
+	target := []account.Account{
+		{User: "app", Host: "%", Plugin: "caching_sha2_password", AuthString: "BB", Locked: true,
+			Grants: []account.Grant{grant("shop", false, "SELECT", "DELETE"), grant("logs", false, "SELECT")}},
+		{User: "stale", Host: "%"},
+	}
+
+	assert.Equal(t, []string{
+		"CREATE USER `reader`@`%` IDENTIFIED WITH 'caching_sha2_password' PASSWORD EXPIRE DEFAULT ACCOUNT LOCK",
+		"ALTER USER `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0xAA",
+		"ALTER USER `app`@`%` REQUIRE SSL",
+		"ALTER USER `app`@`%` ACCOUNT UNLOCK",
+		"REVOKE SELECT ON `logs`.* FROM `app`@`%`",
+		"REVOKE DELETE ON `shop`.* FROM `app`@`%`",
+		"GRANT INSERT ON `shop`.* TO `app`@`%` WITH GRANT OPTION",
+		"GRANT SELECT ON `shop`.* TO `reader`@`%`",
+		"GRANT `reader`@`%` TO `app`@`%` WITH ADMIN OPTION",
+		"ALTER USER `app`@`%` DEFAULT ROLE `reader`@`%`",
+		"DROP USER IF EXISTS `stale`@`%`",
+	}, Build(source, target, account.MySQL))
+}
+
+func TestBuild_MariaDB(t *testing.T) {
+	source := []account.Account{
+		{User: "app", Host: "%", Plugin: "mysql_native_password", AuthString: hex.EncodeToString([]byte("*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19")),
+			Roles:        []account.RoleGrant{{Role: "`reporter`@`%`"}},
+			DefaultRoles: []string{"`reporter`@`%`"}},
+		{User: "reporter", Host: "%", Role: true, Locked: true, Grants: []account.Grant{grant("shop", false, "SELECT")}},
+	}
+	target := []account.Account{
+		{User: "app", Host: "%", Plugin: "mysql_native_password", AuthString: hex.EncodeToString([]byte("*6BB4837EB74329105EE4568DDA7DC67ED2CA2AD9"))},
+		{User: "stale", Host: "%", Role: true, Locked: true, Grants: []account.Grant{grant("logs", false, "SELECT")}},
+	}
+
+	assert.Equal(t, []string{
+		"CREATE ROLE `reporter`",
+		"ALTER USER `app`@`%` IDENTIFIED VIA mysql_native_password USING '*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19'",
+		"GRANT SELECT ON `shop`.* TO `reporter`",
+		"GRANT `reporter` TO `app`@`%`",
+		"SET DEFAULT ROLE `reporter` FOR `app`@`%`",
+		"DROP ROLE IF EXISTS `stale`",
+	}, Build(source, target, account.MariaDB))
+}
+
+func TestBuild_Factors(t *testing.T) {
+	ldap := account.Factor{Plugin: "authentication_ldap_sasl", AuthString: "01"}
+	fido := account.Factor{Plugin: "authentication_fido"}
+	source := []account.Account{
+		{User: "one", Host: "%", Plugin: "caching_sha2_password"},
+		{User: "three", Host: "%", Plugin: "caching_sha2_password", Factors: []account.Factor{ldap, fido}},
+		{User: "two", Host: "%", Plugin: "caching_sha2_password", Factors: []account.Factor{fido}},
+		{User: "new", Host: "%", Plugin: "caching_sha2_password", Factors: []account.Factor{ldap}},
+	}
+	target := []account.Account{
+		{User: "one", Host: "%", Plugin: "caching_sha2_password", Factors: []account.Factor{ldap, fido}},
+		{User: "three", Host: "%", Plugin: "caching_sha2_password"},
+		{User: "two", Host: "%", Plugin: "caching_sha2_password", Factors: []account.Factor{ldap}},
+	}
+	assert.Equal(t, []string{
+		"CREATE USER `new`@`%` IDENTIFIED WITH 'caching_sha2_password' AND IDENTIFIED WITH 'authentication_ldap_sasl' AS 0x01 ACCOUNT UNLOCK",
+		"ALTER USER `one`@`%` DROP 2 FACTOR DROP 3 FACTOR",
+		"ALTER USER `three`@`%` ADD 2 FACTOR IDENTIFIED WITH 'authentication_ldap_sasl' AS 0x01 ADD 3 FACTOR IDENTIFIED WITH 'authentication_fido'",
+		"ALTER USER `two`@`%` MODIFY 2 FACTOR IDENTIFIED WITH 'authentication_fido'",
+	}, Build(source, target, account.MySQL))
+
+	// applying the plan to the target gives the source
+	script := ""
+	for _, a := range target {
+		script += a.CreateStatement() + ";\n"
+	}
+	for _, stmt := range Build(source, target, account.MySQL) {
+		script += stmt + ";\n"
+	}
+	replayed, err := account.ParseSQL(script)
+	assert.NoError(t, err)
+	assert.Equal(t, Fingerprint(source), Fingerprint(replayed))
+}
+
+func TestBuild_AccountOptions(t *testing.T) {
+	source := []account.Account{
+		{User: "app", Host: "%", Plugin: "caching_sha2_password", MaxQueriesPerHour: 100, PasswordHistory: "5",
+			PasswordReuseInterval: "30", FailedLoginAttempts: 3, PasswordLockTime: "2", Comment: "billing",
+			Attributes: map[string]any{"team": "core"}},
+		{User: "reset", Host: "%", Plugin: "caching_sha2_password"},
+	}
+	target := []account.Account{
+		{User: "app", Host: "%", Plugin: "caching_sha2_password", MaxUserConnections: 10,
+			PasswordRequireCurrent: "REQUIRED", Attributes: map[string]any{"owner": "ops"}},
+		{User: "reset", Host: "%", Plugin: "caching_sha2_password", MaxQueriesPerHour: 5, PasswordHistory: "3",
+			PasswordReuseInterval: "10", PasswordRequireCurrent: "OPTIONAL", FailedLoginAttempts: 4, PasswordLockTime: "UNBOUNDED",
+			Comment: "old"},
+	}
+	assert.Equal(t, []string{
+		"ALTER USER `app`@`%` WITH MAX_QUERIES_PER_HOUR 100 MAX_UPDATES_PER_HOUR 0 MAX_CONNECTIONS_PER_HOUR 0 MAX_USER_CONNECTIONS 0",
+		"ALTER USER `app`@`%` PASSWORD HISTORY 5",
+		"ALTER USER `app`@`%` PASSWORD REUSE INTERVAL 30 DAY",
+		"ALTER USER `app`@`%` PASSWORD REQUIRE CURRENT DEFAULT",
+		"ALTER USER `app`@`%` FAILED_LOGIN_ATTEMPTS 3",
+		"ALTER USER `app`@`%` PASSWORD_LOCK_TIME 2",
+		"ALTER USER `app`@`%` COMMENT 'billing'",
+		`ALTER USER ` + "`app`@`%`" + ` ATTRIBUTE '{"owner":null,"team":"core"}'`,
+		"ALTER USER `reset`@`%` WITH MAX_QUERIES_PER_HOUR 0 MAX_UPDATES_PER_HOUR 0 MAX_CONNECTIONS_PER_HOUR 0 MAX_USER_CONNECTIONS 0",
+		"ALTER USER `reset`@`%` PASSWORD HISTORY DEFAULT",
+		"ALTER USER `reset`@`%` PASSWORD REUSE INTERVAL DEFAULT",
+		"ALTER USER `reset`@`%` PASSWORD REQUIRE CURRENT DEFAULT",
+		"ALTER USER `reset`@`%` FAILED_LOGIN_ATTEMPTS 0",
+		"ALTER USER `reset`@`%` PASSWORD_LOCK_TIME 0",
+		"ALTER USER `reset`@`%` ATTRIBUTE '{\"comment\": null}'",
+	}, Build(source, target, account.MySQL))
+
+	// the options are part of the target state a plan is checked against
+	changed := append([]account.Account(nil), target...)
+	changed[0].FailedLoginAttempts = 5
+	assert.NotEqual(t, Fingerprint(target), Fingerprint(changed))
+}
+
+func TestBuild_NoChanges(t *testing.T) {
+	accounts := []account.Account{{User: "app", Host: "%", Grants: []account.Grant{grant("shop", false, "SELECT")}}}
+	assert.Empty(t, Build(accounts, accounts, account.MySQL))
+}
+
+func TestFingerprint(t *testing.T) {
+	a := []account.Account{
+		{User: "b", Host: "%", Grants: []account.Grant{grant("x", false, "SELECT"), grant("y", false, "INSERT")}},
+		{User: "a", Host: "%"},
+	}
+	reordered := []account.Account{
+		{User: "a", Host: "%"},
+		{User: "b", Host: "%", Grants: []account.Grant{grant("y", false, "INSERT"), grant("x", false, "SELECT")}},
+	}
+	assert.Equal(t, Fingerprint(a), Fingerprint(reordered))
+
+	changed := []account.Account{{User: "a", Host: "%"}, {User: "b", Host: "%", Grants: []account.Grant{grant("x", false, "SELECT")}}}
+	assert.NotEqual(t, Fingerprint(a), Fingerprint(changed))
+	assert.True(t, strings.HasPrefix(Fingerprint(a), "sha256:"))
+}
+
+func TestWriteRead(t *testing.T) {
+	p := &Plan{
+		Target:         "db2",
+		OnlyUser:       "app",
+		TargetChecksum: "sha256:abc",
+		Statements:     []string{"DROP USER `app`@`%`", "GRANT SELECT ON `shop`.* TO `app`@`10.%`"},
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, p.Write(&buf))
+	assert.True(t, strings.HasPrefix(buf.String(), "-- go-pass plan\n-- target: db2\n"))
+
+	read, err := Read(buf.String())
+	assert.NoError(t, err)
+	assert.Equal(t, p, read)
+
+	tampered := strings.Replace(buf.String(), "SELECT", "SELECT, DELETE", 1)
+	_, err = Read(tampered)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum mismatch")
+
+	// the header is covered by the checksum too
+	for _, edit := range [][2]string{
+		{"-- target: db2", "-- target: db3"},
+		{"-- only-user: app", "-- only-user: other"},
+		{"-- target-checksum: sha256:abc", "-- target-checksum: sha256:def"},
+	} {
+		_, err = Read(strings.Replace(buf.String(), edit[0], edit[1], 1))
+		assert.Error(t, err, edit[1])
+		assert.Contains(t, err.Error(), "checksum mismatch", edit[1])
+	}
+
+	// key lines after the header are not read
+	read, err = Read(buf.String() + "-- target-checksum: sha256:0000\n")
+	assert.NoError(t, err)
+	assert.Equal(t, p.TargetChecksum, read.TargetChecksum)
+
+	_, err = Read("GRANT SELECT ON *.* TO `x`@`%`;")
+	assert.Error(t, err)
+}
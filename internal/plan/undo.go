@@ -0,0 +1,131 @@
+package plan
+
+import (
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+)
+
+// AffectedAccounts returns the distinct accounts modified by statements, in
+// the order they first appear
+func AffectedAccounts(statements []string) []account.Name {
+	seen := make(map[string]bool)
+	var names []account.Name
+	for _, stmt := range statements {
+		for _, n := range account.StatementAccounts(stmt) {
+			if !seen[n.ID()] {
+				seen[n.ID()] = true
+				names = append(names, n)
+			}
+		}
+	}
+	return names
+}
+
+// Undo returns statements that restore the affected accounts of statements to
+// their state in snapshot, which holds the affected accounts that existed
+// before the statements run. Accounts missing from snapshot are dropped;
+// existing accounts are recreated if needed, get their previous hash, options
+// and default roles back and have their privileges and roles replaced by the
+// ones they had before. The statements are rendered in the dialect of the
+// server they run on.
+func Undo(statements []string, snapshot []account.Account, d account.Dialect) []string {
+	before := make(map[string]*account.Account, len(snapshot))
+	for i := range snapshot {
+		before[snapshot[i].ID()] = &snapshot[i]
+	}
+
+	// roles granted by the statements, which REVOKE ALL does not remove
+	newRoles := make(map[string][]string)
+	for _, stmt := range statements {
+		gs, err := account.ParseGrant(stmt)
+		if err != nil || gs.Revoke {
+			continue
+		}
+		for _, g := range gs.Grantees {
+			for _, r := range gs.Roles {
+				newRoles[g.ID()] = append(newRoles[g.ID()], r.Role)
+			}
+		}
+	}
+
+	after := replay(statements, snapshot)
+
+	var creates, alters, revokes, grants, roleGrants, defaults, drops []string
+	for _, n := range AffectedAccounts(statements) {
+		a, ok := before[n.ID()]
+		if !ok {
+			if t, ok := after[n.ID()]; ok {
+				drops = append(drops, d.DropStatement(t))
+			} else {
+				drops = append(drops, "DROP USER IF EXISTS "+n.ID())
+			}
+			continue
+		}
+		create := strings.Replace(d.CreateStatement(a), "CREATE USER ", "CREATE USER IF NOT EXISTS ", 1)
+		creates = append(creates, strings.Replace(create, "CREATE ROLE ", "CREATE ROLE IF NOT EXISTS ", 1))
+		if stmt := d.AlterStatement(a); stmt != "" {
+			alters = append(alters, stmt)
+		}
+		if t, ok := after[n.ID()]; ok {
+			alters = append(alters, factorStatements(a, t)...)
+		}
+
+		grantee := d.Grantee(a)
+		revokes = append(revokes, "REVOKE ALL PRIVILEGES, GRANT OPTION FROM "+grantee)
+		held := make(map[string]bool)
+		for _, r := range a.Roles {
+			held[r.Role] = true
+		}
+		for _, r := range newRoles[a.ID()] {
+			if !held[r] {
+				revokes = append(revokes, "REVOKE "+d.RoleName(r)+" FROM "+grantee)
+				held[r] = true
+			}
+		}
+
+		for _, g := range a.Grants {
+			if !usageOnly(g) {
+				grants = append(grants, g.Statement(grantee))
+			}
+		}
+		for _, g := range a.Revokes {
+			grants = append(grants, g.RevokeStatement(grantee))
+		}
+		for _, r := range a.Roles {
+			roleGrants = append(roleGrants, d.RoleGrantStatement(r, grantee))
+		}
+		if stmt := d.DefaultRoleStatement(a); stmt != "" {
+			defaults = append(defaults, stmt)
+		}
+	}
+
+	var stmts []string
+	for _, group := range [][]string{creates, alters, revokes, grants, roleGrants, defaults, drops} {
+		stmts = append(stmts, group...)
+	}
+	return stmts
+}
+
+// replay returns the state of the snapshot accounts after the statements
+// run. ALTER USER cannot reset the additional factors of an account, so
+// their undo depends on the factors the statements leave behind. Nothing is
+// returned when the statements cannot be replayed.
+func replay(statements []string, snapshot []account.Account) map[string]*account.Account {
+	var b strings.Builder
+	for _, a := range snapshot {
+		b.WriteString(a.CreateStatement() + ";\n")
+	}
+	for _, stmt := range statements {
+		b.WriteString(stmt + ";\n")
+	}
+	accounts, err := account.ParseSQL(b.String())
+	if err != nil {
+		return nil
+	}
+	after := make(map[string]*account.Account, len(accounts))
+	for i := range accounts {
+		after[accounts[i].ID()] = &accounts[i]
+	}
+	return after
+}
@@ -0,0 +1,117 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/stretchr/testify/assert"
+)
+
+var undoStatements = []string{
+	"CREATE USER IF NOT EXISTS `new`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0x24",
+	"ALTER USER `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0xBB",
+	"REVOKE SELECT ON `shop`.* FROM `app`@`%`",
+	"GRANT `writer`@`%`, `reader`@`%` TO `app`@`%`",
+	"DROP USER `old`@`localhost`",
+	"SHOW GRANTS FOR `other`@`%`",
+}
+
+func TestAffectedAccounts(t *testing.T) {
+	assert.Equal(t, []account.Name{
+		{User: "new", Host: "%"},
+		{User: "app", Host: "%"},
+		{User: "old", Host: "localhost"},
+	}, AffectedAccounts(undoStatements))
+}
+
+func TestUndo(t *testing.T) {
+	snapshot := []account.Account{
+		{User: "app", Host: "%", Plugin: "caching_sha2_password", AuthString: "AA", Require: "NONE", PasswordExpire: "DEFAULT",
+			Grants:       []account.Grant{grant("*", false, "USAGE"), grant("shop", false, "SELECT")},
+			Roles:        []account.RoleGrant{{Role: "`reader`@`%`"}},
+			DefaultRoles: []string{"`reader`@`%`"}},
+		{User: "old", Host: "localhost", Plugin: "mysql_native_password", AuthString: "CC", Locked: true},
+	}
+
+	assert.Equal(t, []string{
+		"CREATE USER IF NOT EXISTS `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0xAA REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK",
+		"CREATE USER IF NOT EXISTS `old`@`localhost` IDENTIFIED WITH 'mysql_native_password' AS 0xCC ACCOUNT LOCK",
+		"ALTER USER `app`@`%` IDENTIFIED WITH 'caching_sha2_password' AS 0xAA REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK",
+		"ALTER USER `old`@`localhost` IDENTIFIED WITH 'mysql_native_password' AS 0xCC ACCOUNT LOCK",
+		"REVOKE ALL PRIVILEGES, GRANT OPTION FROM `app`@`%`",
+		"REVOKE `writer`@`%` FROM `app`@`%`",
+		"REVOKE ALL PRIVILEGES, GRANT OPTION FROM `old`@`localhost`",
+		"GRANT SELECT ON `shop`.* TO `app`@`%`",
+		"GRANT `reader`@`%` TO `app`@`%`",
+		"ALTER USER `app`@`%` DEFAULT ROLE `reader`@`%`",
+		"ALTER USER `old`@`localhost` DEFAULT ROLE NONE",
+		"DROP USER IF EXISTS `new`@`%`",
+	}, Undo(undoStatements, snapshot, account.MySQL))
+}
+
+func TestUndo_ReplaysToSnapshot(t *testing.T) {
+	snapshot := []account.Account{
+		{User: "app", Host: "%", Plugin: "caching_sha2_password", AuthString: "AA", Require: "NONE", PasswordExpire: "DEFAULT",
+			Grants: []account.Grant{grant("shop", true, "SELECT")}},
+	}
+	// replaying the undo script on its own must describe the snapshot
+	var script string
+	for _, stmt := range Undo([]string{"GRANT INSERT ON `shop`.* TO `app`@`%`"}, snapshot, account.MySQL) {
+		script += stmt + ";\n"
+	}
+	replayed, err := account.ParseSQL(script)
+	assert.NoError(t, err)
+	assert.Equal(t, Fingerprint(snapshot), Fingerprint(replayed))
+}
+
+func TestUndo_Factors(t *testing.T) {
+	snapshot := []account.Account{
+		{User: "app", Host: "%", Plugin: "caching_sha2_password", AuthString: "AA",
+			Factors: []account.Factor{{Plugin: "authentication_ldap_sasl", AuthString: "01"}}},
+	}
+	statements := []string{
+		"ALTER USER `app`@`%` MODIFY 2 FACTOR IDENTIFIED WITH 'authentication_fido'",
+		"ALTER USER `app`@`%` ADD 3 FACTOR IDENTIFIED WITH 'authentication_ldap_sasl'",
+	}
+	undo := Undo(statements, snapshot, account.MySQL)
+	assert.Contains(t, undo, "ALTER USER `app`@`%` MODIFY 2 FACTOR IDENTIFIED WITH 'authentication_ldap_sasl' AS 0x01")
+	assert.Contains(t, undo, "ALTER USER `app`@`%` DROP 3 FACTOR")
+
+	var script string
+	for _, stmt := range append(statements, undo...) {
+		script += stmt + ";\n"
+	}
+	replayed, err := account.ParseSQL(snapshot[0].CreateStatement() + ";\n" + script)
+	assert.NoError(t, err)
+	assert.Equal(t, Fingerprint(snapshot), Fingerprint(replayed))
+}
+
+func TestUndo_MariaDB(t *testing.T) {
+	statements := []string{
+		"CREATE ROLE IF NOT EXISTS `app_write`",
+		"GRANT INSERT ON `shop`.* TO `app_write`",
+		"GRANT SELECT, INSERT ON `shop`.* TO `app_read`",
+		"GRANT `app_write` TO `app`@`%`",
+		"SET DEFAULT ROLE `app_write` FOR `app`@`%`",
+	}
+	snapshot := []account.Account{
+		{User: "app", Host: "%", Plugin: "ed25519", AuthString: "5A4967", Alternatives: []account.Factor{{Plugin: "unix_socket"}},
+			Roles:        []account.RoleGrant{{Role: "`app_read`@`%`"}},
+			DefaultRoles: []string{"`app_read`@`%`"}},
+		{User: "app_read", Host: "%", Role: true, Locked: true,
+			Grants: []account.Grant{grant("*", false, "USAGE"), grant("shop", false, "SELECT")}},
+	}
+
+	assert.Equal(t, []string{
+		"CREATE ROLE IF NOT EXISTS `app_read`",
+		"CREATE USER IF NOT EXISTS `app`@`%` IDENTIFIED VIA ed25519 USING 'ZIg' OR unix_socket ACCOUNT UNLOCK",
+		"ALTER USER `app`@`%` IDENTIFIED VIA ed25519 USING 'ZIg' OR unix_socket ACCOUNT UNLOCK",
+		"REVOKE ALL PRIVILEGES, GRANT OPTION FROM `app_read`",
+		"REVOKE ALL PRIVILEGES, GRANT OPTION FROM `app`@`%`",
+		"REVOKE `app_write` FROM `app`@`%`",
+		"GRANT SELECT ON `shop`.* TO `app_read`",
+		"GRANT `app_read` TO `app`@`%`",
+		"SET DEFAULT ROLE `app_read` FOR `app`@`%`",
+		"DROP ROLE IF EXISTS `app_write`",
+	}, Undo(statements, snapshot, account.MariaDB))
+}
@@ -0,0 +1,241 @@
+// Package policy evaluates accounts against team-specific rules loaded from a
+// YAML or JSON policy file
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/audit"
+	"github.com/ChaosHour/go-pass/internal/effective"
+	"gopkg.in/yaml.v3"
+)
+
+// AllEnvironments is the forbidden_privileges key that applies everywhere
+const AllEnvironments = "all"
+
+// Policy is a list of rules. A policy file looks like:
+//
+//	rules:
+//	  - id: app-hosts
+//	    description: Application users only connect from the app subnet
+//	    severity: high
+//	    user: "app_*"
+//	    allowed_hosts: ["10.20.%", "localhost"]
+//	    plugins: [caching_sha2_password]
+//	    require_tls: true
+//	    forbidden_privileges:
+//	      all: [SUPER, FILE]
+//	      production: [DROP]
+type Policy struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// Rule selects accounts by user and host pattern and lists the checks that
+// apply to them. Patterns use * and ? wildcards; a % in an account host is
+// matched literally.
+type Rule struct {
+	ID                  string              `yaml:"id"`
+	Description         string              `yaml:"description"`
+	Severity            audit.Severity      `yaml:"severity"`
+	User                string              `yaml:"user"`
+	Host                string              `yaml:"host"`
+	AllowedHosts        []string            `yaml:"allowed_hosts"`
+	Plugins             []string            `yaml:"plugins"`
+	RequireTLS          bool                `yaml:"require_tls"`
+	ForbiddenPrivileges map[string][]string `yaml:"forbidden_privileges"`
+
+	user, host *regexp.Regexp
+	allowed    []*regexp.Regexp
+}
+
+// Parse reads a YAML or JSON policy. Unknown keys are rejected so that typos
+// do not silently disable a check.
+func Parse(data []byte) (*Policy, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	p := &Policy{}
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for i, r := range p.Rules {
+		if r.ID == "" {
+			return nil, fmt.Errorf("rule %d has no id", i+1)
+		}
+		if seen[r.ID] {
+			return nil, fmt.Errorf("duplicate rule id %q", r.ID)
+		}
+		seen[r.ID] = true
+		if r.Severity == "" {
+			r.Severity = audit.Medium
+		}
+		if r.Severity.Rank() == 0 {
+			return nil, fmt.Errorf("rule %s: unknown severity %q", r.ID, r.Severity)
+		}
+		if len(r.AllowedHosts) == 0 && len(r.Plugins) == 0 && !r.RequireTLS && len(r.ForbiddenPrivileges) == 0 {
+			return nil, fmt.Errorf("rule %s has no checks", r.ID)
+		}
+		r.user, r.host = glob(r.User), glob(r.Host)
+		for _, h := range r.AllowedHosts {
+			r.allowed = append(r.allowed, glob(h))
+		}
+	}
+	return p, nil
+}
+
+// glob compiles a pattern with * and ? wildcards; an empty pattern matches
+// everything
+func glob(pattern string) *regexp.Regexp {
+	if pattern == "" {
+		pattern = "*"
+	}
+	re := strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(pattern))
+	return regexp.MustCompile("^" + re + "$")
+}
+
+// Evaluate checks the accounts against every rule. Forbidden privileges are
+// taken from the "all" entry and the entry for env, and are looked for in
+// the grants of the account and of every role granted to it.
+func (p *Policy) Evaluate(source, env string, accounts []account.Account) *audit.Report {
+	r := &audit.Report{Source: source, Findings: []audit.Finding{}}
+	roles := effective.NewResolver(accounts)
+	for _, rule := range p.Rules {
+		desc := rule.Description
+		if desc == "" {
+			desc = rule.ID
+		}
+		meta := audit.Rule{ID: rule.ID, Severity: rule.Severity, Description: desc}
+		r.Rules = append(r.Rules, meta)
+		for i := range accounts {
+			a := &accounts[i]
+			if !rule.user.MatchString(a.User) || !rule.host.MatchString(a.Host) {
+				continue
+			}
+			for _, msg := range rule.check(a, roles, env) {
+				r.Add(meta, a.ID(), msg)
+			}
+		}
+	}
+	r.Sort()
+	return r
+}
+
+// check returns one message per violation of the rule by a
+func (rule *Rule) check(a *account.Account, roles *effective.Resolver, env string) []string {
+	var msgs []string
+	if len(rule.allowed) > 0 && !matchesAny(rule.allowed, a.Host) {
+		msgs = append(msgs, fmt.Sprintf("host %s is not allowed (allowed: %s)", account.QuoteString(a.Host), quoteAll(rule.AllowedHosts)))
+	}
+	// the plugin is unknown for accounts parsed from files without IDENTIFIED WITH
+	if len(rule.Plugins) > 0 && a.Plugin != "" && !containsFold(rule.Plugins, a.Plugin) {
+		msgs = append(msgs, fmt.Sprintf("plugin %s is not allowed (allowed: %s)", a.Plugin, strings.Join(rule.Plugins, ", ")))
+	}
+	if rule.RequireTLS && (a.Require == "" || a.Require == "NONE") {
+		msgs = append(msgs, "REQUIRE NONE, TLS is required")
+	}
+
+	forbidden := privilegeNames(append(append([]string(nil), rule.ForbiddenPrivileges[AllEnvironments]...), rule.ForbiddenPrivileges[env]...))
+	if len(forbidden) == 0 {
+		return msgs
+	}
+	// a role that is not active by default can still be activated with SET ROLE
+	principals, _ := roles.Principals(a, true)
+	for _, p := range principals {
+		for _, g := range p.Account.Grants {
+			for _, priv := range forbidden {
+				if !grants(g, priv) {
+					continue
+				}
+				msg := fmt.Sprintf("%s on %s is forbidden", priv, g.Level())
+				if env != "" {
+					msg += " in " + env
+				}
+				var through []string
+				if priv != "ALL PRIVILEGES" && !slices.Contains(grantedNames(g), priv) {
+					through = append(through, "granted by ALL PRIVILEGES")
+				}
+				if p.Via != "" {
+					through = append(through, "through role "+p.Account.ID())
+				}
+				if len(through) > 0 {
+					msg += " (" + strings.Join(through, " ") + ")"
+				}
+				msgs = append(msgs, msg)
+			}
+		}
+	}
+	return msgs
+}
+
+// privilegeNames upper-cases, sorts and deduplicates privilege names, with
+// ALL spelled ALL PRIVILEGES as in SHOW GRANTS
+func privilegeNames(names []string) []string {
+	var out []string
+	for _, n := range names {
+		n = strings.ToUpper(strings.Join(strings.Fields(n), " "))
+		if n == "ALL" {
+			n = "ALL PRIVILEGES"
+		}
+		if !slices.Contains(out, n) {
+			out = append(out, n)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// grants reports whether a grant gives the privilege. A forbidden ALL
+// PRIVILEGES only matches a grant of ALL PRIVILEGES; any other privilege
+// also matches ALL PRIVILEGES at a level where it exists.
+func grants(g account.Grant, priv string) bool {
+	if priv == "ALL PRIVILEGES" {
+		return slices.Contains(grantedNames(g), priv)
+	}
+	return effective.Provides(g, priv)
+}
+
+// grantedNames returns the privilege names of a grant, including GRANT OPTION
+func grantedNames(g account.Grant) []string {
+	var names []string
+	for _, p := range g.Privileges {
+		names = append(names, p.Name)
+	}
+	if g.GrantOption {
+		names = append(names, "GRANT OPTION")
+	}
+	sort.Strings(names)
+	return names
+}
+
+func matchesAny(patterns []*regexp.Regexp, s string) bool {
+	for _, re := range patterns {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteAll(list []string) string {
+	quoted := make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = account.QuoteString(s)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,147 @@
+package policy
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+const policyYAML = `
+rules:
+  - id: app-hosts
+    description: Application users only connect from the app subnet
+    severity: high
+    user: "app_*"
+    allowed_hosts: ["10.20.*", "localhost"]
+  - id: modern-auth
+    plugins: [caching_sha2_password]
+    require_tls: true
+    host: "10.*"
+  - id: no-admin
+    severity: critical
+    forbidden_privileges:
+      all: [SUPER]
+      production: [DROP, GRANT OPTION]
+`
+
+const accountsSQL = `
+CREATE USER 'app_api'@'10.20.%' IDENTIFIED WITH 'caching_sha2_password' AS 0x24 REQUIRE SSL;
+GRANT SELECT, DROP ON app.* TO 'app_api'@'10.20.%';
+CREATE USER 'app_batch'@'%' IDENTIFIED WITH 'mysql_native_password' AS '*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19';
+CREATE USER 'legacy'@'10.1.%' IDENTIFIED WITH 'mysql_native_password' AS '*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19' REQUIRE NONE;
+GRANT SUPER ON *.* TO 'legacy'@'10.1.%' WITH GRANT OPTION;
+`
+
+func evaluate(t *testing.T, env string) [][]string {
+	t.Helper()
+	p, err := Parse([]byte(policyYAML))
+	assert.NoError(t, err)
+	accounts, err := account.ParseSQL(accountsSQL)
+	assert.NoError(t, err)
+
+	r := p.Evaluate("db1", env, accounts)
+	assert.Len(t, r.Rules, 3)
+	var got [][]string
+	for _, f := range r.Findings {
+		got = append(got, []string{string(f.Severity), f.Rule, f.Account, f.Message})
+	}
+	return got
+}
+
+func TestEvaluate(t *testing.T) {
+	assert.Equal(t, [][]string{
+		{"critical", "no-admin", "`legacy`@`10.1.%`", "SUPER on *.* is forbidden in staging"},
+		{"high", "app-hosts", "`app_batch`@`%`", "host '%' is not allowed (allowed: '10.20.*', 'localhost')"},
+		{"medium", "modern-auth", "`legacy`@`10.1.%`", "plugin mysql_native_password is not allowed (allowed: caching_sha2_password)"},
+		{"medium", "modern-auth", "`legacy`@`10.1.%`", "REQUIRE NONE, TLS is required"},
+	}, evaluate(t, "staging"))
+}
+
+func TestEvaluate_Environment(t *testing.T) {
+	got := evaluate(t, "production")
+	assert.Contains(t, got, []string{"critical", "no-admin", "`app_api`@`10.20.%`", "DROP on `app`.* is forbidden in production"})
+	assert.Contains(t, got, []string{"critical", "no-admin", "`legacy`@`10.1.%`", "GRANT OPTION on *.* is forbidden in production"})
+}
+
+func forbidden(t *testing.T, sql, privileges string) []string {
+	t.Helper()
+	p, err := Parse([]byte("rules: [{id: no-admin, forbidden_privileges: {all: [" + privileges + "]}}]"))
+	assert.NoError(t, err)
+	accounts, err := account.ParseSQL(sql)
+	assert.NoError(t, err)
+	var got []string
+	for _, f := range p.Evaluate("db1", "", accounts).Findings {
+		got = append(got, f.Account+" "+f.Message)
+	}
+	return got
+}
+
+func TestEvaluate_AllPrivileges(t *testing.T) {
+	got := forbidden(t, `
+CREATE USER 'dba'@'%';
+GRANT ALL PRIVILEGES ON *.* TO 'dba'@'%';
+CREATE USER 'owner'@'%';
+GRANT ALL PRIVILEGES ON app.* TO 'owner'@'%';
+`, "SUPER, drop, ALL")
+	assert.ElementsMatch(t, []string{
+		"`dba`@`%` ALL PRIVILEGES on *.* is forbidden",
+		"`dba`@`%` DROP on *.* is forbidden (granted by ALL PRIVILEGES)",
+		"`dba`@`%` SUPER on *.* is forbidden (granted by ALL PRIVILEGES)",
+		"`owner`@`%` ALL PRIVILEGES on `app`.* is forbidden",
+		"`owner`@`%` DROP on `app`.* is forbidden (granted by ALL PRIVILEGES)",
+	}, got)
+}
+
+func TestEvaluate_Roles(t *testing.T) {
+	got := forbidden(t, `
+CREATE ROLE 'admin';
+GRANT ALL PRIVILEGES ON *.* TO 'admin'@'%';
+CREATE ROLE 'deployer';
+GRANT DROP ON app.* TO 'deployer'@'%';
+GRANT 'admin' TO 'deployer'@'%';
+CREATE USER 'ci'@'10.%';
+GRANT 'deployer' TO 'ci'@'10.%';
+`, "SUPER, DROP")
+	assert.Contains(t, got, "`ci`@`10.%` DROP on `app`.* is forbidden (through role `deployer`@`%`)")
+	assert.Contains(t, got, "`ci`@`10.%` SUPER on *.* is forbidden (granted by ALL PRIVILEGES through role `admin`@`%`)")
+}
+
+func TestParse_Example(t *testing.T) {
+	data, err := os.ReadFile("../../examples/policy.yaml")
+	assert.NoError(t, err)
+	_, err = Parse(data)
+	assert.NoError(t, err)
+}
+
+func TestParse_JSON(t *testing.T) {
+	p, err := Parse([]byte(`{"rules": [{"id": "tls", "require_tls": true}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, audit.Medium, p.Rules[0].Severity)
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":  `rules: [{id: a, require_tsl: true}]`,
+		"missing id":   `rules: [{require_tls: true}]`,
+		"duplicate id": `rules: [{id: a, require_tls: true}, {id: a, require_tls: true}]`,
+		"severity":     `rules: [{id: a, severity: urgent, require_tls: true}]`,
+		"no checks":    `rules: [{id: a, user: app}]`,
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse([]byte(data))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGlob(t *testing.T) {
+	assert.True(t, glob("").MatchString("anything"))
+	assert.True(t, glob("app_*").MatchString("app_api"))
+	assert.False(t, glob("app_*").MatchString("application"))
+	assert.True(t, glob("10.?.%").MatchString("10.1.%"))
+	assert.False(t, glob("10.*").MatchString("%"))
+}
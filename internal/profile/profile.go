@@ -0,0 +1,295 @@
+// Package profile adjusts accounts for managed MySQL services, which restrict
+// privileges and reserve accounts of their own
+package profile
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed profiles/*.yaml
+var builtin embed.FS
+
+// Profile lists what a managed service does not accept. A profile file looks
+// like:
+//
+//	description: Amazon RDS for MySQL
+//	extends: rds
+//	remove_privileges: [SUPER, FILE]
+//	substitute_privileges:
+//	  ALL PRIVILEGES: [SELECT, INSERT, UPDATE, DELETE]
+//	skip_users: [rdsadmin, "mysql.*", "admin@localhost"]
+//
+// Substitutions apply to global grants, ALL PRIVILEGES on a schema is
+// accepted everywhere. Skip patterns match the user, or user@host when they
+// contain an @, and use * and ? wildcards.
+type Profile struct {
+	Name                 string              `yaml:"-"`
+	Description          string              `yaml:"description"`
+	Extends              string              `yaml:"extends"`
+	RemovePrivileges     []string            `yaml:"remove_privileges"`
+	SubstitutePrivileges map[string][]string `yaml:"substitute_privileges"`
+	SkipUsers            []string            `yaml:"skip_users"`
+
+	skip []*regexp.Regexp
+}
+
+// Adjustment is a change made to an account, or an account left out, so the
+// dump imports into the service
+type Adjustment struct {
+	Account string
+	Message string
+}
+
+// String returns the adjustment with the account it applies to
+func (a Adjustment) String() string {
+	return a.Account + ": " + a.Message
+}
+
+// Names returns the names of the profiles shipped with go-pass
+func Names() []string {
+	entries, _ := builtin.ReadDir("profiles")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+	}
+	return names
+}
+
+// Load returns the profile shipped with go-pass under name, or reads it from
+// a file when name is a path. A profile extends another by name or by a path
+// relative to its own file.
+func Load(name string) (*Profile, error) {
+	return load(name, "", nil)
+}
+
+func load(name, dir string, seen []string) (*Profile, error) {
+	data, source, err := read(name, dir)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(seen, source) {
+		return nil, fmt.Errorf("profile %s extends itself", name)
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", name, err)
+	}
+	p.Name = strings.TrimSuffix(path.Base(filepath.ToSlash(name)), filepath.Ext(name))
+	if p.Extends == "" {
+		return p, nil
+	}
+	parent, err := load(p.Extends, filepath.Dir(source), append(seen, source))
+	if err != nil {
+		return nil, err
+	}
+	return parent.merge(p), nil
+}
+
+// read returns the profile file and where it was found
+func read(name, dir string) ([]byte, string, error) {
+	if !isPath(name) {
+		data, err := builtin.ReadFile("profiles/" + name + ".yaml")
+		if err != nil {
+			return nil, "", fmt.Errorf("unknown profile %q (built-in profiles: %s)", name, strings.Join(Names(), ", "))
+		}
+		return data, "builtin:" + name, nil
+	}
+	if !filepath.IsAbs(name) && dir != "" {
+		name = filepath.Join(dir, name)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read profile: %w", err)
+	}
+	return data, name, nil
+}
+
+func isPath(name string) bool {
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator)
+}
+
+// Parse reads a YAML or JSON profile. Unknown keys are rejected so that typos
+// do not silently let a restricted privilege through.
+func Parse(data []byte) (*Profile, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	p := &Profile{}
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("failed to parse profile: %w", err)
+	}
+
+	for i, priv := range p.RemovePrivileges {
+		p.RemovePrivileges[i] = strings.ToUpper(priv)
+	}
+	subs := make(map[string][]string, len(p.SubstitutePrivileges))
+	for priv, with := range p.SubstitutePrivileges {
+		for i, w := range with {
+			with[i] = strings.ToUpper(w)
+		}
+		subs[strings.ToUpper(priv)] = with
+	}
+	p.SubstitutePrivileges = subs
+	p.compile()
+	return p, nil
+}
+
+func (p *Profile) compile() {
+	p.skip = p.skip[:0]
+	for _, s := range p.SkipUsers {
+		p.skip = append(p.skip, glob(s))
+	}
+}
+
+// merge returns the parent with the lists of child appended and its
+// substitutions taking precedence
+func (p *Profile) merge(child *Profile) *Profile {
+	m := &Profile{
+		Name:                 child.Name,
+		Description:          child.Description,
+		RemovePrivileges:     append(slices.Clone(p.RemovePrivileges), child.RemovePrivileges...),
+		SubstitutePrivileges: make(map[string][]string),
+		SkipUsers:            append(slices.Clone(p.SkipUsers), child.SkipUsers...),
+	}
+	if m.Description == "" {
+		m.Description = p.Description
+	}
+	for priv, with := range p.SubstitutePrivileges {
+		m.SubstitutePrivileges[priv] = with
+	}
+	for priv, with := range child.SubstitutePrivileges {
+		m.SubstitutePrivileges[priv] = with
+	}
+	m.compile()
+	return m
+}
+
+// glob compiles a pattern with * and ? wildcards
+func glob(pattern string) *regexp.Regexp {
+	re := strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(pattern))
+	return regexp.MustCompile("^" + re + "$")
+}
+
+// Skips reports whether the account is reserved by the service
+func (p *Profile) Skips(a *account.Account) bool {
+	for i, re := range p.skip {
+		subject := a.User
+		if strings.Contains(p.SkipUsers[i], "@") {
+			subject = a.User + "@" + a.Host
+		}
+		if re.MatchString(subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns copies of the accounts without the reserved accounts and
+// with restricted privileges removed or substituted, and an adjustment for
+// every change
+func (p *Profile) Apply(accounts []account.Account) ([]account.Account, []Adjustment) {
+	out := make([]account.Account, 0, len(accounts))
+	var adjustments []Adjustment
+	for _, a := range accounts {
+		if p.Skips(&a) {
+			adjustments = append(adjustments, Adjustment{Account: a.ID(), Message: fmt.Sprintf("reserved on %s, skipped", p.Name)})
+			continue
+		}
+		adj := &adjuster{profile: p, id: a.ID()}
+		var grants []account.Grant
+		for _, g := range a.Grants {
+			if g, ok := adj.grant(g); ok {
+				grants = append(grants, g)
+			}
+		}
+		a.Grants = grants
+		a.Revokes = adj.revokes(a.Revokes)
+		out = append(out, a)
+		adjustments = append(adjustments, adj.adjustments...)
+	}
+	return out, adjustments
+}
+
+type adjuster struct {
+	profile     *Profile
+	id          string
+	adjustments []Adjustment
+}
+
+func (adj *adjuster) add(format string, args ...any) {
+	adj.adjustments = append(adj.adjustments, Adjustment{Account: adj.id, Message: fmt.Sprintf(format, args...)})
+}
+
+// grant removes and substitutes the restricted privileges of a grant. It
+// returns false when nothing is left to grant.
+func (adj *adjuster) grant(g account.Grant) (account.Grant, bool) {
+	global := g.Schema == "*" && g.Object == "*" && g.ObjectType != "PROXY"
+	var privs []account.Privilege
+	var removed []string
+	for _, priv := range g.Privileges {
+		with, ok := adj.profile.SubstitutePrivileges[priv.Name]
+		switch {
+		case ok && global:
+			adj.add("%s on %s replaced with %s", priv.Name, g.Level(), strings.Join(with, ", "))
+			for _, w := range with {
+				if !slices.ContainsFunc(privs, func(p account.Privilege) bool { return p.Name == w && len(p.Columns) == 0 }) {
+					privs = append(privs, account.Privilege{Name: w})
+				}
+			}
+		case slices.Contains(adj.profile.RemovePrivileges, priv.Name):
+			removed = append(removed, priv.Name)
+		case len(priv.Columns) > 0 || !slices.ContainsFunc(privs, func(p account.Privilege) bool { return p.Name == priv.Name && len(p.Columns) == 0 }):
+			privs = append(privs, priv)
+		}
+	}
+	if len(removed) > 0 {
+		adj.add("%s on %s not allowed on %s, removed", strings.Join(removed, ", "), g.Level(), adj.profile.Name)
+	}
+	if len(privs) == 0 {
+		if !g.GrantOption {
+			return g, false
+		}
+		privs = []account.Privilege{{Name: "USAGE"}}
+	}
+	g.Privileges = privs
+	return g, true
+}
+
+// revokes drops partial revokes of removed privileges, which are not granted
+// anymore
+func (adj *adjuster) revokes(revokes []account.Grant) []account.Grant {
+	var out []account.Grant
+	for _, r := range revokes {
+		var privs []account.Privilege
+		var removed []string
+		for _, priv := range r.Privileges {
+			if slices.Contains(adj.profile.RemovePrivileges, priv.Name) {
+				removed = append(removed, priv.Name)
+				continue
+			}
+			privs = append(privs, priv)
+		}
+		if len(removed) > 0 {
+			adj.add("partial revoke of %s on %s removed with the privilege", strings.Join(removed, ", "), r.Level())
+		}
+		if len(privs) > 0 {
+			r.Privileges = privs
+			out = append(out, r)
+		}
+	}
+	return out
+}
@@ -0,0 +1,115 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func messages(adjustments []Adjustment) []string {
+	var out []string
+	for _, a := range adjustments {
+		out = append(out, a.String())
+	}
+	return out
+}
+
+func TestLoad_Builtin(t *testing.T) {
+	assert.Equal(t, []string{"aurora", "azure", "cloudsql", "rds"}, Names())
+	for _, name := range Names() {
+		p, err := Load(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, name, p.Name)
+		assert.NotEmpty(t, p.Description, name)
+		assert.Contains(t, p.RemovePrivileges, "SUPER", name)
+	}
+
+	// aurora extends rds
+	p, err := Load("aurora")
+	assert.NoError(t, err)
+	assert.Equal(t, "Amazon Aurora MySQL", p.Description)
+	assert.Contains(t, p.SkipUsers, "rdsadmin")
+	assert.Contains(t, p.SkipUsers, "rdsproxyadmin")
+	assert.Contains(t, p.SubstitutePrivileges, "ALL PRIVILEGES")
+
+	_, err = Load("heroku")
+	assert.EqualError(t, err, `unknown profile "heroku" (built-in profiles: aurora, azure, cloudsql, rds)`)
+}
+
+func TestLoad_File(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "base.yaml"), []byte("description: Base\nremove_privileges: [shutdown]\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "custom.yaml"), []byte("extends: base.yaml\nskip_users: [ops_*]\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "company-rds.yaml"), []byte("description: RDS with our service accounts\nextends: rds\nskip_users: [\"monitor@10.%\"]\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "loop.yaml"), []byte("extends: loop.yaml\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "typo.yaml"), []byte("remove_privilege: [SUPER]\n"), 0644))
+
+	p, err := Load(filepath.Join(dir, "custom.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, "custom", p.Name)
+	assert.Equal(t, "Base", p.Description)
+	assert.Equal(t, []string{"SHUTDOWN"}, p.RemovePrivileges)
+	assert.True(t, p.Skips(&account.Account{User: "ops_backup", Host: "%"}))
+
+	p, err = Load(filepath.Join(dir, "company-rds.yaml"))
+	assert.NoError(t, err)
+	assert.True(t, p.Skips(&account.Account{User: "rdsadmin", Host: "localhost"}))
+	assert.True(t, p.Skips(&account.Account{User: "monitor", Host: "10.%"}))
+	assert.False(t, p.Skips(&account.Account{User: "monitor", Host: "%"}))
+
+	_, err = Load(filepath.Join(dir, "loop.yaml"))
+	assert.ErrorContains(t, err, "extends itself")
+
+	_, err = Load(filepath.Join(dir, "typo.yaml"))
+	assert.ErrorContains(t, err, "field remove_privilege not found")
+
+	_, err = Load(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to read profile")
+}
+
+func TestApply_RDS(t *testing.T) {
+	accounts, err := account.ParseSQL("CREATE USER 'rdsadmin'@'localhost' IDENTIFIED WITH 'mysql_native_password' ACCOUNT LOCK;\n" +
+		"GRANT ALL PRIVILEGES ON *.* TO 'rdsadmin'@'localhost' WITH GRANT OPTION;\n" +
+		"CREATE USER 'mysql.sys'@'localhost' ACCOUNT LOCK;\n" +
+		"CREATE USER 'admin'@'%' IDENTIFIED WITH 'caching_sha2_password';\n" +
+		"GRANT ALL PRIVILEGES ON *.* TO 'admin'@'%' WITH GRANT OPTION;\n" +
+		"REVOKE FILE, INSERT ON `mysql`.* FROM 'admin'@'%';\n" +
+		"CREATE USER 'ops'@'10.%';\n" +
+		"GRANT PROCESS, SUPER, REPLICATION CLIENT ON *.* TO 'ops'@'10.%';\n" +
+		"GRANT FILE ON *.* TO 'ops'@'10.%';\n" +
+		"GRANT ALL PRIVILEGES ON `shop`.* TO 'ops'@'10.%';\n")
+	assert.NoError(t, err)
+
+	p, err := Load("rds")
+	assert.NoError(t, err)
+	out, adjustments := p.Apply(accounts)
+	assert.Equal(t, []string{
+		"`admin`@`%`: ALL PRIVILEGES on *.* replaced with " + strings.Join(p.SubstitutePrivileges["ALL PRIVILEGES"], ", "),
+		"`admin`@`%`: partial revoke of FILE on `mysql`.* removed with the privilege",
+		"`mysql.sys`@`localhost`: reserved on rds, skipped",
+		"`ops`@`10.%`: SUPER on *.* not allowed on rds, removed",
+		"`ops`@`10.%`: FILE on *.* not allowed on rds, removed",
+		"`rdsadmin`@`localhost`: reserved on rds, skipped",
+	}, messages(adjustments))
+
+	assert.Len(t, out, 2)
+	admin := out[0]
+	assert.Equal(t, "SELECT", admin.Grants[0].Privileges[0].Name)
+	assert.NotContains(t, admin.Grants[0].Privileges, account.Privilege{Name: "ALL PRIVILEGES"})
+	assert.True(t, admin.Grants[0].GrantOption)
+	assert.Equal(t, []account.Privilege{{Name: "INSERT"}}, admin.Revokes[0].Privileges)
+
+	ops := out[1]
+	assert.Len(t, ops.Grants, 2)
+	assert.Equal(t, []account.Privilege{{Name: "PROCESS"}, {Name: "REPLICATION CLIENT"}}, ops.Grants[0].Privileges)
+	// ALL PRIVILEGES on a schema is accepted
+	assert.Equal(t, []account.Privilege{{Name: "ALL PRIVILEGES"}}, ops.Grants[1].Privileges)
+
+	// the input is left alone
+	assert.Len(t, accounts, 4)
+	assert.Contains(t, accounts[2].Grants[0].Privileges, account.Privilege{Name: "SUPER"})
+}
@@ -0,0 +1,146 @@
+package sqlsplit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Split splits SQL text into statements the way the mysql client does.
+// Single, double and backtick quotes (with backslash and doubled-quote
+// escapes) are honoured, --, # and /* */ comments are removed, and DELIMITER
+// directives change the statement terminator. Executable /*! */ comments and
+// /*+ */ optimizer hints are kept. Statements are returned trimmed and
+// without their terminator; empty statements are dropped.
+func Split(data string) ([]string, error) {
+	var statements []string
+	var cur strings.Builder
+	started := false // cur holds more than whitespace
+	delimiter := ";"
+
+	flush := func() {
+		if stmt := strings.TrimSpace(cur.String()); stmt != "" {
+			statements = append(statements, stmt)
+		}
+		cur.Reset()
+		started = false
+	}
+
+	i := 0
+	for i < len(data) {
+		c := data[i]
+
+		if !started && !isSpace(c) {
+			if d, n, ok := delimiterDirective(data[i:]); ok {
+				if d == "" {
+					return nil, fmt.Errorf("DELIMITER without a delimiter at offset %d", i)
+				}
+				delimiter = d
+				cur.Reset()
+				i += n
+				continue
+			}
+		}
+
+		switch {
+		case strings.HasPrefix(data[i:], delimiter):
+			flush()
+			i += len(delimiter)
+		case c == '\'' || c == '"' || c == '`':
+			n, err := quotedLength(data[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%w at offset %d", err, i)
+			}
+			cur.WriteString(data[i : i+n])
+			started = true
+			i += n
+		case c == '#' || isDashComment(data[i:]):
+			start := i
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if endsWithDash(&cur) {
+				cur.WriteString(data[start:i])
+			}
+		case strings.HasPrefix(data[i:], "/*"):
+			end := strings.Index(data[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment at offset %d", i)
+			}
+			n := end + 4
+			switch {
+			case strings.HasPrefix(data[i:], "/*!") || strings.HasPrefix(data[i:], "/*+"):
+				cur.WriteString(data[i : i+n])
+				started = true
+			case endsWithDash(&cur):
+				cur.WriteString(data[i : i+n])
+			default:
+				// a comment separates tokens like whitespace does
+				cur.WriteByte(' ')
+			}
+			i += n
+		default:
+			cur.WriteByte(c)
+			started = started || !isSpace(c)
+			i++
+		}
+	}
+	flush()
+	return statements, nil
+}
+
+// delimiterDirective recognises a DELIMITER line at the start of s and returns
+// the new delimiter and the length of the line
+func delimiterDirective(s string) (string, int, bool) {
+	if len(s) < 10 || !strings.EqualFold(s[:9], "DELIMITER") || (s[9] != ' ' && s[9] != '\t') {
+		return "", 0, false
+	}
+	line := s
+	if nl := strings.IndexByte(line, '\n'); nl >= 0 {
+		line = line[:nl]
+	}
+	fields := strings.Fields(line[9:])
+	if len(fields) == 0 {
+		return "", 0, true
+	}
+	return fields[0], len(line), true
+}
+
+// endsWithDash reports whether the statement so far ends in '-'. Comments are
+// kept after a dash since replacing them with whitespace could turn the dash
+// and what follows into a -- comment.
+func endsWithDash(b *strings.Builder) bool {
+	s := b.String()
+	return len(s) > 0 && s[len(s)-1] == '-'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// quotedLength returns the length of the quoted string or identifier at the
+// start of s, including its quotes
+func quotedLength(s string) (int, error) {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote != '`':
+			i++
+		case s[i] == quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated %c quote", quote)
+}
+
+// isDashComment reports whether s starts with a -- comment, which MySQL only
+// recognises when followed by whitespace
+func isDashComment(s string) bool {
+	if !strings.HasPrefix(s, "--") {
+		return false
+	}
+	return len(s) > 2 && isSpace(s[2])
+}
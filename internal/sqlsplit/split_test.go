@@ -0,0 +1,124 @@
+package sqlsplit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "simple",
+			data: "SELECT 1; SELECT 2;\n",
+			want: []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name: "no trailing delimiter",
+			data: "SELECT 1;\nSELECT 2",
+			want: []string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			name: "semicolons in quotes",
+			data: "CREATE USER 'a;b'@'%' IDENTIFIED WITH 'mysql_native_password' AS '*AB;CD' COMMENT \"x;y\";\nGRANT SELECT ON `we;ird`.* TO 'a;b'@'%';",
+			want: []string{
+				"CREATE USER 'a;b'@'%' IDENTIFIED WITH 'mysql_native_password' AS '*AB;CD' COMMENT \"x;y\"",
+				"GRANT SELECT ON `we;ird`.* TO 'a;b'@'%'",
+			},
+		},
+		{
+			name: "escaped and doubled quotes",
+			data: `SELECT 'it\'s;', 'it''s;', "say \"hi;\"", ` + "`a``;b`" + `; SELECT 3`,
+			want: []string{`SELECT 'it\'s;', 'it''s;', "say \"hi;\"", ` + "`a``;b`", "SELECT 3"},
+		},
+		{
+			name: "comments",
+			data: "-- CREATE USER IF NOT EXISTS for app@%: \nCREATE USER `app`@`%`; # trailing ; comment\n/* block ; comment */ GRANT SELECT ON *.* TO `app`@`%`;\n--\n-- only comments;\n",
+			want: []string{"CREATE USER `app`@`%`", "GRANT SELECT ON *.* TO `app`@`%`"},
+		},
+		{
+			name: "double dash without space is not a comment",
+			data: "SELECT 1--1; SELECT 2",
+			want: []string{"SELECT 1--1", "SELECT 2"},
+		},
+		{
+			name: "executable comments and hints are kept",
+			data: "/*!80000 SET @x = 1 */; SELECT /*+ MAX_EXECUTION_TIME(1) */ 1;",
+			want: []string{"/*!80000 SET @x = 1 */", "SELECT /*+ MAX_EXECUTION_TIME(1) */ 1"},
+		},
+		{
+			name: "delimiter",
+			data: "DELIMITER $$\nCREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END$$\ndelimiter ;\nSELECT 3;",
+			want: []string{"CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END", "SELECT 3"},
+		},
+		{
+			name: "delimiter word inside a statement",
+			data: "SELECT delimiter FROM t; SELECT 'DELIMITER $$';",
+			want: []string{"SELECT delimiter FROM t", "SELECT 'DELIMITER $$'"},
+		},
+		{
+			name: "empty",
+			data: " ;\n; -- nothing\n",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Split(tt.data)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSplit_Errors(t *testing.T) {
+	for _, data := range []string{
+		"SELECT 'unterminated;",
+		"SELECT `unterminated",
+		"SELECT 'ends in escape\\",
+		"SELECT 1 /* unterminated",
+		"DELIMITER \nSELECT 1",
+	} {
+		_, err := Split(data)
+		assert.Error(t, err, data)
+	}
+}
+
+func FuzzSplit(f *testing.F) {
+	f.Add("SELECT 1; SELECT 2")
+	f.Add("CREATE USER 'a;b'@'%' IDENTIFIED WITH 'x' AS '\\';';")
+	f.Add("-- c\n# d\n/* e; */ GRANT SELECT ON `x;`.* TO u; /*!1 ; */")
+	f.Add("DELIMITER //\nSELECT 1; SELECT 2//\nDELIMITER ;\nSELECT 3;")
+	f.Add("SELECT \"a\"\"b;\", 'c''d;'--\n")
+
+	f.Fuzz(func(t *testing.T, data string) {
+		stmts, err := Split(data)
+		if err != nil {
+			return
+		}
+		for _, s := range stmts {
+			if s == "" || s != strings.TrimSpace(s) {
+				t.Fatalf("statement %q is empty or not trimmed", s)
+			}
+		}
+
+		// without DELIMITER directives every statement ends at a top-level
+		// semicolon, so joining and splitting again must be lossless
+		if strings.Contains(strings.ToUpper(data), "DELIMITER") {
+			return
+		}
+		again, err := Split(strings.Join(stmts, ";\n"))
+		if err != nil {
+			t.Fatalf("re-splitting %q: %v", stmts, err)
+		}
+		if strings.Join(again, "\x00") != strings.Join(stmts, "\x00") {
+			t.Fatalf("re-splitting %q gave %q", stmts, again)
+		}
+	})
+}
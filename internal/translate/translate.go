@@ -0,0 +1,317 @@
+// Package translate rewrites structured accounts for the MySQL version a dump
+// is imported into
+package translate
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+)
+
+// Version is the MySQL version accounts are translated for
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	// Series is set when no patch release was given; 8.0 means the latest
+	// 8.0 release
+	Series bool
+}
+
+// ParseVersion parses a target version such as 5.7, 8.0.36 or 8.4
+func ParseVersion(s string) (Version, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid target version %q: expected major.minor[.patch]", s)
+	}
+	nums := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid target version %q: expected major.minor[.patch]", s)
+		}
+		nums[i] = n
+	}
+	v := Version{Major: nums[0], Minor: nums[1], Patch: nums[2], Series: len(parts) == 2}
+	if !v.AtLeast(5, 7, 0) {
+		return Version{}, fmt.Errorf("unsupported target version %s: the oldest supported target is 5.7", v)
+	}
+	return v, nil
+}
+
+// AtLeast reports whether the target is at least major.minor.patch
+func (v Version) AtLeast(major, minor, patch int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	if v.Minor != minor {
+		return v.Minor > minor
+	}
+	return v.Series || v.Patch >= patch
+}
+
+// String returns the version as it was given
+func (v Version) String() string {
+	if v.Series {
+		return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Warning is a change to an account that alters what it can do, or
+// something that could not be translated
+type Warning struct {
+	Account string
+	Message string
+}
+
+// String returns the warning with the account it applies to
+func (w Warning) String() string {
+	return w.Account + ": " + w.Message
+}
+
+// superPrivileges are the dynamic privileges MySQL 8.0 grants to accounts
+// with SUPER when upgrading from 5.7
+var superPrivileges = []string{
+	"BINLOG_ADMIN", "BINLOG_ENCRYPTION_ADMIN", "CONNECTION_ADMIN", "ENCRYPTION_KEY_ADMIN",
+	"GROUP_REPLICATION_ADMIN", "PERSIST_RO_VARIABLES_ADMIN", "REPLICATION_SLAVE_ADMIN",
+	"RESOURCE_GROUP_ADMIN", "ROLE_ADMIN", "SESSION_VARIABLES_ADMIN", "SET_USER_ID",
+	"SYSTEM_VARIABLES_ADMIN", "XA_RECOVER_ADMIN",
+}
+
+// setUserIDPrivileges replace SET_USER_ID, which is deprecated as of 8.2
+var setUserIDPrivileges = []string{"SET_ANY_DEFINER", "ALLOW_NONEXISTENT_DEFINER"}
+
+// static57 are the privileges MySQL 5.7 knows; anything else is a dynamic
+// privilege or was added in 8.0
+var static57 = map[string]bool{
+	"ALL PRIVILEGES": true, "ALTER": true, "ALTER ROUTINE": true, "CREATE": true, "CREATE ROUTINE": true,
+	"CREATE TABLESPACE": true, "CREATE TEMPORARY TABLES": true, "CREATE USER": true, "CREATE VIEW": true,
+	"DELETE": true, "DROP": true, "EVENT": true, "EXECUTE": true, "FILE": true, "INDEX": true,
+	"INSERT": true, "LOCK TABLES": true, "PROCESS": true, "PROXY": true, "REFERENCES": true,
+	"RELOAD": true, "REPLICATION CLIENT": true, "REPLICATION SLAVE": true, "SELECT": true,
+	"SHOW DATABASES": true, "SHOW VIEW": true, "SHUTDOWN": true, "SUPER": true, "TRIGGER": true,
+	"UPDATE": true, "USAGE": true,
+}
+
+// foreignPlugins are MariaDB authentication plugins, mapped to the MySQL
+// plugin that works the same way or to "" when there is none
+var foreignPlugins = map[string]string{
+	"unix_socket": "auth_socket",
+	"ed25519":     "",
+	"gssapi":      "",
+	"parsec":      "",
+}
+
+// Accounts returns copies of the accounts rewritten to what the target
+// accepts, and a warning for every change that alters what an account can
+// do or that could not be translated
+func Accounts(accounts []account.Account, target Version) ([]account.Account, []Warning) {
+	out := make([]account.Account, 0, len(accounts))
+	var warnings []Warning
+	for _, a := range accounts {
+		t := &translator{target: target, id: a.ID()}
+		out = append(out, t.account(a))
+		warnings = append(warnings, t.warnings...)
+	}
+	return out, warnings
+}
+
+type translator struct {
+	target   Version
+	id       string
+	warnings []Warning
+}
+
+func (t *translator) warn(format string, args ...any) {
+	t.warnings = append(t.warnings, Warning{Account: t.id, Message: fmt.Sprintf(format, args...)})
+}
+
+func (t *translator) account(a account.Account) account.Account {
+	t.authentication(&a)
+	t.options(&a)
+
+	var grants []account.Grant
+	for _, g := range a.Grants {
+		if g, ok := t.grant(g); ok {
+			grants = append(grants, g)
+		}
+	}
+	a.Grants = grants
+
+	if !t.target.AtLeast(8, 0, 0) {
+		if len(a.Roles) > 0 || len(a.DefaultRoles) > 0 {
+			t.warn("roles are not supported before 8.0, dropped %s", roleList(a.Roles))
+			a.Roles, a.DefaultRoles = nil, nil
+		}
+	}
+	if len(a.Revokes) > 0 && !t.target.AtLeast(8, 0, 16) {
+		levels := make([]string, len(a.Revokes))
+		for i, r := range a.Revokes {
+			levels[i] = r.Level()
+		}
+		t.warn("partial revokes are not supported before 8.0.16, the global privileges also cover %s", strings.Join(levels, ", "))
+		a.Revokes = nil
+	}
+	return a
+}
+
+// authentication rewrites plugins the target does not have. Accounts whose
+// password cannot be carried over are locked and need a new password.
+func (t *translator) authentication(a *account.Account) {
+	if len(a.Alternatives) > 0 {
+		t.warn("alternative authentication (IDENTIFIED VIA ... OR) is MariaDB only, dropped %s", pluginList(a.Alternatives))
+		a.Alternatives = nil
+	}
+	if len(a.Factors) > 0 && !t.target.AtLeast(8, 0, 27) {
+		t.warn("multi-factor authentication is not supported before 8.0.27, dropped %s", pluginList(a.Factors))
+		a.Factors = nil
+	}
+
+	if plugin, ok := foreignPlugins[a.Plugin]; ok && plugin != "" {
+		t.warn("%s replaced with %s", a.Plugin, plugin)
+		a.Plugin, a.AuthString = plugin, ""
+	}
+	if reason := t.unsupportedPlugin(a); reason != "" {
+		t.warn("%s; the account is locked without a password and needs a new one", reason)
+		a.Plugin, a.AuthString = "", ""
+		a.Locked = true
+		return
+	}
+	if a.Plugin == "mysql_native_password" && t.target.AtLeast(8, 4, 0) {
+		t.warn("mysql_native_password is disabled by default as of 8.4; enable it with --mysql-native-password=ON or use go-pass migrate-plugin")
+	}
+}
+
+// unsupportedPlugin explains why the target cannot use the authentication
+// of the account, or returns an empty string
+func (t *translator) unsupportedPlugin(a *account.Account) string {
+	switch {
+	case a.Plugin == "":
+		return ""
+	case a.Plugin == "mysql_old_password", a.Plugin == "mysql_native_password" && len(a.AuthBytes()) == 16:
+		return "pre-4.1 password hashes are not supported"
+	case a.Plugin == "caching_sha2_password" && !t.target.AtLeast(8, 0, 0):
+		return "caching_sha2_password is not supported before 8.0"
+	case a.Plugin == "mysql_native_password" && t.target.AtLeast(9, 0, 0):
+		return "mysql_native_password was removed in 9.0"
+	}
+	if _, ok := foreignPlugins[a.Plugin]; ok {
+		return fmt.Sprintf("the MariaDB plugin %s has no MySQL equivalent", a.Plugin)
+	}
+	return ""
+}
+
+// options drops account options the target does not have. Options left at
+// their default are dropped without a warning.
+func (t *translator) options(a *account.Account) {
+	if a.MaxStatementTime != "" {
+		t.warn("MAX_STATEMENT_TIME is MariaDB only, dropped")
+		a.MaxStatementTime = ""
+	}
+	if !t.target.AtLeast(8, 0, 3) {
+		if nonDefault(a.PasswordHistory) || nonDefault(a.PasswordReuseInterval) {
+			t.warn("PASSWORD HISTORY and PASSWORD REUSE INTERVAL are not supported before 8.0.3, dropped")
+		}
+		a.PasswordHistory, a.PasswordReuseInterval = "", ""
+	}
+	if !t.target.AtLeast(8, 0, 13) {
+		if nonDefault(a.PasswordRequireCurrent) {
+			t.warn("PASSWORD REQUIRE CURRENT is not supported before 8.0.13, dropped")
+		}
+		a.PasswordRequireCurrent = ""
+	}
+	if !t.target.AtLeast(8, 0, 19) {
+		if a.FailedLoginAttempts != 0 || (nonDefault(a.PasswordLockTime) && a.PasswordLockTime != "0") {
+			t.warn("FAILED_LOGIN_ATTEMPTS and PASSWORD_LOCK_TIME are not supported before 8.0.19, dropped")
+		}
+		a.FailedLoginAttempts, a.PasswordLockTime = 0, ""
+	}
+	if !t.target.AtLeast(8, 0, 21) && (a.Comment != "" || len(a.Attributes) > 0) {
+		t.warn("COMMENT and ATTRIBUTE are not supported before 8.0.21, dropped")
+		a.Comment, a.Attributes = "", nil
+	}
+}
+
+// grant rewrites the privileges of a grant. It returns false when nothing
+// is left to grant.
+func (t *translator) grant(g account.Grant) (account.Grant, bool) {
+	var privs []account.Privilege
+	add := func(names ...string) {
+		for _, n := range names {
+			if !slices.ContainsFunc(privs, func(p account.Privilege) bool { return p.Name == n && len(p.Columns) == 0 }) {
+				privs = append(privs, account.Privilege{Name: n})
+			}
+		}
+	}
+	var dropped []string
+	for _, p := range g.Privileges {
+		switch {
+		case p.Name == "SUPER" && t.target.AtLeast(8, 0, 0):
+			replacement := t.superReplacement()
+			t.warn("SUPER on %s replaced with %s", g.Level(), strings.Join(replacement, ", "))
+			add(replacement...)
+		case p.Name == "SET_USER_ID" && t.target.AtLeast(8, 2, 0):
+			t.warn("SET_USER_ID on %s replaced with %s", g.Level(), strings.Join(setUserIDPrivileges, ", "))
+			add(setUserIDPrivileges...)
+		case !static57[p.Name] && !t.target.AtLeast(8, 0, 0):
+			dropped = append(dropped, p.Name)
+		case len(p.Columns) > 0:
+			privs = append(privs, p)
+		default:
+			add(p.Name)
+		}
+	}
+	if len(dropped) > 0 {
+		t.warn("%s on %s not supported before 8.0, dropped", strings.Join(dropped, ", "), g.Level())
+	}
+	if len(privs) == 0 {
+		if !g.GrantOption {
+			return g, false
+		}
+		privs = []account.Privilege{{Name: "USAGE"}}
+	}
+	g.Privileges = privs
+	return g, true
+}
+
+func (t *translator) superReplacement() []string {
+	if !t.target.AtLeast(8, 2, 0) {
+		return superPrivileges
+	}
+	var privs []string
+	for _, p := range superPrivileges {
+		if p == "SET_USER_ID" {
+			privs = append(privs, setUserIDPrivileges...)
+			continue
+		}
+		privs = append(privs, p)
+	}
+	return privs
+}
+
+func nonDefault(option string) bool {
+	return option != "" && option != "DEFAULT"
+}
+
+func pluginList(factors []account.Factor) string {
+	plugins := make([]string, len(factors))
+	for i, f := range factors {
+		plugins[i] = f.Plugin
+	}
+	return strings.Join(plugins, ", ")
+}
+
+func roleList(roles []account.RoleGrant) string {
+	if len(roles) == 0 {
+		return "the default roles"
+	}
+	names := make([]string, len(roles))
+	for i, r := range roles {
+		names[i] = r.Role
+	}
+	return strings.Join(names, ", ")
+}
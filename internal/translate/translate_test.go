@@ -0,0 +1,143 @@
+package translate
+
+import (
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/stretchr/testify/assert"
+)
+
+func version(t *testing.T, s string) Version {
+	v, err := ParseVersion(s)
+	assert.NoError(t, err)
+	return v
+}
+
+func messages(warnings []Warning) []string {
+	var out []string
+	for _, w := range warnings {
+		out = append(out, w.String())
+	}
+	return out
+}
+
+func TestParseVersion(t *testing.T) {
+	v := version(t, "8.0")
+	assert.True(t, v.Series)
+	assert.True(t, v.AtLeast(8, 0, 27))
+	assert.False(t, v.AtLeast(8, 1, 0))
+	assert.Equal(t, "8.0", v.String())
+
+	v = version(t, "8.0.20")
+	assert.True(t, v.AtLeast(8, 0, 19))
+	assert.False(t, v.AtLeast(8, 0, 21))
+	assert.Equal(t, "8.0.20", v.String())
+
+	for _, s := range []string{"8", "8.x", "8.0.1.2", "5.6", ""} {
+		_, err := ParseVersion(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestAccounts_57To80(t *testing.T) {
+	// as read from a 5.7 server
+	accounts, err := account.ParseSQL("CREATE USER 'admin'@'%' IDENTIFIED WITH 'mysql_native_password' AS '*6BB4837EB74329105EE4568DDA7DC67ED2CA2AD9' REQUIRE NONE PASSWORD EXPIRE DEFAULT ACCOUNT UNLOCK;\n" +
+		"GRANT SELECT, RELOAD, PROCESS, SUPER, REPLICATION CLIENT ON *.* TO 'admin'@'%' WITH GRANT OPTION;\n")
+	assert.NoError(t, err)
+
+	out, warnings := Accounts(accounts, version(t, "8.0"))
+	assert.Equal(t, []string{
+		"`admin`@`%`: SUPER on *.* replaced with BINLOG_ADMIN, BINLOG_ENCRYPTION_ADMIN, CONNECTION_ADMIN, ENCRYPTION_KEY_ADMIN, GROUP_REPLICATION_ADMIN, PERSIST_RO_VARIABLES_ADMIN, REPLICATION_SLAVE_ADMIN, RESOURCE_GROUP_ADMIN, ROLE_ADMIN, SESSION_VARIABLES_ADMIN, SET_USER_ID, SYSTEM_VARIABLES_ADMIN, XA_RECOVER_ADMIN",
+	}, messages(warnings))
+	g := out[0].Grants[0]
+	assert.Equal(t, "SELECT", g.Privileges[0].Name)
+	assert.NotContains(t, g.Privileges, account.Privilege{Name: "SUPER"})
+	assert.Contains(t, g.Privileges, account.Privilege{Name: "SET_USER_ID"})
+	assert.True(t, g.GrantOption)
+
+	// the input is left alone
+	assert.Contains(t, accounts[0].Grants[0].Privileges, account.Privilege{Name: "SUPER"})
+}
+
+func TestAccounts_80To84(t *testing.T) {
+	accounts := []account.Account{
+		{User: "app", Host: "%", Plugin: "mysql_native_password", AuthString: "2A36",
+			Grants: []account.Grant{{Schema: "*", Object: "*", Privileges: []account.Privilege{{Name: "SUPER"}, {Name: "SET_USER_ID"}}}}},
+		{User: "web", Host: "%", Plugin: "caching_sha2_password", AuthString: "24"},
+	}
+
+	out, warnings := Accounts(accounts, version(t, "8.4"))
+	msgs := messages(warnings)
+	assert.Len(t, msgs, 3)
+	assert.Contains(t, msgs[0], "mysql_native_password is disabled by default as of 8.4")
+	assert.Contains(t, msgs[1], "SUPER on *.* replaced with")
+	assert.Equal(t, "`app`@`%`: SET_USER_ID on *.* replaced with SET_ANY_DEFINER, ALLOW_NONEXISTENT_DEFINER", msgs[2])
+
+	var names []string
+	for _, p := range out[0].Grants[0].Privileges {
+		names = append(names, p.Name)
+	}
+	assert.NotContains(t, names, "SET_USER_ID")
+	assert.Contains(t, names, "SET_ANY_DEFINER")
+	// SET_ANY_DEFINER is granted once even though two privileges map to it
+	assert.Len(t, names, len(superPrivileges)+1)
+	assert.Equal(t, accounts[1], out[1])
+
+	// mysql_native_password is gone in 9.0
+	out, warnings = Accounts(accounts[:1], version(t, "9.0"))
+	assert.Contains(t, warnings[0].Message, "mysql_native_password was removed in 9.0")
+	assert.True(t, out[0].Locked)
+	assert.Empty(t, out[0].Plugin)
+}
+
+func TestAccounts_80To57(t *testing.T) {
+	accounts := []account.Account{{
+		User: "app", Host: "%", Plugin: "caching_sha2_password", AuthString: "24",
+		PasswordHistory: "DEFAULT", PasswordReuseInterval: "DEFAULT", PasswordRequireCurrent: "DEFAULT",
+		FailedLoginAttempts: 3, PasswordLockTime: "1", Comment: "orders",
+		Factors:      []account.Factor{{Plugin: "authentication_fido"}},
+		Grants:       []account.Grant{{Schema: "*", Object: "*", Privileges: []account.Privilege{{Name: "SELECT"}, {Name: "BACKUP_ADMIN"}}}, {Schema: "*", Object: "*", Privileges: []account.Privilege{{Name: "ROLE_ADMIN"}}}},
+		Revokes:      []account.Grant{{Schema: "mysql", Object: "*", Privileges: []account.Privilege{{Name: "SELECT"}}}},
+		Roles:        []account.RoleGrant{{Role: "`reader`@`%`"}},
+		DefaultRoles: []string{"`reader`@`%`"},
+	}}
+
+	out, warnings := Accounts(accounts, version(t, "5.7"))
+	assert.Equal(t, []string{
+		"`app`@`%`: multi-factor authentication is not supported before 8.0.27, dropped authentication_fido",
+		"`app`@`%`: caching_sha2_password is not supported before 8.0; the account is locked without a password and needs a new one",
+		"`app`@`%`: FAILED_LOGIN_ATTEMPTS and PASSWORD_LOCK_TIME are not supported before 8.0.19, dropped",
+		"`app`@`%`: COMMENT and ATTRIBUTE are not supported before 8.0.21, dropped",
+		"`app`@`%`: BACKUP_ADMIN on *.* not supported before 8.0, dropped",
+		"`app`@`%`: ROLE_ADMIN on *.* not supported before 8.0, dropped",
+		"`app`@`%`: roles are not supported before 8.0, dropped `reader`@`%`",
+		"`app`@`%`: partial revokes are not supported before 8.0.16, the global privileges also cover `mysql`.*",
+	}, messages(warnings))
+
+	a := out[0]
+	assert.True(t, a.Locked)
+	assert.Equal(t, "CREATE USER `app`@`%` ACCOUNT LOCK", a.CreateStatement())
+	assert.Len(t, a.Grants, 1)
+	assert.Equal(t, "GRANT SELECT ON *.* TO `app`@`%`", a.Grants[0].Statement(a.ID()))
+	assert.Empty(t, a.Roles)
+	assert.Empty(t, a.Revokes)
+}
+
+func TestAccounts_MariaDB(t *testing.T) {
+	accounts := []account.Account{
+		{User: "app", Host: "%", Plugin: "ed25519", AuthString: "5A49", MaxStatementTime: "30.000000"},
+		{User: "ops", Host: "localhost", Plugin: "unix_socket", Alternatives: []account.Factor{{Plugin: "mysql_native_password", AuthString: "2A"}}},
+		{User: "old", Host: "%", Plugin: "mysql_native_password", AuthString: "36393535636339393635396234313261"},
+	}
+	out, warnings := Accounts(accounts, version(t, "8.0"))
+	assert.Equal(t, []string{
+		"`app`@`%`: the MariaDB plugin ed25519 has no MySQL equivalent; the account is locked without a password and needs a new one",
+		"`app`@`%`: MAX_STATEMENT_TIME is MariaDB only, dropped",
+		"`ops`@`localhost`: alternative authentication (IDENTIFIED VIA ... OR) is MariaDB only, dropped mysql_native_password",
+		"`ops`@`localhost`: unix_socket replaced with auth_socket",
+		"`old`@`%`: pre-4.1 password hashes are not supported; the account is locked without a password and needs a new one",
+	}, messages(warnings))
+	assert.Equal(t, "auth_socket", out[1].Plugin)
+	assert.Empty(t, out[1].Alternatives)
+	assert.True(t, out[2].Locked)
+}
@@ -0,0 +1,226 @@
+// Package weakpass checks dumped authentication strings against a wordlist
+// and trivial variants of the account name. Everything runs locally; no
+// login is ever attempted.
+package weakpass
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/passhash"
+	"github.com/fatih/color"
+)
+
+var red = color.New(color.FgRed).SprintFunc()
+
+// Where a guessed password came from
+const (
+	SourceEmpty    = "empty password"
+	SourceUser     = "same as the user name"
+	SourceReversed = "user name reversed"
+	SourceWordlist = "wordlist"
+)
+
+// Guessable is printed instead of the source when passwords are not revealed
+const Guessable = "guessable password"
+
+// batchSize is the number of candidates a worker checks per job. caching_sha2
+// candidates take milliseconds each, native ones microseconds, so batches keep
+// channel overhead low without starving workers on small wordlists.
+const batchSize = 64
+
+// Finding is an account whose password was guessed
+type Finding struct {
+	Account  string
+	Plugin   string
+	Source   string
+	Password string
+}
+
+// Skip is an account that could not be checked
+type Skip struct {
+	Account string
+	Reason  string
+}
+
+// Result is the outcome of Check
+type Result struct {
+	Checked  int
+	Findings []Finding
+	Skipped  []Skip
+}
+
+type candidate struct {
+	password string
+	source   string
+}
+
+type job struct {
+	index      int
+	candidates []candidate
+}
+
+// ReadWordlist reads one password per line, skipping empty lines
+func ReadWordlist(r io.Reader) ([]string, error) {
+	var words []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if w := strings.TrimRight(sc.Text(), "\r"); w != "" {
+			words = append(words, w)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read wordlist: %w", err)
+	}
+	return words, nil
+}
+
+// candidates returns the passwords to try for user: the user name, the user
+// name reversed and then the wordlist, without duplicates. The empty password
+// is checked separately.
+func candidates(user string, words []string) []candidate {
+	seen := map[string]bool{"": true}
+	var out []candidate
+	add := func(password, source string) {
+		if !seen[password] {
+			seen[password] = true
+			out = append(out, candidate{password, source})
+		}
+	}
+	add(user, SourceUser)
+	add(reverse(user), SourceReversed)
+	for _, w := range words {
+		add(w, SourceWordlist)
+	}
+	return out
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// Check tries the empty password, the user name, the user name reversed and
+// every word against each account using workers goroutines. Roles, locked
+// accounts and accounts using plugins without a stored hash (auth_socket,
+// LDAP, ...) are skipped.
+func Check(ctx context.Context, accounts []account.Account, words []string, workers int) (*Result, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	result := &Result{}
+	findings := make([]*Finding, len(accounts))
+	found := make([]atomic.Bool, len(accounts))
+
+	var pending []int
+	for i, a := range accounts {
+		// nobody can log in as a role or a locked account, which includes
+		// every MySQL role created with CREATE ROLE
+		switch {
+		case a.Role:
+			result.Skipped = append(result.Skipped, Skip{Account: a.ID(), Reason: "role"})
+			continue
+		case a.Locked:
+			result.Skipped = append(result.Skipped, Skip{Account: a.ID(), Reason: "account is locked"})
+			continue
+		}
+		// checking the empty password also validates the stored hash
+		ok, err := passhash.Verify(a.Plugin, a.AuthBytes(), "")
+		if err != nil {
+			result.Skipped = append(result.Skipped, Skip{Account: a.ID(), Reason: err.Error()})
+			continue
+		}
+		result.Checked++
+		if ok {
+			findings[i] = &Finding{Account: a.ID(), Plugin: a.Plugin, Source: SourceEmpty}
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				a := &accounts[j.index]
+				auth := a.AuthBytes()
+				for _, c := range j.candidates {
+					if found[j.index].Load() {
+						break
+					}
+					// the hash was validated above, so Verify cannot fail here
+					if ok, _ := passhash.Verify(a.Plugin, auth, c.password); ok && found[j.index].CompareAndSwap(false, true) {
+						findings[j.index] = &Finding{Account: a.ID(), Plugin: a.Plugin, Source: c.source, Password: c.password}
+					}
+				}
+			}
+		}()
+	}
+
+	var err error
+produce:
+	for _, i := range pending {
+		cands := candidates(accounts[i].User, words)
+		for start := 0; start < len(cands); start += batchSize {
+			if found[i].Load() {
+				break
+			}
+			end := min(start+batchSize, len(cands))
+			select {
+			case jobs <- job{index: i, candidates: cands[start:end]}:
+			case <-ctx.Done():
+				err = ctx.Err()
+				break produce
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range accounts {
+		if findings[i] != nil {
+			result.Findings = append(result.Findings, *findings[i])
+		}
+	}
+	return result, nil
+}
+
+// WriteText writes one line per weak account followed by the totals. How a
+// password was found tells what it is, so the source and guessed passwords
+// are only printed when reveal is set.
+func (r *Result) WriteText(w io.Writer, reveal bool) error {
+	var b strings.Builder
+	for _, f := range r.Findings {
+		if !reveal {
+			fmt.Fprintf(&b, "%s %s (%s): %s\n", red("[!]"), f.Account, f.Plugin, Guessable)
+			continue
+		}
+		fmt.Fprintf(&b, "%s %s (%s): %s", red("[!]"), f.Account, f.Plugin, f.Source)
+		if f.Source == SourceWordlist {
+			fmt.Fprintf(&b, ": %s", account.QuoteString(f.Password))
+		}
+		b.WriteString("\n")
+	}
+	for _, s := range r.Skipped {
+		fmt.Fprintf(&b, "-- %s skipped: %s\n", s.Account, s.Reason)
+	}
+	fmt.Fprintf(&b, "Checked: %d, Weak: %d, Skipped: %d\n", r.Checked, len(r.Findings), len(r.Skipped))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
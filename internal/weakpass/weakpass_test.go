@@ -0,0 +1,118 @@
+package weakpass
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/ChaosHour/go-pass/internal/account"
+	"github.com/ChaosHour/go-pass/internal/passhash"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func withAuth(user, plugin, auth string) account.Account {
+	return account.Account{User: user, Host: "%", Plugin: plugin, AuthString: strings.ToUpper(hex.EncodeToString([]byte(auth)))}
+}
+
+func TestReadWordlist(t *testing.T) {
+	words, err := ReadWordlist(strings.NewReader("secret\r\n\nwinter2024\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"secret", "winter2024"}, words)
+}
+
+func TestCandidates(t *testing.T) {
+	got := candidates("bob", []string{"bob", "secret", ""})
+	assert.Equal(t, []candidate{{"bob", SourceUser}, {"secret", SourceWordlist}}, got)
+
+	got = candidates("app", nil)
+	assert.Equal(t, []candidate{{"app", SourceUser}, {"ppa", SourceReversed}}, got)
+}
+
+func TestCheck(t *testing.T) {
+	salt := []byte("ABCDEFGHIJKLMNOPQRST")
+	accounts := []account.Account{
+		withAuth("app", passhash.CachingSHA2Password, passhash.CachingSHA2("winter2024", salt)),
+		withAuth("empty", passhash.NativePassword, ""),
+		withAuth("monitor", passhash.NativePassword, passhash.Native("rotinom")),
+		withAuth("strong", passhash.CachingSHA2Password, passhash.CachingSHA2("x7#Lq!92vR", salt)),
+		withAuth("svc", passhash.NativePassword, passhash.Native("svc")),
+		{User: "root", Host: "localhost", Plugin: "auth_socket"},
+	}
+	words := []string{"password", "secret", "winter2024"}
+	for i := 0; i < 200; i++ {
+		words = append(words, strings.Repeat("x", i+1))
+	}
+
+	result, err := Check(context.Background(), accounts, words, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, result.Checked)
+	assert.Equal(t, []Finding{
+		{Account: "`app`@`%`", Plugin: passhash.CachingSHA2Password, Source: SourceWordlist, Password: "winter2024"},
+		{Account: "`empty`@`%`", Plugin: passhash.NativePassword, Source: SourceEmpty},
+		{Account: "`monitor`@`%`", Plugin: passhash.NativePassword, Source: SourceReversed, Password: "rotinom"},
+		{Account: "`svc`@`%`", Plugin: passhash.NativePassword, Source: SourceUser, Password: "svc"},
+	}, result.Findings)
+	if assert.Len(t, result.Skipped, 1) {
+		assert.Equal(t, "`root`@`localhost`", result.Skipped[0].Account)
+	}
+}
+
+func TestCheck_LockedAndRoles(t *testing.T) {
+	accounts, err := account.ParseSQL(`
+CREATE USER 'app'@'%' IDENTIFIED WITH 'mysql_native_password' AS '' ACCOUNT LOCK;
+CREATE ROLE 'reader';
+CREATE USER 'open'@'%' IDENTIFIED WITH 'mysql_native_password' AS '';
+`)
+	assert.NoError(t, err)
+	result, err := Check(context.Background(), accounts, nil, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Checked)
+	assert.Equal(t, []Finding{{Account: "`open`@`%`", Plugin: passhash.NativePassword, Source: SourceEmpty}}, result.Findings)
+	assert.Equal(t, []Skip{
+		{Account: "`app`@`%`", Reason: "account is locked"},
+		{Account: "`reader`@`%`", Reason: "role"},
+	}, result.Skipped)
+}
+
+func TestCheck_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	accounts := []account.Account{withAuth("app", passhash.NativePassword, passhash.Native("secret"))}
+	_, err := Check(ctx, accounts, []string{"a", "b"}, 2)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWriteText(t *testing.T) {
+	color.NoColor = true
+	result := &Result{
+		Checked: 4,
+		Findings: []Finding{
+			{Account: "`app`@`%`", Plugin: passhash.NativePassword, Source: SourceWordlist, Password: "secret"},
+			{Account: "`empty`@`%`", Plugin: passhash.NativePassword, Source: SourceEmpty},
+			{Account: "`monitor`@`%`", Plugin: passhash.NativePassword, Source: SourceReversed, Password: "rotinom"},
+			{Account: "`svc`@`%`", Plugin: passhash.NativePassword, Source: SourceUser, Password: "svc"},
+		},
+		Skipped: []Skip{{Account: "`root`@`localhost`", Reason: "unsupported plugin"}},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, result.WriteText(&buf, false))
+	for _, leak := range []string{"secret", "rotinom", SourceWordlist, SourceEmpty, SourceUser, SourceReversed} {
+		assert.NotContains(t, buf.String(), leak)
+	}
+	assert.Equal(t, "[!] `app`@`%` (mysql_native_password): guessable password\n"+
+		"[!] `empty`@`%` (mysql_native_password): guessable password\n"+
+		"[!] `monitor`@`%` (mysql_native_password): guessable password\n"+
+		"[!] `svc`@`%` (mysql_native_password): guessable password\n"+
+		"-- `root`@`localhost` skipped: unsupported plugin\n"+
+		"Checked: 4, Weak: 4, Skipped: 1\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, result.WriteText(&buf, true))
+	assert.Contains(t, buf.String(), "`app`@`%` (mysql_native_password): wordlist: 'secret'\n")
+	assert.Contains(t, buf.String(), "`monitor`@`%` (mysql_native_password): user name reversed\n")
+	assert.Contains(t, buf.String(), "`svc`@`%` (mysql_native_password): same as the user name\n")
+}